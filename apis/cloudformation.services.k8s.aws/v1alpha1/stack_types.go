@@ -26,6 +26,7 @@ SOFTWARE.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -35,25 +36,88 @@ import (
 
 // Defines the desired state of Stack
 type StackSpec struct {
+	// Capabilities acknowledged for the stack, replacing the default capabilities of the operator when given
 	// +kubebuilder:validation:Optional
 	// +optional
 	Capabilities []string `json:"capabilities,omitempty"`
+	// DisableCapabilities removes capabilities from the default capabilities of the operator, and from those inferred
+	// +kubebuilder:validation:Optional
+	// +optional
+	DisableCapabilities []string `json:"disableCapabilities,omitempty"`
+	// DeletionPolicy overrides the DeletionPolicy of resources of the template by logical ID (e.g. retaining a data
+	// bucket), applied to the stack before it is deleted
+	// +kubebuilder:validation:Optional
+	// +optional
+	DeletionPolicy map[string]ResourceDeletionPolicy `json:"deletionPolicy,omitempty"`
+	// EmptyS3BucketsOnDelete empties the S3 buckets created by the stack before it is deleted
+	// +kubebuilder:validation:Optional
+	// +optional
+	EmptyS3BucketsOnDelete bool `json:"emptyS3BucketsOnDelete,omitempty"`
+	// InferCapabilities submits the capabilities CloudFormation reports the template requires along with those given
+	// +kubebuilder:validation:Optional
+	// +optional
+	InferCapabilities bool `json:"inferCapabilities,omitempty"`
+	// ListParameters are parameters of List<> or CommaDelimitedList types, submitted joined with commas
+	// +kubebuilder:validation:Optional
+	// +optional
+	ListParameters map[string][]string `json:"listParameters,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +optional
 	NotificationArns []string `json:"notificationArns,omitempty"`
+	// OnFailure is what CloudFormation does with a stack failing to create. Only applied on create, changing it
+	// afterwards is reported by the CreateOnlyChanged condition
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:Enum=DO_NOTHING;ROLLBACK;DELETE
 	// +optional
 	OnFailure string `json:"onFailure,omitempty"`
+	// OutputsConfigMapRef shapes the keys the outputs are written under in the {name}-cm ConfigMap, e.g. to consume it
+	// with envFrom
+	// +kubebuilder:validation:Optional
+	// +optional
+	OutputsConfigMapRef *OutputsConfigMapReference `json:"outputsConfigMapRef,omitempty"`
+	// OutputsSecretRef writes the outputs of the stack to a Secret in the same namespace
+	// +kubebuilder:validation:Optional
+	// +optional
+	OutputsSecretRef *OutputsSecretReference `json:"outputsSecretRef,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +optional
 	Parameters map[string]string `json:"parameters,omitempty"`
+	// ParametersFrom sources parameter values from elsewhere in the cluster
+	// +kubebuilder:validation:Optional
+	// +optional
+	ParametersFrom []ParameterSource `json:"parametersFrom,omitempty"`
+	// ParametersSchemaRef selects a JSON schema (JSON or YAML) in a ConfigMap the parameters must satisfy before the
+	// stack is created or updated, e.g. restricting instance types or CIDR ranges
+	// +kubebuilder:validation:Optional
+	// +optional
+	ParametersSchemaRef *corev1.ConfigMapKeySelector `json:"parametersSchemaRef,omitempty"`
+	// PreventDeletion blocks deleting the stack until the deletion is confirmed via annotation
+	// +kubebuilder:validation:Optional
+	// +optional
+	PreventDeletion bool `json:"preventDeletion,omitempty"`
+	// PreUpdateAlarmCheck lists CloudWatch alarms (ARNs or names) which must all be OK before the stack is updated
+	// +kubebuilder:validation:Optional
+	// +optional
+	PreUpdateAlarmCheck []string `json:"preUpdateAlarmCheck,omitempty"`
+	// RetryMode selects AWS SDK clients with the given retry mode for operations on this stack
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=standard;adaptive
+	// +optional
+	RetryMode string `json:"retryMode,omitempty"`
+	// ClientConfig tunes the CloudFormation client used for the operations on this stack
+	// +kubebuilder:validation:Optional
+	// +optional
+	ClientConfig *StackClientConfig `json:"clientConfig,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +optional
 	RoleARN string `json:"roleArn,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +optional
 	StackName string `json:"stackName,omitempty"`
+	// RecordTemplateInStatus records the template last submitted in the status for audit
+	// +kubebuilder:validation:Optional
+	// +optional
+	RecordTemplateInStatus bool `json:"recordTemplateInStatus,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +optional
 	Tags map[string]string `json:"tags,omitempty"`
@@ -66,6 +130,64 @@ type StackSpec struct {
 	// +kubebuilder:validation:Optional
 	// +optional
 	TemplateUrl string `json:"templateUrl,omitempty"`
+	// TemplateVersionId pins the S3 object version of the templateUrl submitted
+	// +kubebuilder:validation:Optional
+	// +optional
+	TemplateVersionId string `json:"templateVersionId,omitempty"`
+	// TemplateRef selects a Template in the same namespace holding the template of the stack
+	// +kubebuilder:validation:Optional
+	// +optional
+	TemplateRef *TemplateReference `json:"templateRef,omitempty"`
+	// TrackTemplateUrl updates the stack whenever the content of the S3 object behind templateUrl changes
+	// +kubebuilder:validation:Optional
+	// +optional
+	TrackTemplateUrl bool `json:"trackTemplateUrl,omitempty"`
+	// StackPolicy is the body of the stack policy guarding the resources of the stack against updates
+	// +kubebuilder:validation:Optional
+	// +optional
+	StackPolicy string `json:"stackPolicy,omitempty"`
+	// StackPolicyUrl is the location in S3 of the stack policy guarding the resources of the stack against updates
+	// +kubebuilder:validation:Optional
+	// +optional
+	StackPolicyUrl string `json:"stackPolicyUrl,omitempty"`
+	// TTL is the maximum age of the stack, after which the stack and this resource are deleted
+	// +kubebuilder:validation:Optional
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+	// MinUpdateInterval is the minimum time between updates of the stack, updates due sooner are deferred, e.g. not to
+	// update the stack on every change of a flapping parameter source
+	// +kubebuilder:validation:Optional
+	// +optional
+	MinUpdateInterval *metav1.Duration `json:"minUpdateInterval,omitempty"`
+	// UpdateTimeout is how long an update may run before it is cancelled and rolled back
+	// +kubebuilder:validation:Optional
+	// +optional
+	UpdateTimeout *metav1.Duration `json:"updateTimeout,omitempty"`
+}
+
+// ResourceDeletionPolicy is the CloudFormation DeletionPolicy of a resource
+// +kubebuilder:validation:Enum=Retain;Delete;Snapshot
+type ResourceDeletionPolicy string
+
+const (
+	DeletionPolicyRetain   ResourceDeletionPolicy = "Retain"
+	DeletionPolicyDelete   ResourceDeletionPolicy = "Delete"
+	DeletionPolicySnapshot ResourceDeletionPolicy = "Snapshot"
+)
+
+// StackClientConfig tunes the CloudFormation client used for the operations on a stack, e.g. for large stacks whose
+// calls are slower than most
+type StackClientConfig struct {
+	// MaxAttempts bounds the attempts of each CloudFormation request, replacing the operator default
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+	// CallTimeout bounds each CloudFormation call submitting an operation (0 for no bound), replacing
+	// --cloudformation-call-timeout
+	// +kubebuilder:validation:Optional
+	// +optional
+	CallTimeout *metav1.Duration `json:"callTimeout,omitempty"`
 }
 
 // Defines the observed state of Stack
@@ -83,12 +205,283 @@ type StackStatus struct {
 	// +kubebuilder:validation:Optional
 	// +optional
 	Outputs map[string]string `json:"outputs,omitempty"`
+	// ResolvedParameters are the values CloudFormation resolved SSM-typed parameters
+	// (AWS::SSM::Parameter::Value<...>) to, recorded only when the operator is started with --record-resolved-parameters
+	// +kubebuilder:validation:Optional
+	// +optional
+	ResolvedParameters map[string]string `json:"resolvedParameters,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +optional
 	Resources []StackResource `json:"resources,omitempty"`
+	// NestedStacks are the child stacks of the stack, from its AWS::CloudFormation::Stack resources
+	// +kubebuilder:validation:Optional
+	// +optional
+	NestedStacks []NestedStack `json:"nestedStacks,omitempty"`
+	// AppliedTemplate is the template last submitted with recordTemplateInStatus, unless too large to record
+	// +kubebuilder:validation:Optional
+	// +optional
+	AppliedTemplate string `json:"appliedTemplate,omitempty"`
+	// AppliedTemplateDigest is the SHA-256 digest of the template last submitted with recordTemplateInStatus
+	// +kubebuilder:validation:Optional
+	// +optional
+	AppliedTemplateDigest string `json:"appliedTemplateDigest,omitempty"`
+	// DeleteAttempts counts the deletions attempted while the stack was in DELETE_FAILED
+	// +kubebuilder:validation:Optional
+	// +optional
+	DeleteAttempts int32 `json:"deleteAttempts,omitempty"`
+	// InferredCapabilities lists the capabilities the template was last found to require with inferCapabilities
+	// +kubebuilder:validation:Optional
+	// +optional
+	InferredCapabilities []string `json:"inferredCapabilities,omitempty"`
+	// History lists the most recent stack status transitions, oldest first
+	// +kubebuilder:validation:Optional
+	// +optional
+	History []StackStatusTransition `json:"history,omitempty"`
+	// LastOperationDuration is the time the latest operation took, from its start until the stack settled
+	// +kubebuilder:validation:Optional
+	// +optional
+	LastOperationDuration *metav1.Duration `json:"lastOperationDuration,omitempty"`
+	// AverageOperationDuration is the rolling average of the time operations took, weighting recent operations more
+	// +kubebuilder:validation:Optional
+	// +optional
+	AverageOperationDuration *metav1.Duration `json:"averageOperationDuration,omitempty"`
+	// LastAppliedTemplateHash identifies the template and inputs (parameters, tags, capabilities, role and
+	// notification ARNs) last submitted to the stack
+	// +kubebuilder:validation:Optional
+	// +optional
+	LastAppliedTemplateHash string `json:"lastAppliedTemplateHash,omitempty"`
+	// LastAppliedTemplateSourceHash identifies the template alone (body, or URL with its version or entity tag) last
+	// submitted, updates leaving it unchanged reuse the template on the stack
+	// +kubebuilder:validation:Optional
+	// +optional
+	LastAppliedTemplateSourceHash string `json:"lastAppliedTemplateSourceHash,omitempty"`
+	// TemplateVersionId is the S3 object version of the templateUrl last submitted
+	// +kubebuilder:validation:Optional
+	// +optional
+	TemplateVersionId string `json:"templateVersionId,omitempty"`
+	// CurrentOperationToken is the ClientRequestToken of the latest operation submitted, carried by the stack events
+	// of the operation
+	// +kubebuilder:validation:Optional
+	// +optional
+	CurrentOperationToken string `json:"currentOperationToken,omitempty"`
+	// PendingOperationToken is the ClientRequestToken of an operation whose submission timed out, not knowing whether
+	// CloudFormation received it. Retries for the same generation submit it again so they are not run twice.
+	// +kubebuilder:validation:Optional
+	// +optional
+	PendingOperationToken string `json:"pendingOperationToken,omitempty"`
+	// AppliedStackPolicyHash identifies the stack policy last set on the stack
+	// +kubebuilder:validation:Optional
+	// +optional
+	AppliedStackPolicyHash string `json:"appliedStackPolicyHash,omitempty"`
+	// Progress approximates the resources settled out of those the running operation touched so far
+	// (completed/touched), empty between operations
+	// +kubebuilder:validation:Optional
+	// +optional
+	Progress string `json:"progress,omitempty"`
+	// ResourceCount is the number of resources managed by the stack
+	// +kubebuilder:validation:Optional
+	// +optional
+	ResourceCount int32 `json:"resourceCount,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +optional
 	RoleARN string `json:"roleArn,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	ScheduledDeletionTime *metav1.Time `json:"scheduledDeletionTime,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	NotificationARNs []string `json:"notificationArns,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	RollbackConfiguration *RollbackConfiguration `json:"rollbackConfiguration,omitempty"`
+	// Region the stack was created in
+	// +kubebuilder:validation:Optional
+	// +optional
+	Region string `json:"region,omitempty"`
+	// AWS account the stack was created in
+	// +kubebuilder:validation:Optional
+	// +optional
+	AccountID string `json:"accountID,omitempty"`
+	// FailureSummary explains the latest failure of the stack: its status, the resource which failed and why, and
+	// the AWS request ID when known. Cleared once an operation is submitted or the stack settles healthy.
+	// +kubebuilder:validation:Optional
+	// +optional
+	FailureSummary string `json:"failureSummary,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+const (
+	// ConditionReady indicates the stack has settled in a status considered healthy
+	ConditionReady = "Ready"
+	// ConditionBlockedByAlarm indicates an update is deferred while a pre-update alarm is not OK
+	ConditionBlockedByAlarm = "BlockedByAlarm"
+	// ConditionDeletionBlocked indicates deletion is waiting on confirmation of a protected stack
+	ConditionDeletionBlocked = "DeletionBlocked"
+	// ConditionWaitingOnStackOutput indicates a referenced Stack output (or ConfigMap/Secret key) is not yet available
+	ConditionWaitingOnStackOutput = "WaitingOnStackOutput"
+	// ConditionDependencyCycle indicates the Stacks referenced via parametersFrom lead back to this Stack
+	ConditionDependencyCycle = "DependencyCycle"
+	// ConditionEmptyingS3Buckets indicates the S3 buckets of the stack are being emptied ahead of deletion
+	ConditionEmptyingS3Buckets = "EmptyingS3Buckets"
+	// ConditionHookBlocked indicates the latest stack operation was rejected by a CloudFormation Hook
+	ConditionHookBlocked = "HookBlocked"
+	// ConditionInvalidServiceRole indicates CloudFormation refused the operation as the role in roleArn is not usable
+	ConditionInvalidServiceRole = "InvalidServiceRole"
+	// ConditionDeferred indicates changes to the stack are held by the controller Config, e.g. a maintenance window
+	ConditionDeferred = "Deferred"
+	// ConditionUpdateTimedOut indicates the latest update ran past updateTimeout and was cancelled
+	ConditionUpdateTimedOut = "UpdateTimedOut"
+	// ConditionUpdateCancelled indicates the latest update was cancelled on request with the cancel-update annotation
+	ConditionUpdateCancelled = "UpdateCancelled"
+	// ConditionCreateFailed indicates the stack failed to create and was deleted by CloudFormation (onFailure: DELETE)
+	ConditionCreateFailed = "CreateFailed"
+	// ConditionWaitingOnTemplate indicates the Template in templateRef is missing or the Stack lacks its required
+	// parameters
+	ConditionWaitingOnTemplate = "WaitingOnTemplate"
+	// ConditionInvalidParameters indicates parameters removed from the spec are still required by the template
+	ConditionInvalidParameters = "InvalidParameters"
+	// ConditionSchemaViolation indicates the parameters don't satisfy the schema in parametersSchemaRef
+	ConditionSchemaViolation = "SchemaViolation"
+	// ConditionRegionMismatch indicates the stack lives in another region than the one the operator is configured for
+	ConditionRegionMismatch = "RegionMismatch"
+	// ConditionNamespaceNotAllowed indicates the namespace of the Stack is not allowed to manage stacks
+	ConditionNamespaceNotAllowed = "NamespaceNotAllowed"
+	// ConditionInvalidSpec indicates the spec failed validation, the stack is left alone until it is fixed
+	ConditionInvalidSpec = "InvalidSpec"
+	// ConditionRejected indicates CloudFormation rejected the latest operation outright (e.g. a ValidationError), it is
+	// only submitted again once the spec changes
+	ConditionRejected = "Rejected"
+	// ConditionInvalidTemplate indicates CloudFormation found the template invalid, only checked by a controller
+	// validating Stacks without reconciling them
+	ConditionInvalidTemplate = "InvalidTemplate"
+	// ConditionCreateOnlyChanged indicates a create-only field of the spec (onFailure) no longer matches the stack, the
+	// change only applies should the stack be created again
+	ConditionCreateOnlyChanged = "CreateOnlyChanged"
+	// ConditionQuotaExceeded indicates the latest operation failed on an account limit (e.g. the number of stacks), it
+	// is retried with a long backoff
+	ConditionQuotaExceeded = "QuotaExceeded"
+	// ConditionRegionUnavailable indicates the latest operation failed on the CloudFormation endpoint of the region
+	// being unreachable (e.g. a regional outage), as opposed to the request being refused
+	ConditionRegionUnavailable = "RegionUnavailable"
+	// ConditionTemplateTooLarge indicates CloudFormation refused the inline template as over the TemplateBody size
+	// limit, the template is to be given by URL or uploaded to S3 by the operator
+	ConditionTemplateTooLarge = "TemplateTooLarge"
+	// ConditionPaused indicates the Stack is paused by its paused annotation, neither reconciled nor followed
+	ConditionPaused = "Paused"
+	// ConditionUpdateRateLimited indicates an update of the stack is deferred until minUpdateInterval has passed since
+	// the last one
+	ConditionUpdateRateLimited = "UpdateRateLimited"
+	// ConditionDeletionPolicyRefused indicates CloudFormation refused the update setting the deletionPolicy overrides,
+	// the stack is deleted with the policies of its template
+	ConditionDeletionPolicyRefused = "DeletionPolicyRefused"
+)
+
+// Defines a parameter whose value is sourced from elsewhere in the cluster
+type ParameterSource struct {
+	// Name of the stack parameter
+	Name string `json:"name"`
+	// StackRef selects an output of another Stack in the same namespace
+	// +kubebuilder:validation:Optional
+	// +optional
+	StackRef *StackOutputReference `json:"stackRef,omitempty"`
+	// ConfigMapKeyRef selects a key of a ConfigMap in the same namespace
+	// +kubebuilder:validation:Optional
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// SecretKeyRef selects a key of a Secret in the same namespace
+	// +kubebuilder:validation:Optional
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// Sensitive reads the secretKeyRef value only as the stack is submitted, keeping it out of the status and logs
+	// +kubebuilder:validation:Optional
+	// +optional
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// Selects a Template in the same namespace
+type TemplateReference struct {
+	// Name of the Template
+	Name string `json:"name"`
+}
+
+// Selects an output of a Stack in the same namespace
+type StackOutputReference struct {
+	// Name of the Stack resource
+	Name string `json:"name"`
+	// Output key of the Stack
+	Output string `json:"output"`
+}
+
+// Records a transition of the stack status
+type StackStatusTransition struct {
+	// Time the transition was observed
+	Time metav1.Time `json:"time"`
+	// Status transitioned to
+	Status string `json:"status"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// Selects the Secret the outputs of a Stack are written to
+type OutputsSecretReference struct {
+	// Name of the Secret
+	Name string `json:"name"`
+	// Outputs selects the outputs written (optionally renamed), all outputs are written when empty
+	// +kubebuilder:validation:Optional
+	// +optional
+	Outputs []OutputSelector `json:"outputs,omitempty"`
+}
+
+// Shapes the keys the outputs of a Stack are written under in its ConfigMap
+type OutputsConfigMapReference struct {
+	// KeyPrefix is prepended as is to the key of each output, e.g. MYSTACK_
+	// +kubebuilder:validation:Optional
+	// +optional
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+	// KeyCase transforms the output keys: Upper (VpcId to VPCID) or UpperSnake (VpcId to VPC_ID), kept as is when
+	// empty
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Upper;UpperSnake
+	// +optional
+	KeyCase string `json:"keyCase,omitempty"`
+}
+
+const (
+	// OutputKeyCaseUpper uppercases the output keys
+	OutputKeyCaseUpper = "Upper"
+	// OutputKeyCaseUpperSnake uppercases the output keys, separating their words with underscores
+	OutputKeyCaseUpperSnake = "UpperSnake"
+)
+
+// Selects an output of a Stack and the key it is written as
+type OutputSelector struct {
+	// Output key of the Stack
+	Output string `json:"output"`
+	// Key written to, the output key when empty
+	// +kubebuilder:validation:Optional
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// Defines the rollback configuration reported by CloudFormation for a Stack
+type RollbackConfiguration struct {
+	// +kubebuilder:validation:Optional
+	// +optional
+	MonitoringTimeInMinutes *int32 `json:"monitoringTimeInMinutes,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	RollbackTriggers []RollbackTrigger `json:"rollbackTriggers,omitempty"`
+}
+
+// Defines an alarm monitored by CloudFormation to roll back a Stack operation
+type RollbackTrigger struct {
+	Arn  string `json:"arn"`
+	Type string `json:"type"`
 }
 
 // Defines a resource provided/managed by a Stack and its current state
@@ -102,8 +495,24 @@ type StackResource struct {
 	StatusReason string `json:"statusReason,omitempty"`
 }
 
+// NestedStack is a child stack created by an AWS::CloudFormation::Stack resource of the stack
+type NestedStack struct {
+	LogicalId string `json:"logicalID"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	StackID string `json:"stackID,omitempty"`
+	Status  string `json:"status"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	StatusReason string `json:"statusReason,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.stackStatus`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Progress",type=string,JSONPath=`.status.progress`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Stack is the Schema for the stacks API
 type Stack struct {
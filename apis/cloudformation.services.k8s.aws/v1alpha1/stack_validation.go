@@ -0,0 +1,191 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ssmParameterTypePrefix starts the types of the template parameters CloudFormation resolves from SSM at deploy time
+const ssmParameterTypePrefix = "AWS::SSM::Parameter::Value<"
+
+// Validate checks the spec of the Stack, requiring a template unless the stack already exists (and keeps its
+// template). Used by the webhook and the reconciler alike, each error names the field at fault.
+func (r *Stack) Validate() field.ErrorList {
+	return r.validate(r.Status.StackID == "")
+}
+
+func (r *Stack) validate(requireTemplate bool) field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	// Only one source of the template
+	if r.Spec.Template != "" && r.Spec.TemplateUrl != "" {
+		errs = append(errs, field.Forbidden(spec.Child("templateUrl"), ErrBothTemplateAndUrl.Error()))
+	}
+	if r.Spec.TemplateRef != nil && (r.Spec.Template != "" || r.Spec.TemplateUrl != "") {
+		errs = append(errs, field.Forbidden(spec.Child("templateRef"), ErrTemplateRefAndBody.Error()))
+	}
+	if requireTemplate && r.Spec.Template == "" && r.Spec.TemplateUrl == "" && r.Spec.TemplateRef == nil {
+		errs = append(errs, field.Required(spec.Child("template"), ErrNeedTemplateOrUrl.Error()))
+	}
+	if r.Spec.TemplateVersionId != "" && r.Spec.TemplateUrl == "" {
+		errs = append(errs, field.Forbidden(spec.Child("templateVersionId"), ErrVersionWithoutUrl.Error()))
+	}
+
+	// Only one source of the stack policy, CloudFormation only reads policies written in JSON
+	if r.Spec.StackPolicy != "" && r.Spec.StackPolicyUrl != "" {
+		errs = append(errs, field.Forbidden(spec.Child("stackPolicyUrl"), ErrBothPolicyAndUrl.Error()))
+	}
+	if r.Spec.StackPolicy != "" && !json.Valid([]byte(r.Spec.StackPolicy)) {
+		errs = append(errs, field.Invalid(spec.Child("stackPolicy"), r.Spec.StackPolicy, ErrPolicyNotJSON.Error()))
+	}
+
+	if r.Spec.RoleARN != "" && len(r.Spec.RoleARN) < 20 {
+		errs = append(errs, field.Invalid(spec.Child("roleArn"), r.Spec.RoleARN, ErrRoleArnTooShort.Error()))
+	}
+	if len(r.Spec.NotificationArns) > 5 {
+		errs = append(errs, field.Invalid(spec.Child("notificationArns"), len(r.Spec.NotificationArns),
+			ErrTooManyARNs.Error()))
+	}
+	if len(r.Spec.StackName) > 64 {
+		errs = append(errs, field.Invalid(spec.Child("stackName"), r.Spec.StackName, ErrStackNameTooLong.Error()))
+	} else if r.Spec.StackName != "" && !nameRegex.MatchString(r.Spec.StackName) {
+		errs = append(errs, field.Invalid(spec.Child("stackName"), r.Spec.StackName, ErrStackNameFormat.Error()))
+	}
+	if r.Spec.TTL != nil && r.Spec.TTL.Duration <= 0 {
+		errs = append(errs, field.Invalid(spec.Child("ttl"), r.Spec.TTL.Duration.String(), ErrInvalidTTL.Error()))
+	}
+	if r.Spec.MinUpdateInterval != nil && r.Spec.MinUpdateInterval.Duration <= 0 {
+		errs = append(errs, field.Invalid(spec.Child("minUpdateInterval"), r.Spec.MinUpdateInterval.Duration.String(),
+			ErrInvalidInterval.Error()))
+	}
+
+	// Output keys prefixed remain valid ConfigMap keys
+	if ref := r.Spec.OutputsConfigMapRef; ref != nil && ref.KeyPrefix != "" &&
+		len(validation.IsConfigMapKey(ref.KeyPrefix)) > 0 {
+		errs = append(errs, field.Invalid(spec.Child("outputsConfigMapRef", "keyPrefix"), ref.KeyPrefix,
+			ErrBadOutputKeyPrefix.Error()))
+	}
+
+	// Pseudo parameters can't be given values
+	for _, name := range sortedKeys(r.Spec.Parameters) {
+		if pseudoParameter(name) {
+			errs = append(errs, field.Invalid(spec.Child("parameters").Key(name), name, ErrPseudoParameter.Error()))
+		}
+	}
+	for _, name := range sortedKeys(r.Spec.ListParameters) {
+		if pseudoParameter(name) {
+			errs = append(errs, field.Invalid(spec.Child("listParameters").Key(name), name,
+				ErrPseudoParameter.Error()))
+		}
+	}
+
+	// Parameter sources are complete and don't collide with literal parameters
+	for i, source := range r.Spec.ParametersFrom {
+		path := spec.Child("parametersFrom").Index(i)
+		if pseudoParameter(source.Name) {
+			errs = append(errs, field.Invalid(path.Child("name"), source.Name, ErrPseudoParameter.Error()))
+		}
+		if source.Name == "" || !validParameterSource(source) {
+			errs = append(errs, field.Invalid(path, source.Name, ErrBadParameterSource.Error()))
+		}
+		if source.Sensitive && source.SecretKeyRef == nil {
+			errs = append(errs, field.Forbidden(path.Child("sensitive"), ErrSensitiveNotSecret.Error()))
+		}
+		_, literal := r.Spec.Parameters[source.Name]
+		_, list := r.Spec.ListParameters[source.Name]
+		if literal || list {
+			errs = append(errs, field.Invalid(path.Child("name"), source.Name, ErrDuplicateParameter.Error()))
+		}
+	}
+
+	// List parameters are distinct and, where the inline template can be read, declared as lists. SSM parameter types
+	// (AWS::SSM::Parameter::Value<List<String>>) take the name of the SSM parameter, given as a single value
+	parameterTypes := templateParameterTypes(r.Spec.Template)
+	for _, name := range sortedKeys(r.Spec.ListParameters) {
+		path := spec.Child("listParameters").Key(name)
+		if _, exists := r.Spec.Parameters[name]; exists {
+			errs = append(errs, field.Invalid(path, name, ErrDuplicateParameter.Error()))
+		}
+		if parameterType, declared := parameterTypes[name]; declared && !listParameterType(parameterType) {
+			errs = append(errs, field.Invalid(path, parameterType, ErrListParameterType.Error()))
+		}
+	}
+
+	for i, capability := range r.Spec.Capabilities {
+		if !allowedCapability(capability) {
+			errs = append(errs, field.Invalid(spec.Child("capabilities").Index(i), capability,
+				ErrBadCapability.Error()))
+		}
+	}
+	for i, capability := range r.Spec.DisableCapabilities {
+		if !allowedCapability(capability) {
+			errs = append(errs, field.Invalid(spec.Child("disableCapabilities").Index(i), capability,
+				ErrBadCapability.Error()))
+		}
+	}
+	if len(r.Spec.Capabilities) > 0 && len(r.Spec.DisableCapabilities) > 0 {
+		errs = append(errs, field.Invalid(spec.Child("disableCapabilities"), r.Spec.DisableCapabilities,
+			ErrCapabilityConflict.Error()))
+	}
+	return errs
+}
+
+// listParameterType identifies the template parameter types taking a list of values, excluding the SSM parameter
+// types which CloudFormation resolves from the single SSM parameter name given.
+func listParameterType(parameterType string) bool {
+	return strings.Contains(parameterType, "List") && !strings.HasPrefix(parameterType, ssmParameterTypePrefix)
+}
+
+// pseudoParameter identifies the names of the pseudo parameters CloudFormation reserves, e.g. AWS::Region.
+func pseudoParameter(name string) bool {
+	return strings.HasPrefix(name, "AWS::")
+}
+
+// sortedKeys lists the keys of the map in order, reporting errors in a stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// allowedCapability identifies the capabilities within the known/allowed set.
+func allowedCapability(capability string) bool {
+	for _, allowed := range allowedCapabilities {
+		if capability == allowed {
+			return true
+		}
+	}
+	return false
+}
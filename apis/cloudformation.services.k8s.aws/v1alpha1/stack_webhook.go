@@ -26,7 +26,9 @@ package v1alpha1
 
 import (
 	coreerrors "errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"regexp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -55,6 +57,19 @@ var (
 	ErrBadCapability      = coreerrors.New("Invalid capability specified.")
 	allowedCapabilities   = []string{"CAPABILITY_IAM", "CAPABILITY_NAMED_IAM", "CAPABILITY_AUTO_EXPAND"}
 	ErrStackNameFormat    = coreerrors.New("Stack name can include letters (A-Z and a-z), numbers (0-9), and dashes (-). Must start with a letter.")
+	ErrInvalidTTL         = coreerrors.New("TTL must be a positive duration.")
+	ErrInvalidInterval    = coreerrors.New("MinUpdateInterval must be a positive duration.")
+	ErrBadOutputKeyPrefix = coreerrors.New("The output key prefix can only include letters, numbers, dashes (-), underscores (_) and dots (.).")
+	ErrBadParameterSource = coreerrors.New("Each entry in parametersFrom requires a name and exactly one of stackRef, configMapKeyRef or secretKeyRef.")
+	ErrDuplicateParameter = coreerrors.New("Parameters cannot be specified in more than one of parameters, listParameters and parametersFrom.")
+	ErrListParameterType  = coreerrors.New("List parameters must be declared in the template with a List<...> or CommaDelimitedList type, SSM parameter types take the parameter name in parameters.")
+	ErrVersionWithoutUrl  = coreerrors.New("TemplateVersionId requires TemplateUrl.")
+	ErrBothPolicyAndUrl   = coreerrors.New("StackPolicy and StackPolicyUrl cannot both be provided.")
+	ErrPolicyNotJSON      = coreerrors.New("StackPolicy must be a JSON document.")
+	ErrSensitiveNotSecret = coreerrors.New("Only parameters sourced from a secretKeyRef can be sensitive.")
+	ErrTemplateRefAndBody = coreerrors.New("TemplateRef cannot be combined with Template or TemplateUrl.")
+	ErrPseudoParameter    = coreerrors.New("AWS:: names are reserved for the pseudo parameters CloudFormation provides (e.g. AWS::Region, AWS::AccountId), reference them in the template with Ref instead.")
+	ErrCapabilityConflict = coreerrors.New("DisableCapabilities only applies to the default capabilities, it cannot be combined with Capabilities.")
 	nameRegex, _          = regexp.Compile("^[a-zA-Z][a-zA-Z0-9\\-]*$")
 )
 
@@ -68,50 +83,15 @@ var _ webhook.Validator = &Stack{}
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *Stack) ValidateCreate() (admission.Warnings, error) {
 	stacklog.Info("validate create", "name", r.Name)
+	return nil, r.invalid(r.validate(true))
+}
 
-	// Checking to ensure both the template and templateUrl aren't specified.
-	if r.Spec.Template != "" && r.Spec.TemplateUrl != "" {
-		return nil, ErrBothTemplateAndUrl
-	}
-
-	// Ensuring either the template or templateUrl are specified.
-	if r.Spec.Template == "" && r.Spec.TemplateUrl == "" {
-		return nil, ErrNeedTemplateOrUrl
-	}
-
-	// Ensuring the Role ARN is long enough
-	if r.Spec.RoleARN != "" && len(r.Spec.RoleARN) < 20 {
-		return nil, ErrRoleArnTooShort
-	}
-
-	// Ensuring no more than 5 NotificationARNs are submitted
-	if len(r.Spec.NotificationArns) > 5 {
-		return nil, ErrTooManyARNs
-	}
-
-	if len(r.Spec.StackName) > 64 {
-		return nil, ErrStackNameTooLong
-	}
-
-	if r.Spec.StackName != "" && !nameRegex.Match([]byte(r.Spec.StackName)) {
-		return nil, ErrStackNameFormat
-	}
-
-	// Ensuring the capabilities input are within the known/allowed set
-	for _, x := range r.Spec.Capabilities {
-		goodCapability := false
-		for _, y := range allowedCapabilities {
-			if x == y {
-				goodCapability = true
-				break
-			}
-		}
-		if !goodCapability {
-			return nil, ErrBadCapability
-		}
+// invalid reports the errors found validating the Stack, if any, as an Invalid API error.
+func (r *Stack) invalid(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
 	}
-
-	return nil, nil
+	return apierrors.NewInvalid(GroupVersion.WithKind("Stack").GroupKind(), r.Name, errs)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
@@ -121,19 +101,25 @@ func (r *Stack) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
 	// Converting to Stack type
 	oldStack := old.(*Stack)
 
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
 	if r.Spec.RoleARN == "" && oldStack.Status.RoleARN != "" {
-		return nil, ErrMissingRole
+		errs = append(errs, field.Required(spec.Child("roleArn"), ErrMissingRole.Error()))
 	}
 
-	if r.Spec.OnFailure != oldStack.Spec.OnFailure {
-		return nil, ErrCannotChangeOnFail
-	}
+	// Once the stack is created, changing these would orphan it or has no effect. The region isn't part of the spec,
+	// stacks found in another region than configured are left alone by the controller instead.
+	if oldStack.Status.StackID != "" {
+		if r.Spec.OnFailure != oldStack.Spec.OnFailure {
+			errs = append(errs, field.Forbidden(spec.Child("onFailure"), ErrCannotChangeOnFail.Error()))
+		}
 
-	if r.Spec.StackName != oldStack.Spec.StackName {
-		return nil, ErrCannotRenameStacks
+		if r.Spec.StackName != oldStack.Spec.StackName {
+			errs = append(errs, field.Forbidden(spec.Child("stackName"), ErrCannotRenameStacks.Error()))
+		}
 	}
 
-	return r.ValidateCreate()
+	return nil, r.invalid(append(errs, r.validate(oldStack.Status.StackID == "")...))
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -144,3 +130,48 @@ func (r *Stack) ValidateDelete() (admission.Warnings, error) {
 
 	return nil, nil
 }
+
+// validParameterSource ensures exactly one complete reference is given for the parameter.
+func validParameterSource(source ParameterSource) bool {
+	sources := 0
+	if source.StackRef != nil {
+		if source.StackRef.Name == "" || source.StackRef.Output == "" {
+			return false
+		}
+		sources++
+	}
+	if source.ConfigMapKeyRef != nil {
+		if source.ConfigMapKeyRef.Name == "" || source.ConfigMapKeyRef.Key == "" {
+			return false
+		}
+		sources++
+	}
+	if source.SecretKeyRef != nil {
+		if source.SecretKeyRef.Name == "" || source.SecretKeyRef.Key == "" {
+			return false
+		}
+		sources++
+	}
+	return sources == 1
+}
+
+// templateParameterTypes reads the declared parameter types from an inline JSON or YAML template. Templates which
+// cannot be read yield no types.
+func templateParameterTypes(template string) map[string]string {
+	var parsed struct {
+		Parameters map[string]struct {
+			Type string `json:"Type"`
+		} `json:"Parameters"`
+	}
+	types := map[string]string{}
+	if template == "" {
+		return types
+	}
+	if _, err := ParseTemplate(template, &parsed); err != nil {
+		return types
+	}
+	for name, parameter := range parsed.Parameters {
+		types[name] = parameter.Type
+	}
+	return types
+}
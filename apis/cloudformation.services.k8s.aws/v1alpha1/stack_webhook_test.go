@@ -0,0 +1,326 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// invalidWith identifies the Invalid API errors reporting the expected error among their causes
+func invalidWith(err error, expected error) bool {
+	statusErr, ok := err.(*apierrors.StatusError)
+	if !ok || !apierrors.IsInvalid(err) {
+		return false
+	}
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		if strings.HasSuffix(cause.Message, expected.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestImmutableFieldsAfterCreation(t *testing.T) {
+	old := &Stack{Spec: StackSpec{StackName: "my-bucket", Template: "Resources: {}"}}
+
+	renamed := old.DeepCopy()
+	renamed.Spec.StackName = "my-other-bucket"
+	renamed.Spec.OnFailure = "DELETE"
+	if _, err := renamed.ValidateUpdate(old); err != nil {
+		t.Errorf("expected changes before the stack exists to be allowed, got %v", err)
+	}
+
+	old.Status.StackID = "arn:aws:cloudformation:us-east-1:123456789012:stack/my-bucket/327b7d3c"
+	if _, err := renamed.ValidateUpdate(old); !invalidWith(err, ErrCannotChangeOnFail) {
+		t.Errorf("expected %v, got %v", ErrCannotChangeOnFail, err)
+	}
+	renamed.Spec.OnFailure = ""
+	if _, err := renamed.ValidateUpdate(old); !invalidWith(err, ErrCannotRenameStacks) {
+		t.Errorf("expected %v, got %v", ErrCannotRenameStacks, err)
+	}
+}
+
+func TestParameterSourcesRequireOneReference(t *testing.T) {
+	stack := &Stack{Spec: StackSpec{StackName: "my-bucket", Template: "Resources: {}"}}
+	configMapRef := &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"},
+		Key: "name"}
+
+	stack.Spec.ParametersFrom = []ParameterSource{{Name: "BucketName", ConfigMapKeyRef: configMapRef}}
+	if _, err := stack.ValidateCreate(); err != nil {
+		t.Errorf("expected a ConfigMap reference to be accepted, got %v", err)
+	}
+
+	stack.Spec.ParametersFrom[0].StackRef = &StackOutputReference{Name: "my-network", Output: "VpcId"}
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrBadParameterSource) {
+		t.Errorf("expected %v for two references, got %v", ErrBadParameterSource, err)
+	}
+
+	stack.Spec.ParametersFrom = []ParameterSource{{Name: "BucketName", SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}}}}
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrBadParameterSource) {
+		t.Errorf("expected %v for a reference without a key, got %v", ErrBadParameterSource, err)
+	}
+
+	stack.Spec.ParametersFrom = []ParameterSource{{Name: "BucketName", ConfigMapKeyRef: configMapRef, Sensitive: true}}
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrSensitiveNotSecret) {
+		t.Errorf("expected %v for a sensitive ConfigMap reference, got %v", ErrSensitiveNotSecret, err)
+	}
+}
+
+func TestTemplateOptionalOnceCreated(t *testing.T) {
+	old := &Stack{Spec: StackSpec{StackName: "my-bucket", Template: "Resources: {}"}}
+	updated := old.DeepCopy()
+	updated.Spec.Template = ""
+
+	if _, err := updated.ValidateUpdate(old); !invalidWith(err, ErrNeedTemplateOrUrl) {
+		t.Errorf("expected %v before the stack exists, got %v", ErrNeedTemplateOrUrl, err)
+	}
+	old.Status.StackID = "arn:aws:cloudformation:us-east-1:123456789012:stack/my-bucket/327b7d3c"
+	if _, err := updated.ValidateUpdate(old); err != nil {
+		t.Errorf("expected the template to be optional once the stack exists, got %v", err)
+	}
+}
+
+func TestListParametersDeclaredAsLists(t *testing.T) {
+	template := `Parameters:
+  Subnets:
+    Type: List<AWS::EC2::Subnet::Id>
+  BucketName:
+    Type: String
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Ref BucketName
+`
+	stack := &Stack{Spec: StackSpec{StackName: "my-app", Template: template,
+		ListParameters: map[string][]string{"Subnets": {"subnet-1", "subnet-2"}}}}
+	if _, err := stack.ValidateCreate(); err != nil {
+		t.Errorf("expected a List<> parameter to be accepted, got %v", err)
+	}
+
+	stack.Spec.ListParameters["BucketName"] = []string{"my-bucket"}
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrListParameterType) {
+		t.Errorf("expected %v for a String parameter, got %v", ErrListParameterType, err)
+	}
+
+	delete(stack.Spec.ListParameters, "BucketName")
+	stack.Spec.Parameters = map[string]string{"Subnets": "subnet-1"}
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrDuplicateParameter) {
+		t.Errorf("expected %v, got %v", ErrDuplicateParameter, err)
+	}
+
+	// Reported in the order of the parameter names, the same on every admission
+	stack.Spec.Parameters = map[string]string{"Subnets": "subnet-1", "Azs": "us-east-1a", "Vpcs": "vpc-1"}
+	stack.Spec.ListParameters = map[string][]string{"Subnets": {"subnet-1"}, "Azs": {"us-east-1a"},
+		"Vpcs": {"vpc-1"}}
+	for i := 0; i < 5; i++ {
+		_, err := stack.ValidateCreate()
+		statusErr, ok := err.(*apierrors.StatusError)
+		if !ok {
+			t.Fatalf("expected the duplicates refused, got %v", err)
+		}
+		var fields []string
+		for _, cause := range statusErr.ErrStatus.Details.Causes {
+			fields = append(fields, cause.Field)
+		}
+		expected := []string{"spec.listParameters[Azs]", "spec.listParameters[Subnets]", "spec.listParameters[Vpcs]"}
+		if !reflect.DeepEqual(fields, expected) {
+			t.Fatalf("expected the errors in the order %v, got %v", expected, fields)
+		}
+	}
+}
+
+func TestValidateTemplateVersionId(t *testing.T) {
+	stack := &Stack{Spec: StackSpec{StackName: "my-app", Template: "Resources: {}", TemplateVersionId: "3HL4kqtJlcpXroDTDmJ"}}
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrVersionWithoutUrl) {
+		t.Errorf("expected %v, got %v", ErrVersionWithoutUrl, err)
+	}
+
+	stack.Spec.Template = ""
+	stack.Spec.TemplateUrl = "https://my-bucket.s3.amazonaws.com/stack.yaml"
+	if _, err := stack.ValidateCreate(); err != nil {
+		t.Errorf("expected a versioned templateUrl to be accepted, got %v", err)
+	}
+}
+
+func TestValidateTemplateRef(t *testing.T) {
+	stack := &Stack{Spec: StackSpec{StackName: "my-app", TemplateRef: &TemplateReference{Name: "bucket"}}}
+	if _, err := stack.ValidateCreate(); err != nil {
+		t.Errorf("expected a templateRef to be accepted, got %v", err)
+	}
+
+	stack.Spec.Template = "Resources: {}"
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrTemplateRefAndBody) {
+		t.Errorf("expected %v, got %v", ErrTemplateRefAndBody, err)
+	}
+}
+
+func TestValidateReportsFields(t *testing.T) {
+	stack := &Stack{Spec: StackSpec{StackName: "my-app", Template: "Resources: {}",
+		TemplateUrl: "https://my-bucket.s3.amazonaws.com/stack.yaml", Capabilities: []string{"CAPABILITY_ALL"}}}
+
+	errs := stack.Validate()
+	fields := map[string]bool{}
+	for _, err := range errs {
+		fields[err.Field] = true
+	}
+	if len(errs) != 2 || !fields["spec.templateUrl"] || !fields["spec.capabilities[0]"] {
+		t.Errorf("expected errors on the template URL and capability, got %v", errs)
+	}
+
+	// The template is only required until the stack exists
+	stack.Spec = StackSpec{StackName: "my-app"}
+	if errs = stack.Validate(); len(errs) != 1 || errs[0].Field != "spec.template" {
+		t.Errorf("expected the template required, got %v", errs)
+	}
+	stack.Status.StackID = "arn:aws:cloudformation:us-east-1:123456789012:stack/my-app/327b7d3c"
+	if errs = stack.Validate(); len(errs) != 0 {
+		t.Errorf("expected no errors once the stack exists, got %v", errs)
+	}
+}
+
+func TestValidateDisableCapabilities(t *testing.T) {
+	stack := &Stack{Spec: StackSpec{StackName: "my-app", Template: "Resources: {}",
+		DisableCapabilities: []string{"CAPABILITY_NAMED_IAM"}}}
+	if _, err := stack.ValidateCreate(); err != nil {
+		t.Errorf("expected capabilities disabled from the defaults accepted, got %v", err)
+	}
+
+	stack.Spec.DisableCapabilities = []string{"CAPABILITY_ALL"}
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrBadCapability) {
+		t.Errorf("expected %v, got %v", ErrBadCapability, err)
+	}
+
+	stack.Spec.DisableCapabilities = []string{"CAPABILITY_NAMED_IAM"}
+	stack.Spec.Capabilities = []string{"CAPABILITY_IAM"}
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrCapabilityConflict) {
+		t.Errorf("expected %v, got %v", ErrCapabilityConflict, err)
+	}
+}
+
+func TestValidatePseudoParameters(t *testing.T) {
+	stack := &Stack{Spec: StackSpec{StackName: "my-app", Template: "Resources: {}",
+		Parameters:     map[string]string{"AWS::Region": "us-east-1", "BucketName": "my-bucket"},
+		ListParameters: map[string][]string{"AWS::NotificationARNs": {"arn:aws:sns:us-east-1:123456789012:topic"}},
+		ParametersFrom: []ParameterSource{{Name: "AWS::AccountId",
+			StackRef: &StackOutputReference{Name: "network", Output: "AccountId"}}},
+	}}
+
+	errs := stack.Validate()
+	var fields []string
+	for _, err := range errs {
+		if err.Detail != ErrPseudoParameter.Error() {
+			t.Errorf("unexpected error %v", err)
+		}
+		fields = append(fields, err.Field)
+	}
+	expected := []string{"spec.parameters[AWS::Region]", "spec.listParameters[AWS::NotificationARNs]",
+		"spec.parametersFrom[0].name"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected the pseudo parameters rejected, got %v", errs)
+	}
+}
+
+func TestSSMParametersAccepted(t *testing.T) {
+	template := `Parameters:
+  ImageId:
+    Type: AWS::SSM::Parameter::Value<AWS::EC2::Image::Id>
+  SubnetIds:
+    Type: AWS::SSM::Parameter::Value<List<String>>
+Resources:
+  Instance:
+    Type: AWS::EC2::Instance
+    Properties:
+      ImageId: !Ref ImageId
+`
+	stack := &Stack{Spec: StackSpec{StackName: "my-app", Template: template,
+		Parameters: map[string]string{
+			"ImageId":   "/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64",
+			"SubnetIds": "/my-app/subnet-ids",
+		}}}
+	if _, err := stack.ValidateCreate(); err != nil {
+		t.Errorf("expected SSM parameter names to be accepted, got %v", err)
+	}
+
+	// The SSM parameter holding the list is named once, not given as a list
+	delete(stack.Spec.Parameters, "SubnetIds")
+	stack.Spec.ListParameters = map[string][]string{"SubnetIds": {"/my-app/subnet-a", "/my-app/subnet-b"}}
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrListParameterType) {
+		t.Errorf("expected %v for an SSM parameter given as a list, got %v", ErrListParameterType, err)
+	}
+}
+
+func TestValidateMinUpdateInterval(t *testing.T) {
+	stack := &Stack{Spec: StackSpec{StackName: "my-app", Template: "Resources: {}",
+		MinUpdateInterval: &metav1.Duration{Duration: 15 * time.Minute}}}
+	if _, err := stack.ValidateCreate(); err != nil {
+		t.Errorf("expected a positive interval to be accepted, got %v", err)
+	}
+
+	stack.Spec.MinUpdateInterval.Duration = 0
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrInvalidInterval) {
+		t.Errorf("expected %v, got %v", ErrInvalidInterval, err)
+	}
+}
+
+func TestValidateOutputsKeyPrefix(t *testing.T) {
+	stack := &Stack{Spec: StackSpec{StackName: "my-app", Template: "Resources: {}",
+		OutputsConfigMapRef: &OutputsConfigMapReference{KeyPrefix: "MY_APP_", KeyCase: OutputKeyCaseUpperSnake}}}
+	if _, err := stack.ValidateCreate(); err != nil {
+		t.Errorf("expected a valid key prefix to be accepted, got %v", err)
+	}
+
+	stack.Spec.OutputsConfigMapRef.KeyPrefix = "my app/"
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrBadOutputKeyPrefix) {
+		t.Errorf("expected %v, got %v", ErrBadOutputKeyPrefix, err)
+	}
+}
+
+func TestValidateStackPolicy(t *testing.T) {
+	stack := &Stack{Spec: StackSpec{StackName: "my-app", Template: "Resources: {}",
+		StackPolicy: `{"Statement":[{"Effect":"Allow","Action":"Update:*","Principal":"*","Resource":"*"}]}`}}
+	if _, err := stack.ValidateCreate(); err != nil {
+		t.Errorf("expected a JSON policy to be accepted, got %v", err)
+	}
+
+	stack.Spec.StackPolicyUrl = "https://my-bucket.s3.amazonaws.com/policy.json"
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrBothPolicyAndUrl) {
+		t.Errorf("expected %v, got %v", ErrBothPolicyAndUrl, err)
+	}
+
+	stack.Spec.StackPolicyUrl = ""
+	stack.Spec.StackPolicy = "Statement: []"
+	if _, err := stack.ValidateCreate(); !invalidWith(err, ErrPolicyNotJSON) {
+		t.Errorf("expected %v, got %v", ErrPolicyNotJSON, err)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StackSummaryStatus aggregates the health of all the Stacks managed by the controller
+type StackSummaryStatus struct {
+	// Total is the number of Stack resources
+	Total int32 `json:"total"`
+	// Ready is the number of Stacks whose Ready condition is True
+	Ready int32 `json:"ready"`
+	// ByStatus counts the Stacks by the status of their stack, PENDING for those not created yet
+	// +kubebuilder:validation:Optional
+	// +optional
+	ByStatus map[string]int32 `json:"byStatus,omitempty"`
+	// FailingCount is the number of Stacks settled in a status not considered healthy
+	FailingCount int32 `json:"failingCount"`
+	// Failing lists the Stacks settled in a status not considered healthy, up to the first 100
+	// +kubebuilder:validation:Optional
+	// +optional
+	Failing []StackSummaryItem `json:"failing,omitempty"`
+	// DriftedCount is the number of Stacks whose stack drifted from its template, as of its last drift detection
+	DriftedCount int32 `json:"driftedCount"`
+	// Drifted lists the Stacks whose stack drifted from its template, up to the first 100
+	// +kubebuilder:validation:Optional
+	// +optional
+	Drifted []StackSummaryItem `json:"drifted,omitempty"`
+	// LastUpdated is when the summary was last computed
+	// +kubebuilder:validation:Optional
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+}
+
+// StackSummaryItem identifies a Stack of the summary
+type StackSummaryItem struct {
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	StackStatus string `json:"stackStatus,omitempty"`
+	// Reason is the message of the Ready condition of the Stack
+	// +kubebuilder:validation:Optional
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Total",type=integer,JSONPath=`.status.total`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Failing",type=integer,JSONPath=`.status.failingCount`
+// +kubebuilder:printcolumn:name="Drifted",type=integer,JSONPath=`.status.driftedCount`
+// +kubebuilder:printcolumn:name="Updated",type=date,JSONPath=`.status.lastUpdated`
+
+// StackSummary is the Schema for the stacksummaries API, a fleet overview maintained by the controller
+type StackSummary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status StackSummaryStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// StackSummaryList contains a list of StackSummary
+type StackSummaryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []StackSummary `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&StackSummary{}, &StackSummaryList{})
+}
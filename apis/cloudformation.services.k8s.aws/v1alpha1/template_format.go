@@ -0,0 +1,71 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+)
+
+// TemplateFormat is the format a CloudFormation template is written in
+type TemplateFormat string
+
+const (
+	TemplateFormatJSON TemplateFormat = "JSON"
+	TemplateFormatYAML TemplateFormat = "YAML"
+)
+
+// byteOrderMark may open templates saved by some editors, neither parser expects it
+var byteOrderMark = []byte("\ufeff")
+
+// DetectTemplateFormat tells JSON templates, opening with a brace, from YAML ones.
+func DetectTemplateFormat(template string) TemplateFormat {
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix([]byte(template), byteOrderMark), " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return TemplateFormatJSON
+	}
+	return TemplateFormatYAML
+}
+
+// ParseTemplate reads a JSON or YAML template into out (decoded as JSON would be), reporting the format detected.
+// JSON templates are read strictly as JSON for errors to point at the JSON at fault, YAML ones read the short form
+// of intrinsic functions (!Ref, !Sub...) as their arguments.
+func ParseTemplate(template string, out interface{}) (TemplateFormat, error) {
+	format := DetectTemplateFormat(template)
+	body := bytes.TrimPrefix([]byte(template), byteOrderMark)
+	var err error
+	if format == TemplateFormatJSON {
+		err = json.Unmarshal(body, out)
+	} else {
+		err = yaml.Unmarshal(body, out)
+	}
+	if err != nil {
+		return format, fmt.Errorf("template is not valid %s: %w", format, err)
+	}
+	return format, nil
+}
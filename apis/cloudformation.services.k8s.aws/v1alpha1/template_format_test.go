@@ -0,0 +1,77 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		format   TemplateFormat
+		err      string
+	}{
+		{"json", `{"Resources": {"Bucket": {"Type": "AWS::S3::Bucket"}}}`, TemplateFormatJSON, ""},
+		{"json indented with tabs", "{\n\t\"Resources\": {\n\t\t\"Bucket\": {\"Type\": \"AWS::S3::Bucket\"}\n\t}\n}\n",
+			TemplateFormatJSON, ""},
+		{"json with a byte order mark", "\ufeff" + `{"Resources": {"Bucket": {"Type": "AWS::S3::Bucket"}}}`,
+			TemplateFormatJSON, ""},
+		{"yaml", "Resources:\n  Bucket:\n    Type: AWS::S3::Bucket\n", TemplateFormatYAML, ""},
+		{"yaml with short form functions", "Resources:\n  Bucket:\n    Type: AWS::S3::Bucket\n    Properties:\n" +
+			"      BucketName: !Sub '${AWS::StackName}-bucket'\n      Tags:\n        - Key: Subnet\n" +
+			"          Value: !Select [0, !Ref Subnets]\n", TemplateFormatYAML, ""},
+		{"malformed json", `{"Resources": {"Bucket": }}`, TemplateFormatJSON, "template is not valid JSON"},
+		{"malformed yaml", "Resources:\n  Bucket:\n    Type: AWS::S3::Bucket\n   Properties: {}\n",
+			TemplateFormatYAML, "template is not valid YAML"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var parsed struct {
+				Resources map[string]struct {
+					Type string `json:"Type"`
+				} `json:"Resources"`
+			}
+			format, err := ParseTemplate(tt.template, &parsed)
+			if format != tt.format {
+				t.Errorf("expected the %s format, got %s", tt.format, format)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Errorf("expected %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Resources["Bucket"].Type != "AWS::S3::Bucket" {
+				t.Errorf("expected the bucket read, got %v", parsed.Resources)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TemplateSpec defines a CloudFormation template shared by the Stacks referencing it
+type TemplateSpec struct {
+	// Template is the body of the CloudFormation template (JSON or YAML)
+	Template string `json:"template"`
+	// Parameters describes the parameters Stacks referencing the template set
+	// +kubebuilder:validation:Optional
+	// +optional
+	Parameters []TemplateParameter `json:"parameters,omitempty"`
+}
+
+// Describes a parameter of a shared template
+type TemplateParameter struct {
+	// Name of the template parameter
+	Name string `json:"name"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	Description string `json:"description,omitempty"`
+	// Required parameters must be set by each Stack referencing the template
+	// +kubebuilder:validation:Optional
+	// +optional
+	Required bool `json:"required,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// Template is the Schema for the templates API
+type Template struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TemplateList contains a list of Template
+type TemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Template `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Template{}, &TemplateList{})
+}
@@ -30,9 +30,146 @@ SOFTWARE.
 package v1alpha1
 
 import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NestedStack) DeepCopyInto(out *NestedStack) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NestedStack.
+func (in *NestedStack) DeepCopy() *NestedStack {
+	if in == nil {
+		return nil
+	}
+	out := new(NestedStack)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutputSelector) DeepCopyInto(out *OutputSelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutputSelector.
+func (in *OutputSelector) DeepCopy() *OutputSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(OutputSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutputsConfigMapReference) DeepCopyInto(out *OutputsConfigMapReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutputsConfigMapReference.
+func (in *OutputsConfigMapReference) DeepCopy() *OutputsConfigMapReference {
+	if in == nil {
+		return nil
+	}
+	out := new(OutputsConfigMapReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutputsSecretReference) DeepCopyInto(out *OutputsSecretReference) {
+	*out = *in
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]OutputSelector, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutputsSecretReference.
+func (in *OutputsSecretReference) DeepCopy() *OutputsSecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(OutputsSecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ParameterSource) DeepCopyInto(out *ParameterSource) {
+	*out = *in
+	if in.StackRef != nil {
+		in, out := &in.StackRef, &out.StackRef
+		*out = new(StackOutputReference)
+		**out = **in
+	}
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ParameterSource.
+func (in *ParameterSource) DeepCopy() *ParameterSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ParameterSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollbackConfiguration) DeepCopyInto(out *RollbackConfiguration) {
+	*out = *in
+	if in.MonitoringTimeInMinutes != nil {
+		in, out := &in.MonitoringTimeInMinutes, &out.MonitoringTimeInMinutes
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RollbackTriggers != nil {
+		in, out := &in.RollbackTriggers, &out.RollbackTriggers
+		*out = make([]RollbackTrigger, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollbackConfiguration.
+func (in *RollbackConfiguration) DeepCopy() *RollbackConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(RollbackConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollbackTrigger) DeepCopyInto(out *RollbackTrigger) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollbackTrigger.
+func (in *RollbackTrigger) DeepCopy() *RollbackTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(RollbackTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Stack) DeepCopyInto(out *Stack) {
 	*out = *in
@@ -60,6 +197,26 @@ func (in *Stack) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackClientConfig) DeepCopyInto(out *StackClientConfig) {
+	*out = *in
+	if in.CallTimeout != nil {
+		in, out := &in.CallTimeout, &out.CallTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackClientConfig.
+func (in *StackClientConfig) DeepCopy() *StackClientConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(StackClientConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StackList) DeepCopyInto(out *StackList) {
 	*out = *in
@@ -92,6 +249,21 @@ func (in *StackList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackOutputReference) DeepCopyInto(out *StackOutputReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackOutputReference.
+func (in *StackOutputReference) DeepCopy() *StackOutputReference {
+	if in == nil {
+		return nil
+	}
+	out := new(StackOutputReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StackResource) DeepCopyInto(out *StackResource) {
 	*out = *in
@@ -115,11 +287,48 @@ func (in *StackSpec) DeepCopyInto(out *StackSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.DisableCapabilities != nil {
+		in, out := &in.DisableCapabilities, &out.DisableCapabilities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeletionPolicy != nil {
+		in, out := &in.DeletionPolicy, &out.DeletionPolicy
+		*out = make(map[string]ResourceDeletionPolicy, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ListParameters != nil {
+		in, out := &in.ListParameters, &out.ListParameters
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
 	if in.NotificationArns != nil {
 		in, out := &in.NotificationArns, &out.NotificationArns
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.OutputsConfigMapRef != nil {
+		in, out := &in.OutputsConfigMapRef, &out.OutputsConfigMapRef
+		*out = new(OutputsConfigMapReference)
+		**out = **in
+	}
+	if in.OutputsSecretRef != nil {
+		in, out := &in.OutputsSecretRef, &out.OutputsSecretRef
+		*out = new(OutputsSecretReference)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Parameters != nil {
 		in, out := &in.Parameters, &out.Parameters
 		*out = make(map[string]string, len(*in))
@@ -127,6 +336,28 @@ func (in *StackSpec) DeepCopyInto(out *StackSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.ParametersFrom != nil {
+		in, out := &in.ParametersFrom, &out.ParametersFrom
+		*out = make([]ParameterSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ParametersSchemaRef != nil {
+		in, out := &in.ParametersSchemaRef, &out.ParametersSchemaRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PreUpdateAlarmCheck != nil {
+		in, out := &in.PreUpdateAlarmCheck, &out.PreUpdateAlarmCheck
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClientConfig != nil {
+		in, out := &in.ClientConfig, &out.ClientConfig
+		*out = new(StackClientConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Tags != nil {
 		in, out := &in.Tags, &out.Tags
 		*out = make(map[string]string, len(*in))
@@ -134,6 +365,26 @@ func (in *StackSpec) DeepCopyInto(out *StackSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.TemplateRef != nil {
+		in, out := &in.TemplateRef, &out.TemplateRef
+		*out = new(TemplateReference)
+		**out = **in
+	}
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MinUpdateInterval != nil {
+		in, out := &in.MinUpdateInterval, &out.MinUpdateInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.UpdateTimeout != nil {
+		in, out := &in.UpdateTimeout, &out.UpdateTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackSpec.
@@ -164,11 +415,66 @@ func (in *StackStatus) DeepCopyInto(out *StackStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.ResolvedParameters != nil {
+		in, out := &in.ResolvedParameters, &out.ResolvedParameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Resources != nil {
 		in, out := &in.Resources, &out.Resources
 		*out = make([]StackResource, len(*in))
 		copy(*out, *in)
 	}
+	if in.NestedStacks != nil {
+		in, out := &in.NestedStacks, &out.NestedStacks
+		*out = make([]NestedStack, len(*in))
+		copy(*out, *in)
+	}
+	if in.InferredCapabilities != nil {
+		in, out := &in.InferredCapabilities, &out.InferredCapabilities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]StackStatusTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastOperationDuration != nil {
+		in, out := &in.LastOperationDuration, &out.LastOperationDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.AverageOperationDuration != nil {
+		in, out := &in.AverageOperationDuration, &out.AverageOperationDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ScheduledDeletionTime != nil {
+		in, out := &in.ScheduledDeletionTime, &out.ScheduledDeletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NotificationARNs != nil {
+		in, out := &in.NotificationARNs, &out.NotificationARNs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RollbackConfiguration != nil {
+		in, out := &in.RollbackConfiguration, &out.RollbackConfiguration
+		*out = new(RollbackConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackStatus.
@@ -180,3 +486,236 @@ func (in *StackStatus) DeepCopy() *StackStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackStatusTransition) DeepCopyInto(out *StackStatusTransition) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackStatusTransition.
+func (in *StackStatusTransition) DeepCopy() *StackStatusTransition {
+	if in == nil {
+		return nil
+	}
+	out := new(StackStatusTransition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackSummary) DeepCopyInto(out *StackSummary) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackSummary.
+func (in *StackSummary) DeepCopy() *StackSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(StackSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *StackSummary) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackSummaryItem) DeepCopyInto(out *StackSummaryItem) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackSummaryItem.
+func (in *StackSummaryItem) DeepCopy() *StackSummaryItem {
+	if in == nil {
+		return nil
+	}
+	out := new(StackSummaryItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackSummaryList) DeepCopyInto(out *StackSummaryList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]StackSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackSummaryList.
+func (in *StackSummaryList) DeepCopy() *StackSummaryList {
+	if in == nil {
+		return nil
+	}
+	out := new(StackSummaryList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *StackSummaryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackSummaryStatus) DeepCopyInto(out *StackSummaryStatus) {
+	*out = *in
+	if in.ByStatus != nil {
+		in, out := &in.ByStatus, &out.ByStatus
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Failing != nil {
+		in, out := &in.Failing, &out.Failing
+		*out = make([]StackSummaryItem, len(*in))
+		copy(*out, *in)
+	}
+	if in.Drifted != nil {
+		in, out := &in.Drifted, &out.Drifted
+		*out = make([]StackSummaryItem, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastUpdated != nil {
+		in, out := &in.LastUpdated, &out.LastUpdated
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackSummaryStatus.
+func (in *StackSummaryStatus) DeepCopy() *StackSummaryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StackSummaryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Template) DeepCopyInto(out *Template) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Template.
+func (in *Template) DeepCopy() *Template {
+	if in == nil {
+		return nil
+	}
+	out := new(Template)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Template) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateList) DeepCopyInto(out *TemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Template, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateList.
+func (in *TemplateList) DeepCopy() *TemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateParameter) DeepCopyInto(out *TemplateParameter) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateParameter.
+func (in *TemplateParameter) DeepCopy() *TemplateParameter {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateParameter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateReference) DeepCopyInto(out *TemplateReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateReference.
+func (in *TemplateReference) DeepCopy() *TemplateReference {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateSpec) DeepCopyInto(out *TemplateSpec) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make([]TemplateParameter, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateSpec.
+func (in *TemplateSpec) DeepCopy() *TemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
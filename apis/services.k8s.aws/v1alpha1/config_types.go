@@ -37,6 +37,31 @@ type ConfigSpec struct {
 	// +kubebuilder:validation:Optional
 	// +optional
 	Tags map[string]string `json:"tags,omitempty"`
+	// Defers the creation, update and deletion of all stacks while set, their status is still followed
+	// +kubebuilder:validation:Optional
+	// +optional
+	DeferStackChanges bool `json:"deferStackChanges,omitempty"`
+	// Recurring windows during which the creation, update and deletion of all stacks is deferred
+	// +kubebuilder:validation:Optional
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+}
+
+// Weekday is a day of the week
+// +kubebuilder:validation:Enum=Monday;Tuesday;Wednesday;Thursday;Friday;Saturday;Sunday
+type Weekday string
+
+// MaintenanceWindow is a recurring period of time during which stacks are not changed
+type MaintenanceWindow struct {
+	// Days of the week the window opens (Monday, Tuesday, ...), every day when empty
+	// +kubebuilder:validation:Optional
+	// +optional
+	Days []Weekday `json:"days,omitempty"`
+	// Time of day the window opens, HH:MM in UTC
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+	// How long the window stays open
+	Duration metav1.Duration `json:"duration"`
 }
 
 // ConfigStatus defines the observed state of Config
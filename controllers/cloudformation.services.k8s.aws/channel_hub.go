@@ -30,5 +30,8 @@ import (
 
 type ChannelHub struct {
 	MappingChannel chan *v1alpha1.Stack
-	FollowChannel  chan *v1alpha1.Stack
+	// Stacks submitted to the follower, coalesced by stack ID
+	FollowQueue *FollowQueue
+	// Stack IDs reported by stack event notifications, processed by the follower ahead of its next poll
+	EventChannel chan string
 }
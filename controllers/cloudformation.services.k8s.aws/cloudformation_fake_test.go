@@ -0,0 +1,295 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	servicesv1alpha1 "github.com/cuppett/aws-cloudformation-operator/apis/services.k8s.aws/v1alpha1"
+	servicesk8saws "github.com/cuppett/aws-cloudformation-operator/controllers/services.k8s.aws"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeCloudFormation is an in-memory CloudFormation keeping stacks by name and by stack ID
+type fakeCloudFormation struct {
+	lock      sync.Mutex
+	stacks    map[string]*cfTypes.Stack
+	resources map[string][]cfTypes.StackResourceSummary
+	templates map[string]string
+	events    map[string][]cfTypes.StackEvent
+	pageSize  int
+	createErr error
+	updateErr error
+	// Error answered to every DescribeStacks
+	describeErr error
+	// Error answered to every ValidateTemplate
+	validateErr error
+	// Capabilities reported required by GetTemplateSummary
+	requiredCapabilities []cfTypes.Capability
+	// Parameters reported declared by GetTemplateSummary
+	templateParameters []cfTypes.ParameterDeclaration
+
+	describes      int
+	templateGets   int
+	summaryInputs  []*cloudformation.GetTemplateSummaryInput
+	validateInputs []*cloudformation.ValidateTemplateInput
+	cancelInputs   []*cloudformation.CancelUpdateStackInput
+	createInputs   []*cloudformation.CreateStackInput
+	updateInputs   []*cloudformation.UpdateStackInput
+	deleteInputs   []*cloudformation.DeleteStackInput
+	policyInputs   []*cloudformation.SetStackPolicyInput
+}
+
+func newFakeCloudFormation() *fakeCloudFormation {
+	return &fakeCloudFormation{
+		stacks:    map[string]*cfTypes.Stack{},
+		resources: map[string][]cfTypes.StackResourceSummary{},
+		templates: map[string]string{},
+		events:    map[string][]cfTypes.StackEvent{},
+	}
+}
+
+// addStack registers a stack reachable by its ID and, unless deleted, by its name.
+func (f *fakeCloudFormation) addStack(name string, id string, status cfTypes.StackStatus) *cfTypes.Stack {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	stack := &cfTypes.Stack{
+		StackName:    aws.String(name),
+		StackId:      aws.String(id),
+		StackStatus:  status,
+		CreationTime: aws.Time(fakeCreationTime),
+	}
+	f.stacks[id] = stack
+	if status != cfTypes.StackStatusDeleteComplete {
+		f.stacks[name] = stack
+	} else {
+		delete(f.stacks, name)
+	}
+	return stack
+}
+
+func (f *fakeCloudFormation) notFound(name string) error {
+	return fmt.Errorf("operation error CloudFormation: ValidationError: Stack with id %s does not exist", name)
+}
+
+func (f *fakeCloudFormation) CreateStack(ctx context.Context, params *cloudformation.CreateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateStackOutput, error) {
+	f.lock.Lock()
+	f.createInputs = append(f.createInputs, params)
+	f.lock.Unlock()
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	id := "arn:aws:cloudformation:us-east-1:123456789012:stack/" + *params.StackName + "/created"
+	f.addStack(*params.StackName, id, cfTypes.StackStatusCreateInProgress).Tags = params.Tags
+	f.templates[id] = aws.ToString(params.TemplateBody)
+	return &cloudformation.CreateStackOutput{StackId: aws.String(id)}, nil
+}
+
+func (f *fakeCloudFormation) UpdateStack(ctx context.Context, params *cloudformation.UpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateStackOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.updateInputs = append(f.updateInputs, params)
+	stack, ok := f.stacks[*params.StackName]
+	if !ok {
+		return nil, f.notFound(*params.StackName)
+	}
+	if f.updateErr != nil {
+		return nil, f.updateErr
+	}
+	if params.TemplateBody != nil {
+		f.templates[*stack.StackId] = *params.TemplateBody
+	}
+	return &cloudformation.UpdateStackOutput{StackId: stack.StackId}, nil
+}
+
+func (f *fakeCloudFormation) GetTemplate(ctx context.Context, params *cloudformation.GetTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.templateGets++
+	stack, ok := f.stacks[*params.StackName]
+	if !ok {
+		return nil, f.notFound(*params.StackName)
+	}
+	return &cloudformation.GetTemplateOutput{TemplateBody: aws.String(f.templates[*stack.StackId])}, nil
+}
+
+func (f *fakeCloudFormation) CancelUpdateStack(ctx context.Context, params *cloudformation.CancelUpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CancelUpdateStackOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.cancelInputs = append(f.cancelInputs, params)
+	return &cloudformation.CancelUpdateStackOutput{}, nil
+}
+
+func (f *fakeCloudFormation) GetTemplateSummary(ctx context.Context, params *cloudformation.GetTemplateSummaryInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateSummaryOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.summaryInputs = append(f.summaryInputs, params)
+	return &cloudformation.GetTemplateSummaryOutput{Capabilities: f.requiredCapabilities,
+		Parameters: f.templateParameters}, nil
+}
+
+func (f *fakeCloudFormation) ValidateTemplate(ctx context.Context, params *cloudformation.ValidateTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ValidateTemplateOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.validateInputs = append(f.validateInputs, params)
+	if f.validateErr != nil {
+		return nil, f.validateErr
+	}
+	return &cloudformation.ValidateTemplateOutput{}, nil
+}
+
+func (f *fakeCloudFormation) SetStackPolicy(ctx context.Context, params *cloudformation.SetStackPolicyInput, optFns ...func(*cloudformation.Options)) (*cloudformation.SetStackPolicyOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.policyInputs = append(f.policyInputs, params)
+	return &cloudformation.SetStackPolicyOutput{}, nil
+}
+
+func (f *fakeCloudFormation) DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.deleteInputs = append(f.deleteInputs, params)
+	return &cloudformation.DeleteStackOutput{}, nil
+}
+
+func (f *fakeCloudFormation) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.describes++
+	if f.describeErr != nil {
+		return nil, f.describeErr
+	}
+	if params.StackName == nil {
+		// Listing every stack not yet deleted
+		output := &cloudformation.DescribeStacksOutput{}
+		for key, stack := range f.stacks {
+			if key == aws.ToString(stack.StackId) && stack.StackStatus != cfTypes.StackStatusDeleteComplete {
+				output.Stacks = append(output.Stacks, *stack)
+			}
+		}
+		return output, nil
+	}
+	stack, ok := f.stacks[*params.StackName]
+	if !ok {
+		return nil, f.notFound(*params.StackName)
+	}
+	return &cloudformation.DescribeStacksOutput{Stacks: []cfTypes.Stack{*stack}}, nil
+}
+
+func (f *fakeCloudFormation) DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	stack, ok := f.stacks[*params.StackName]
+	if !ok {
+		return nil, f.notFound(*params.StackName)
+	}
+	return &cloudformation.DescribeStackEventsOutput{StackEvents: f.events[*stack.StackId]}, nil
+}
+
+func (f *fakeCloudFormation) ListStackResources(ctx context.Context, params *cloudformation.ListStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	stack, ok := f.stacks[*params.StackName]
+	if !ok {
+		return nil, f.notFound(*params.StackName)
+	}
+	summaries := f.resources[*stack.StackId]
+
+	// Paging through the summaries when a page size is set
+	start := 0
+	if params.NextToken != nil {
+		_, _ = fmt.Sscanf(*params.NextToken, "%d", &start)
+	}
+	end := len(summaries)
+	output := &cloudformation.ListStackResourcesOutput{}
+	if f.pageSize > 0 && start+f.pageSize < end {
+		end = start + f.pageSize
+		output.NextToken = aws.String(fmt.Sprintf("%d", end))
+	}
+	output.StackResourceSummaries = summaries[start:end]
+	return output, nil
+}
+
+func newTestScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	_ = servicesv1alpha1.AddToScheme(scheme)
+	return scheme
+}
+
+func newFakeClient(objects ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(objects...).
+		WithStatusSubresource(&v1alpha1.Stack{}).
+		Build()
+}
+
+func newTestFollower(k8sClient client.Client, cfn CloudFormationAPI) *StackFollower {
+	return &StackFollower{
+		Client: k8sClient,
+		ChannelHub: ChannelHub{
+			MappingChannel: make(chan *v1alpha1.Stack, 10),
+			FollowQueue:    NewFollowQueue(),
+		},
+		Log:                  logr.Discard(),
+		CloudFormationHelper: &CloudFormationHelper{CloudFormation: cfn},
+		StacksFollowing:      prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_following"}),
+		StacksFollowed:       prometheus.NewCounter(prometheus.CounterOpts{Name: "test_followed"}),
+		Recorder:             record.NewFakeRecorder(10),
+	}
+}
+
+func newTestReconciler(k8sClient client.Client, cfn CloudFormationAPI) *StackReconciler {
+	return &StackReconciler{
+		Client: k8sClient,
+		ChannelHub: ChannelHub{
+			MappingChannel: make(chan *v1alpha1.Stack, 10),
+			FollowQueue:    NewFollowQueue(),
+		},
+		Log:    logr.Discard(),
+		Scheme: k8sClient.Scheme(),
+		CloudFormationHelper: &CloudFormationHelper{
+			ConfigReconciler: servicesk8saws.InitializeConfigReconciler(k8sClient, logr.Discard(), k8sClient.Scheme(),
+				servicesk8saws.AWSClientOptions{}),
+			CloudFormation: cfn,
+			Region:         "us-east-1",
+		},
+		Recorder: record.NewFakeRecorder(10),
+	}
+}
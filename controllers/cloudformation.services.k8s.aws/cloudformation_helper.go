@@ -32,24 +32,164 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
 	servicesk8saws "github.com/cuppett/aws-cloudformation-operator/controllers/services.k8s.aws"
 	"hash/crc32"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Longest stack name accepted by CloudFormation
+	maxStackNameLength = 128
+	// Longest portion of the resource name used in generated stack names
+	maxGeneratedNameLength = 55
 )
 
 var (
-	ErrStackNotFound = coreerrors.New("stack not found")
+	ErrStackNotFound          = coreerrors.New("stack not found")
+	ErrInvalidStackNameAffix  = coreerrors.New("stack name prefix must start with a letter and affixes may only contain letters, numbers and hyphens")
+	ErrStackNameAffixTooLong  = coreerrors.New("stack name prefix and suffix are too long")
+	stackNamePrefixExpression = regexp.MustCompile(`^([a-zA-Z][-a-zA-Z0-9]*)?$`)
+	stackNameSuffixExpression = regexp.MustCompile(`^[-a-zA-Z0-9]*$`)
+	hookFailureExpression     = regexp.MustCompile(`(?i)\bhook(s|\(s\))?[^a-z].*\bfail`)
+	serviceRoleExpression     = regexp.MustCompile(`(?i)iam:PassRole|role .* is invalid or cannot be assumed`)
 )
 
+// CloudFormationAPI is the subset of the CloudFormation client used by the controller
+type CloudFormationAPI interface {
+	CreateStack(ctx context.Context, params *cloudformation.CreateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateStackOutput, error)
+	UpdateStack(ctx context.Context, params *cloudformation.UpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateStackOutput, error)
+	DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error)
+	DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
+	ListStackResources(ctx context.Context, params *cloudformation.ListStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error)
+	GetTemplate(ctx context.Context, params *cloudformation.GetTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error)
+	DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error)
+	CancelUpdateStack(ctx context.Context, params *cloudformation.CancelUpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CancelUpdateStackOutput, error)
+	GetTemplateSummary(ctx context.Context, params *cloudformation.GetTemplateSummaryInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateSummaryOutput, error)
+	ValidateTemplate(ctx context.Context, params *cloudformation.ValidateTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ValidateTemplateOutput, error)
+	SetStackPolicy(ctx context.Context, params *cloudformation.SetStackPolicyInput, optFns ...func(*cloudformation.Options)) (*cloudformation.SetStackPolicyOutput, error)
+}
+
+// CloudWatchAPI is the subset of the CloudWatch client used by the controller
+type CloudWatchAPI interface {
+	DescribeAlarms(ctx context.Context, params *cloudwatch.DescribeAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DescribeAlarmsOutput, error)
+}
+
+// SQSAPI is the subset of the SQS client used by the controller
+type SQSAPI interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+}
+
+// S3API is the subset of the S3 client used by the controller
+type S3API interface {
+	ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
 type CloudFormationHelper struct {
 	*servicesk8saws.ConfigReconciler
+	// CloudFormation overrides the client from the ConfigReconciler when set
+	CloudFormation CloudFormationAPI
+	// CloudWatch overrides the client from the ConfigReconciler when set
+	CloudWatch CloudWatchAPI
+	// SQS overrides the client from the ConfigReconciler when set
+	SQS SQSAPI
+	// S3 overrides the client from the ConfigReconciler when set
+	S3 S3API
+	// Region overrides the region of the clients from the ConfigReconciler when set
+	Region string
+	// Statuses considered healthy (Ready), DefaultReadyStatuses when empty
+	ReadyStatuses []cfTypes.StackStatus
+	// Decorations of the generated stack names (e.g. identifying the cluster)
+	StackNamePrefix string
+	StackNameSuffix string
+	// Template of the generated stack names (e.g. {namespace}-{name}-{hash}), the resource name and hash when empty
+	StackNameTemplate string
+	// How long a described stack is reused by GetStack, no caching when zero
+	StackCacheTTL time.Duration
+	stackCache    sync.Map // StackID -> *cachedStack
+	// Bound on each CloudFormation call, unbounded when zero
+	CallTimeout time.Duration
+}
+
+// cachedStack is a described stack reusable until it expires
+type cachedStack struct {
+	stack   *cfTypes.Stack
+	expires time.Time
 }
 
-func (cf *CloudFormationHelper) GetCloudFormation() *cloudformation.Client {
+// DefaultReadyStatuses are the stack statuses considered healthy unless configured otherwise
+var DefaultReadyStatuses = []cfTypes.StackStatus{
+	cfTypes.StackStatusCreateComplete,
+	cfTypes.StackStatusUpdateComplete,
+	cfTypes.StackStatusImportComplete,
+	cfTypes.StackStatusUpdateRollbackComplete,
+	cfTypes.StackStatusImportRollbackComplete,
+}
+
+func (cf *CloudFormationHelper) GetCloudFormation() CloudFormationAPI {
+	if cf.CloudFormation != nil {
+		return cf.CloudFormation
+	}
 	return cf.ConfigReconciler.GetCloudFormation()
 }
 
+// CloudFormationFor provides the CloudFormation client honoring the retry mode and client config requested by the
+// stack.
+func (cf *CloudFormationHelper) CloudFormationFor(instance *v1alpha1.Stack) CloudFormationAPI {
+	if cf.CloudFormation != nil {
+		return cf.GetCloudFormation()
+	}
+	if instance.Spec.ClientConfig != nil && instance.Spec.ClientConfig.MaxAttempts > 0 {
+		return cf.ConfigReconciler.GetCloudFormationWith(aws.RetryMode(instance.Spec.RetryMode),
+			int(instance.Spec.ClientConfig.MaxAttempts))
+	}
+	if instance.Spec.RetryMode == "" {
+		return cf.GetCloudFormation()
+	}
+	return cf.ConfigReconciler.GetCloudFormationFor(aws.RetryMode(instance.Spec.RetryMode))
+}
+
+// GetRegion provides the region the CloudFormation client targets.
+func (cf *CloudFormationHelper) GetRegion() string {
+	if cf.Region != "" {
+		return cf.Region
+	}
+	return cf.ConfigReconciler.GetRegion()
+}
+
+func (cf *CloudFormationHelper) GetCloudWatch() CloudWatchAPI {
+	if cf.CloudWatch != nil {
+		return cf.CloudWatch
+	}
+	return cf.ConfigReconciler.GetCloudWatch()
+}
+
+func (cf *CloudFormationHelper) GetSQS() SQSAPI {
+	if cf.SQS != nil {
+		return cf.SQS
+	}
+	return cf.ConfigReconciler.GetSQS()
+}
+
+func (cf *CloudFormationHelper) GetS3() S3API {
+	if cf.S3 != nil {
+		return cf.S3
+	}
+	return cf.ConfigReconciler.GetS3()
+}
+
 // StackInTerminalState Identify if the follower considers the state identified as terminal.
 func (cf *CloudFormationHelper) StackInTerminalState(status cfTypes.StackStatus) bool {
 	statusString := string(status)
@@ -62,15 +202,63 @@ func (cf *CloudFormationHelper) StackInTerminalState(status cfTypes.StackStatus)
 	return false
 }
 
+// StackInReadyState Identify if the stack has settled in a state considered healthy, where its outputs can be
+// relied upon.
+func (cf *CloudFormationHelper) StackInReadyState(status cfTypes.StackStatus) bool {
+	readyStatuses := cf.ReadyStatuses
+	if len(readyStatuses) == 0 {
+		readyStatuses = DefaultReadyStatuses
+	}
+	for _, readyStatus := range readyStatuses {
+		if status == readyStatus {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadyCondition Computes the Ready condition (status, reason) for the stack status given.
+func (cf *CloudFormationHelper) ReadyCondition(status cfTypes.StackStatus) (metav1.ConditionStatus, string) {
+	if cf.StackInReadyState(status) {
+		return metav1.ConditionTrue, "Ready"
+	}
+	if cf.StackInTerminalState(status) {
+		return metav1.ConditionFalse, "Degraded"
+	}
+	return metav1.ConditionFalse, "InProgress"
+}
+
+// GetStack retrieves the stack of the Stack resource, reusing the stack described within StackCacheTTL.
 func (cf *CloudFormationHelper) GetStack(ctx context.Context, instance *v1alpha1.Stack) (*cfTypes.Stack, error) {
+	if cf.StackCacheTTL > 0 && instance.Status.StackID != "" {
+		if cached, ok := cf.stackCache.Load(instance.Status.StackID); ok && time.Now().Before(cached.(*cachedStack).expires) {
+			return cached.(*cachedStack).stack, nil
+		}
+	}
+	return cf.GetStackNoCache(ctx, instance)
+}
+
+// GetStackNoCache retrieves the current state of the stack of the Stack resource.
+func (cf *CloudFormationHelper) GetStackNoCache(ctx context.Context, instance *v1alpha1.Stack) (*cfTypes.Stack, error) {
 	// Must use the stack ID to get details/finalization for deleted stacks
-	name := cf.GetStackName(ctx, instance, true)
+	return cf.DescribeStack(ctx, cf.GetStackName(ctx, instance, true))
+}
 
-	resp, err := cf.ConfigReconciler.GetCloudFormation().DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+// InvalidateStack drops the cached stack, e.g. once an operation on it is submitted.
+func (cf *CloudFormationHelper) InvalidateStack(stackId string) {
+	cf.stackCache.Delete(stackId)
+}
+
+// DescribeStack retrieves a single stack by name or stack ID, refreshing the cached stack. Deleted stacks are only
+// visible by stack ID.
+func (cf *CloudFormationHelper) DescribeStack(ctx context.Context, name string) (*cfTypes.Stack, error) {
+	callCtx, cancel := cf.callContext(ctx)
+	defer cancel()
+	resp, err := cf.GetCloudFormation().DescribeStacks(callCtx, &cloudformation.DescribeStacksInput{
 		NextToken: nil,
 		StackName: aws.String(name),
 	})
-	if err != nil {
+	if err = callError(ctx, callCtx, err); err != nil {
 		if strings.Contains(err.Error(), "does not exist") {
 			return nil, ErrStackNotFound
 		}
@@ -80,7 +268,11 @@ func (cf *CloudFormationHelper) GetStack(ctx context.Context, instance *v1alpha1
 		return nil, ErrStackNotFound
 	}
 
-	return &resp.Stacks[0], nil
+	stack := &resp.Stacks[0]
+	if cf.StackCacheTTL > 0 && stack.StackId != nil {
+		cf.stackCache.Store(*stack.StackId, &cachedStack{stack: stack, expires: time.Now().Add(cf.StackCacheTTL)})
+	}
+	return stack, nil
 }
 
 func (cf *CloudFormationHelper) GetStackName(ctx context.Context, instance *v1alpha1.Stack, allowID bool) string {
@@ -90,21 +282,47 @@ func (cf *CloudFormationHelper) GetStackName(ctx context.Context, instance *v1al
 		stackName = instance.Status.StackID
 	} else if instance.Spec.StackName != "" {
 		stackName = instance.Spec.StackName
+	} else if cf.StackNameTemplate != "" {
+		stackName = cf.stackNameFromTemplate(instance)
 	} else {
 		stackName = instance.Name
-		if len(stackName) > 55 {
-			stackName = stackName[:55]
+		maxLength := maxStackNameLength - len(cf.StackNamePrefix) - len(cf.StackNameSuffix) - 9
+		if maxLength > maxGeneratedNameLength {
+			maxLength = maxGeneratedNameLength
+		}
+		if len(stackName) > maxLength {
+			stackName = stackName[:maxLength]
 		}
 		// Generating a small, automatic name differentiator
-		checkSum := crc32.NewIEEE()
-		checkSum.Write([]byte(instance.UID))
-		checkSum.Write([]byte(instance.Namespace))
-		stackName = stackName + "-" + fmt.Sprintf("%08x", checkSum.Sum32())
+		stackName = cf.StackNamePrefix + stackName + "-" + fmt.Sprintf("%08x", stackNameChecksum(instance)) +
+			cf.StackNameSuffix
 	}
 
 	return stackName
 }
 
+// stackNameChecksum is the differentiator of the stack names generated for the Stack resource.
+func stackNameChecksum(instance *v1alpha1.Stack) uint32 {
+	checkSum := crc32.NewIEEE()
+	checkSum.Write([]byte(instance.UID))
+	checkSum.Write([]byte(instance.Namespace))
+	return checkSum.Sum32()
+}
+
+// ValidateStackNameAffixes ensures the prefix and suffix still allow valid generated stack names.
+func (cf *CloudFormationHelper) ValidateStackNameAffixes() error {
+	if !stackNamePrefixExpression.MatchString(cf.StackNamePrefix) ||
+		!stackNameSuffixExpression.MatchString(cf.StackNameSuffix) {
+		return ErrInvalidStackNameAffix
+	}
+	// Leaving room for at least one character of the resource name and the differentiator
+	if len(cf.StackNamePrefix)+len(cf.StackNameSuffix)+10 > maxStackNameLength {
+		return ErrStackNameAffixTooLong
+	}
+	return nil
+}
+
+// GetStackResources lists all resources of the stack, paging through ListStackResources.
 func (cf *CloudFormationHelper) GetStackResources(ctx context.Context, stackId string) ([]v1alpha1.StackResource, error) {
 
 	var next *string
@@ -112,10 +330,13 @@ func (cf *CloudFormationHelper) GetStackResources(ctx context.Context, stackId s
 	toReturn := make([]v1alpha1.StackResource, 0)
 
 	for {
-		resp, err := cf.ConfigReconciler.GetCloudFormation().ListStackResources(ctx, &cloudformation.ListStackResourcesInput{
+		callCtx, cancel := cf.callContext(ctx)
+		resp, err := cf.GetCloudFormation().ListStackResources(callCtx, &cloudformation.ListStackResourcesInput{
 			NextToken: next,
 			StackName: aws.String(stackId),
 		})
+		err = callError(ctx, callCtx, err)
+		cancel()
 		if err != nil {
 			return nil, err
 		}
@@ -148,3 +369,187 @@ func (cf *CloudFormationHelper) GetStackResources(ctx context.Context, stackId s
 
 	return toReturn, nil
 }
+
+// Stack events of the operation start, scanning back stops at these
+var operationStartStatuses = map[cfTypes.ResourceStatus]bool{
+	cfTypes.ResourceStatusCreateInProgress: true,
+	cfTypes.ResourceStatusUpdateInProgress: true,
+	cfTypes.ResourceStatusDeleteInProgress: true,
+	cfTypes.ResourceStatusImportInProgress: true,
+}
+
+// Bounding how far back the stack events of an operation are scanned
+const maxOperationEventPages = 5
+
+// scanOperationEvents visits the events of the latest operation on the stack, newest first, until visit returns true.
+func (cf *CloudFormationHelper) scanOperationEvents(ctx context.Context, stackId string,
+	visit func(event *cfTypes.StackEvent) bool) error {
+	var next *string
+	for page := 0; page < maxOperationEventPages; page++ {
+		callCtx, cancel := cf.callContext(ctx)
+		resp, err := cf.GetCloudFormation().DescribeStackEvents(callCtx, &cloudformation.DescribeStackEventsInput{
+			NextToken: next,
+			StackName: aws.String(stackId),
+		})
+		err = callError(ctx, callCtx, err)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		// Events are listed newest first
+		for i, e := range resp.StackEvents {
+			if visit(&resp.StackEvents[i]) {
+				return nil
+			}
+			if aws.ToString(e.PhysicalResourceId) == stackId && aws.ToString(e.ResourceType) == "AWS::CloudFormation::Stack" &&
+				operationStartStatuses[e.ResourceStatus] {
+				return nil
+			}
+		}
+
+		next = resp.NextToken
+		if next == nil {
+			break
+		}
+	}
+	return nil
+}
+
+// GetOperationEvents lists the events of the latest operation on the stack, newest first.
+func (cf *CloudFormationHelper) GetOperationEvents(ctx context.Context, stackId string) ([]cfTypes.StackEvent, error) {
+	var events []cfTypes.StackEvent
+	err := cf.scanOperationEvents(ctx, stackId, func(event *cfTypes.StackEvent) bool {
+		events = append(events, *event)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetHookFailure scans the events of the latest operation on the stack for a CloudFormation Hook which failed it.
+// Returns nil when no hook failed.
+func (cf *CloudFormationHelper) GetHookFailure(ctx context.Context, stackId string) (*cfTypes.StackEvent, error) {
+	var failure *cfTypes.StackEvent
+	err := cf.scanOperationEvents(ctx, stackId, func(event *cfTypes.StackEvent) bool {
+		if event.HookStatus == cfTypes.HookStatusHookCompleteFailed || event.HookStatus == cfTypes.HookStatusHookFailed {
+			failure = event
+		}
+		return failure != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return failure, nil
+}
+
+// GetResourceFailure scans the events of the latest operation on the stack for the first resource which failed,
+// leaving out the resources cancelled as a consequence. Returns nil when no resource failed.
+func (cf *CloudFormationHelper) GetResourceFailure(ctx context.Context, stackId string) (*cfTypes.StackEvent, error) {
+	var failure *cfTypes.StackEvent
+	err := cf.scanOperationEvents(ctx, stackId, func(event *cfTypes.StackEvent) bool {
+		if strings.HasSuffix(string(event.ResourceStatus), "_FAILED") && aws.ToString(event.PhysicalResourceId) != stackId &&
+			!strings.HasSuffix(aws.ToString(event.ResourceStatusReason), "cancelled") {
+			// Scanning back to the start of the operation, the earliest failure is kept
+			failure = event
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return failure, nil
+}
+
+// ResourceFailureMessage describes the failed resource from its stack event.
+func ResourceFailureMessage(event *cfTypes.StackEvent) string {
+	message := fmt.Sprintf("%s (%s) %s", aws.ToString(event.LogicalResourceId), aws.ToString(event.ResourceType),
+		event.ResourceStatus)
+	if reason := aws.ToString(event.ResourceStatusReason); reason != "" {
+		message += ": " + reason
+	}
+	return message
+}
+
+// HookFailureMessage describes the failed hook from its stack event.
+func HookFailureMessage(event *cfTypes.StackEvent) string {
+	message := fmt.Sprintf("Hook %s failed on %s", aws.ToString(event.HookType), aws.ToString(event.LogicalResourceId))
+	if reason := aws.ToString(event.HookStatusReason); reason != "" {
+		message += ": " + reason
+	}
+	return message
+}
+
+// IsHookFailure identifies errors from CloudFormation caused by a Hook rejecting the operation.
+func IsHookFailure(err error) bool {
+	return err != nil && hookFailureExpression.MatchString(err.Error())
+}
+
+// IsServiceRoleFailure identifies errors from CloudFormation caused by a service role (RoleARN) which cannot be passed
+// or assumed.
+func IsServiceRoleFailure(err error) bool {
+	return err != nil && serviceRoleExpression.MatchString(err.Error())
+}
+
+// GetAlarmsNotOK identifies which of the CloudWatch alarms (by ARN or name) are not in the OK state.
+// Alarms which cannot be found are reported as well.
+func (cf *CloudFormationHelper) GetAlarmsNotOK(ctx context.Context, alarms []string) ([]string, error) {
+	pending := map[string]bool{}
+	names := make([]string, 0, len(alarms))
+	for _, alarm := range alarms {
+		// arn:aws:cloudwatch:us-east-1:123456789012:alarm:MyAlarm
+		if i := strings.Index(alarm, ":alarm:"); strings.HasPrefix(alarm, "arn:") && i >= 0 {
+			alarm = alarm[i+len(":alarm:"):]
+		}
+		if !pending[alarm] {
+			pending[alarm] = true
+			names = append(names, alarm)
+		}
+	}
+
+	notOK := make([]string, 0)
+	// DescribeAlarms accepts up to 100 alarm names per request
+	for start := 0; start < len(names); start += 100 {
+		end := start + 100
+		if end > len(names) {
+			end = len(names)
+		}
+		var next *string
+		for {
+			resp, err := cf.GetCloudWatch().DescribeAlarms(ctx, &cloudwatch.DescribeAlarmsInput{
+				AlarmNames: names[start:end],
+				AlarmTypes: []cwTypes.AlarmType{cwTypes.AlarmTypeMetricAlarm, cwTypes.AlarmTypeCompositeAlarm},
+				NextToken:  next,
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, alarm := range resp.MetricAlarms {
+				delete(pending, *alarm.AlarmName)
+				if alarm.StateValue != cwTypes.StateValueOk {
+					notOK = append(notOK, fmt.Sprintf("%s (%s)", *alarm.AlarmName, alarm.StateValue))
+				}
+			}
+			for _, alarm := range resp.CompositeAlarms {
+				delete(pending, *alarm.AlarmName)
+				if alarm.StateValue != cwTypes.StateValueOk {
+					notOK = append(notOK, fmt.Sprintf("%s (%s)", *alarm.AlarmName, alarm.StateValue))
+				}
+			}
+			next = resp.NextToken
+			if next == nil {
+				break
+			}
+		}
+	}
+
+	for _, name := range names {
+		if pending[name] {
+			notOK = append(notOK, fmt.Sprintf("%s (not found)", name))
+		}
+	}
+
+	return notOK, nil
+}
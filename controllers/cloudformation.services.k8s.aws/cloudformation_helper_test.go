@@ -0,0 +1,147 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReadyConditionPolicy(t *testing.T) {
+	cases := []struct {
+		readyStatuses []cfTypes.StackStatus
+		status        cfTypes.StackStatus
+		expected      metav1.ConditionStatus
+		reason        string
+	}{
+		{nil, cfTypes.StackStatusUpdateRollbackComplete, metav1.ConditionTrue, "Ready"},
+		{nil, cfTypes.StackStatusRollbackComplete, metav1.ConditionFalse, "Degraded"},
+		{nil, cfTypes.StackStatusUpdateInProgress, metav1.ConditionFalse, "InProgress"},
+		{[]cfTypes.StackStatus{cfTypes.StackStatusCreateComplete, cfTypes.StackStatusUpdateComplete},
+			cfTypes.StackStatusUpdateRollbackComplete, metav1.ConditionFalse, "Degraded"},
+	}
+	for _, c := range cases {
+		helper := &CloudFormationHelper{ReadyStatuses: c.readyStatuses}
+		status, reason := helper.ReadyCondition(c.status)
+		if status != c.expected || reason != c.reason {
+			t.Errorf("%s with %v: expected %s/%s, got %s/%s", c.status, c.readyStatuses, c.expected, c.reason,
+				status, reason)
+		}
+	}
+}
+
+func TestGeneratedStackNameAffixes(t *testing.T) {
+	instance := &v1alpha1.Stack{ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", 63), Namespace: "default",
+		UID: "3c7ae8f0"}}
+
+	helper := &CloudFormationHelper{StackNamePrefix: "prod-east-", StackNameSuffix: "-k8s"}
+	stackName := helper.GetStackName(context.TODO(), instance, true)
+	if !strings.HasPrefix(stackName, "prod-east-aaaa") || !strings.HasSuffix(stackName, "-k8s") {
+		t.Errorf("expected the prefix and suffix to decorate the name, got %s", stackName)
+	}
+
+	helper.StackNamePrefix = "p" + strings.Repeat("x", 100)
+	if err := helper.ValidateStackNameAffixes(); err != nil {
+		t.Fatal(err)
+	}
+	if stackName = helper.GetStackName(context.TODO(), instance, true); len(stackName) > maxStackNameLength {
+		t.Errorf("expected at most %d characters, got %d", maxStackNameLength, len(stackName))
+	}
+
+	// Explicit names are used as given
+	instance.Spec.StackName = "my-bucket"
+	if stackName = helper.GetStackName(context.TODO(), instance, true); stackName != "my-bucket" {
+		t.Errorf("expected the explicit name, got %s", stackName)
+	}
+
+	for _, invalid := range []*CloudFormationHelper{{StackNamePrefix: "1prod"}, {StackNameSuffix: "_k8s"},
+		{StackNamePrefix: "p" + strings.Repeat("x", 120)}} {
+		if invalid.ValidateStackNameAffixes() == nil {
+			t.Errorf("expected %q/%q to be rejected", invalid.StackNamePrefix, invalid.StackNameSuffix)
+		}
+	}
+}
+
+func TestGetStackResourcesPaginates(t *testing.T) {
+	cfn := newFakeCloudFormation()
+	cfn.pageSize = 100
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	for i := 0; i < 250; i++ {
+		cfn.resources[testStackID] = append(cfn.resources[testStackID], cfTypes.StackResourceSummary{
+			LogicalResourceId:  aws.String(fmt.Sprintf("Bucket%d", i)),
+			PhysicalResourceId: aws.String(fmt.Sprintf("my-bucket-%d", i)),
+			ResourceType:       aws.String("AWS::S3::Bucket"),
+			ResourceStatus:     cfTypes.ResourceStatusCreateComplete,
+		})
+	}
+	helper := &CloudFormationHelper{CloudFormation: cfn}
+
+	resources, err := helper.GetStackResources(context.TODO(), testStackID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 250 {
+		t.Fatalf("expected all 250 resources across pages, got %d", len(resources))
+	}
+	if resources[249].LogicalId != "Bucket249" {
+		t.Errorf("expected the last page to be included, got %s", resources[249].LogicalId)
+	}
+}
+
+func TestGetStackCachedByStackID(t *testing.T) {
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	helper := &CloudFormationHelper{CloudFormation: cfn, StackCacheTTL: time.Minute}
+	instance := &v1alpha1.Stack{Status: v1alpha1.StackStatus{StackID: testStackID}}
+
+	for i := 0; i < 3; i++ {
+		if _, err := helper.GetStack(context.TODO(), instance); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cfn.describes != 1 {
+		t.Errorf("expected a single describe within the TTL, got %d", cfn.describes)
+	}
+
+	// Bypassing the cache when freshness is required, and after an operation was submitted
+	if _, err := helper.GetStackNoCache(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	helper.InvalidateStack(testStackID)
+	if _, err := helper.GetStack(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if cfn.describes != 3 {
+		t.Errorf("expected the stack described again, got %d describes", cfn.describes)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	coreerrors "errors"
+	"fmt"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+)
+
+// ErrCallTimeout Identifies a CloudFormation call abandoned after CallTimeout, the call can be retried.
+var ErrCallTimeout = coreerrors.New("CloudFormation call timed out")
+
+// callContext bounds a single CloudFormation call by CallTimeout, when configured.
+func (cf *CloudFormationHelper) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if cf.CallTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, cf.CallTimeout)
+}
+
+// callContextFor bounds a single CloudFormation call on the stack by the call timeout of its client config, CallTimeout
+// otherwise.
+func (cf *CloudFormationHelper) callContextFor(ctx context.Context, instance *v1alpha1.Stack) (context.Context,
+	context.CancelFunc) {
+	if instance.Spec.ClientConfig == nil || instance.Spec.ClientConfig.CallTimeout == nil {
+		return cf.callContext(ctx)
+	}
+	if instance.Spec.ClientConfig.CallTimeout.Duration <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, instance.Spec.ClientConfig.CallTimeout.Duration)
+}
+
+// callError identifies calls failing because they ran past CallTimeout (as opposed to the caller giving up),
+// wrapping their error in ErrCallTimeout.
+func callError(ctx context.Context, callCtx context.Context, err error) error {
+	if err != nil && ctx.Err() == nil && coreerrors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrCallTimeout, err)
+	}
+	return err
+}
+
+// IsCallTimeout identifies errors from CloudFormation calls which ran past CallTimeout.
+func IsCallTimeout(err error) bool {
+	return coreerrors.Is(err, ErrCallTimeout)
+}
@@ -0,0 +1,220 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// hangingCloudFormation never answers updates or describes, until the call is abandoned
+type hangingCloudFormation struct {
+	*fakeCloudFormation
+}
+
+func (h *hangingCloudFormation) UpdateStack(ctx context.Context, params *cloudformation.UpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateStackOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (h *hangingCloudFormation) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// hangingUpdates only hangs on updates
+type hangingUpdates struct {
+	*fakeCloudFormation
+}
+
+func (h *hangingUpdates) UpdateStack(ctx context.Context, params *cloudformation.UpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateStackOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// hangingDeletes only hangs on deletions, recording the stacks attempted
+type hangingDeletes struct {
+	*fakeCloudFormation
+	attempted []string
+}
+
+func (h *hangingDeletes) DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error) {
+	h.attempted = append(h.attempted, aws.ToString(params.StackName))
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// hangingTemplateReads only hangs reading templates and their summaries
+type hangingTemplateReads struct {
+	*fakeCloudFormation
+}
+
+func (h *hangingTemplateReads) GetTemplate(ctx context.Context, params *cloudformation.GetTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (h *hangingTemplateReads) GetTemplateSummary(ctx context.Context, params *cloudformation.GetTemplateSummaryInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateSummaryOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestUpdateCallTimeoutRetried(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate + "\n"})
+	r.CloudFormationHelper.CloudFormation = &hangingCloudFormation{cfn}
+	r.CloudFormationHelper.CallTimeout = 10 * time.Millisecond
+
+	err := r.updateStack(loop)
+	if !IsCallTimeout(err) {
+		t.Fatalf("expected the timed out update to be retried, got %v", err)
+	}
+	if !loop.submitted || r.ChannelHub.FollowQueue.Len() != 1 {
+		t.Error("expected the stack possibly updating followed, holding its operation slot")
+	}
+	if !strings.HasPrefix(loop.instance.Status.PendingOperationToken, "update-0-") {
+		t.Errorf("expected the token of the update kept, got %q", loop.instance.Status.PendingOperationToken)
+	}
+}
+
+func TestUpdateCallTimeoutResubmitsToken(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Generation: 2,
+			Finalizers: []string{stacksFinalizer}},
+		Spec:   v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	stack.Tags = []cfTypes.Tag{{Key: aws.String(controllerKey), Value: aws.String(controllerValue)}}
+	r := newTestReconciler(k8sClient, cfn)
+	r.CloudFormationHelper.CloudFormation = &hangingUpdates{cfn}
+	r.CloudFormationHelper.CallTimeout = 10 * time.Millisecond
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); !IsCallTimeout(err) {
+		t.Fatalf("expected the update to time out, got %v", err)
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	pending := instance.Status.PendingOperationToken
+	if !strings.HasPrefix(pending, "update-2-") {
+		t.Fatalf("expected the token of the timed out update recorded, got %q", pending)
+	}
+
+	// CloudFormation received the update after all, the stack is followed rather than updated again
+	r.CloudFormationHelper.CloudFormation = cfn
+	stack.StackStatus = cfTypes.StackStatusUpdateInProgress
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 0 {
+		t.Fatalf("expected no update while the stack is updating, got %d updates", len(cfn.updateInputs))
+	}
+
+	// Submitted again, the retry carries the same token
+	stack.StackStatus = cfTypes.StackStatusUpdateComplete
+	r.CloudFormationHelper.InvalidateStack(testStackID)
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 || aws.ToString(cfn.updateInputs[0].ClientRequestToken) != pending {
+		t.Fatalf("expected the update retried with the token %q, got %v", pending, cfn.updateInputs)
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.PendingOperationToken != "" || instance.Status.CurrentOperationToken != pending {
+		t.Errorf("expected the token current and no longer pending, got %q and %q",
+			instance.Status.CurrentOperationToken, instance.Status.PendingOperationToken)
+	}
+}
+
+func TestUpdateCallTimeoutFromClientConfig(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate + "\n",
+		ClientConfig: &v1alpha1.StackClientConfig{CallTimeout: &metav1.Duration{Duration: 10 * time.Millisecond}}})
+	r.CloudFormationHelper.CloudFormation = &hangingUpdates{cfn}
+	// Without the client config of the stack, the update would wait for an hour
+	r.CloudFormationHelper.CallTimeout = time.Hour
+
+	if err := r.updateStack(loop); !IsCallTimeout(err) {
+		t.Fatalf("expected the update bound by the timeout of the stack, got %v", err)
+	}
+}
+
+func TestInferCapabilitiesCallTimeout(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate + "\n",
+		InferCapabilities: true})
+	r.CloudFormationHelper.CloudFormation = &hangingTemplateReads{cfn}
+	r.CloudFormationHelper.CallTimeout = 10 * time.Millisecond
+
+	if err := r.updateStack(loop); !IsCallTimeout(err) {
+		t.Fatalf("expected inferring the capabilities bound by the call timeout, got %v", err)
+	}
+	if len(cfn.updateInputs) != 0 {
+		t.Errorf("expected no update without the capabilities, got %d updates", len(cfn.updateInputs))
+	}
+}
+
+func TestRecordAppliedTemplateCallTimeout(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket",
+		TemplateUrl: "https://my-bucket.s3.amazonaws.com/template.yaml", RecordTemplateInStatus: true})
+	r.CloudFormationHelper.CloudFormation = &hangingTemplateReads{cfn}
+	r.CloudFormationHelper.CallTimeout = 10 * time.Millisecond
+
+	// Reading the template back is abandoned, leaving it unrecorded
+	r.recordAppliedTemplate(loop)
+	if loop.instance.Status.AppliedTemplateDigest != "" {
+		t.Errorf("expected no template recorded, got %q", loop.instance.Status.AppliedTemplateDigest)
+	}
+}
+
+func TestDescribeCallTimeout(t *testing.T) {
+	cf := &CloudFormationHelper{
+		CloudFormation: &hangingCloudFormation{newFakeCloudFormation()},
+		CallTimeout:    10 * time.Millisecond,
+	}
+	if _, err := cf.DescribeStack(context.TODO(), "my-bucket"); !IsCallTimeout(err) {
+		t.Errorf("expected %v, got %v", ErrCallTimeout, err)
+	}
+
+	// The caller giving up is not a timeout of the call
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	if _, err := cf.DescribeStack(ctx, "my-bucket"); err == nil || IsCallTimeout(err) {
+		t.Errorf("expected the cancellation reported as is, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Time allowed for the sink to store each deployment record
+	deploymentRecordTimeout = 30 * time.Second
+	// Value recorded in place of the parameters sourced from Secrets
+	redactedParameter = "<redacted>"
+)
+
+// successfulStatuses are the statuses a stack settles in when its operation succeeded
+var successfulStatuses = map[cfTypes.StackStatus]bool{
+	cfTypes.StackStatusCreateComplete: true,
+	cfTypes.StackStatusUpdateComplete: true,
+	cfTypes.StackStatusImportComplete: true,
+	cfTypes.StackStatusDeleteComplete: true,
+}
+
+// DeploymentRecord is the audit record of an operation on a stack which succeeded
+type DeploymentRecord struct {
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	UID          string `json:"uid"`
+	StackName    string `json:"stackName"`
+	StackID      string `json:"stackID"`
+	Status       string `json:"status"`
+	TemplateHash string `json:"templateHash,omitempty"`
+	// TemplateDigest is the digest of the template submitted, when recorded
+	TemplateDigest string `json:"templateDigest,omitempty"`
+	// Parameters live on the stack, those sourced from Secrets redacted (NoEcho ones are masked by CloudFormation)
+	Parameters         map[string]string `json:"parameters,omitempty"`
+	ClientRequestToken string            `json:"clientRequestToken,omitempty"`
+	StartedTime        *time.Time        `json:"startedTime,omitempty"`
+	CompletedTime      time.Time         `json:"completedTime"`
+	// Initiator is the field manager which last changed the spec of the Stack (e.g. kubectl or a GitOps agent)
+	Initiator string `json:"initiator,omitempty"`
+}
+
+// DeploymentSink stores deployment records outside the cluster, e.g. for audit.
+type DeploymentSink interface {
+	RecordDeployment(ctx context.Context, record *DeploymentRecord) error
+}
+
+// WebhookDeploymentSink posts each deployment record as JSON to a webhook.
+type WebhookDeploymentSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *WebhookDeploymentSink) RecordDeployment(ctx context.Context, record *DeploymentRecord) error {
+	return postJSON(ctx, s.Client, s.URL, record)
+}
+
+// S3DeploymentSink writes each deployment record to its own object under a prefix of a bucket, keyed by the Stack and
+// the time the operation completed, e.g. with Object Lock enabled on the bucket for an immutable history.
+type S3DeploymentSink struct {
+	CloudFormationHelper *CloudFormationHelper
+	Bucket               string
+	// Prefix of the keys of the records, e.g. deployments/
+	Prefix string
+}
+
+func (s *S3DeploymentSink) RecordDeployment(ctx context.Context, record *DeploymentRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = s.CloudFormationHelper.GetS3().PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.key(record)),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+// key provides the object key of the record, sorting the records of a Stack by completion time.
+func (s *S3DeploymentSink) key(record *DeploymentRecord) string {
+	return fmt.Sprintf("%s%s/%s/%s-%s.json", s.Prefix, record.Namespace, record.Name,
+		record.CompletedTime.UTC().Format("20060102T150405Z"), strings.ToLower(record.Status))
+}
+
+// recordDeployment has the DeploymentSink store the record of the operation the stack just settled from, when it
+// succeeded. Stored in the background so the follower is not held up by the sink.
+func (f *StackFollower) recordDeployment(instance *v1alpha1.Stack, previousStatus string, cfs *cfTypes.Stack,
+	now time.Time) {
+	if f.DeploymentSink == nil || !successfulStatuses[cfs.StackStatus] || previousStatus == "" ||
+		f.CloudFormationHelper.StackInTerminalState(cfTypes.StackStatus(previousStatus)) {
+		return
+	}
+	record := newDeploymentRecord(instance, cfs, now)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), deploymentRecordTimeout)
+		defer cancel()
+		if err := f.DeploymentSink.RecordDeployment(ctx, record); err != nil {
+			f.Log.Error(err, "Failed to store the deployment record", "Namespace", record.Namespace, "Name",
+				record.Name, "status", record.Status)
+		}
+	}()
+}
+
+// newDeploymentRecord compiles the record of the operation the stack settled from.
+func newDeploymentRecord(instance *v1alpha1.Stack, cfs *cfTypes.Stack, now time.Time) *DeploymentRecord {
+	record := &DeploymentRecord{
+		Namespace:          instance.Namespace,
+		Name:               instance.Name,
+		UID:                string(instance.UID),
+		StackName:          aws.ToString(cfs.StackName),
+		StackID:            aws.ToString(cfs.StackId),
+		Status:             string(cfs.StackStatus),
+		TemplateHash:       instance.Status.LastAppliedTemplateHash,
+		TemplateDigest:     instance.Status.AppliedTemplateDigest,
+		ClientRequestToken: instance.Status.CurrentOperationToken,
+		StartedTime:        operationStart(cfs),
+		CompletedTime:      now.UTC(),
+		Initiator:          specManager(instance),
+	}
+
+	secretSourced := map[string]bool{}
+	for _, source := range instance.Spec.ParametersFrom {
+		if source.SecretKeyRef != nil {
+			secretSourced[source.Name] = true
+		}
+	}
+	for _, parameter := range cfs.Parameters {
+		if record.Parameters == nil {
+			record.Parameters = map[string]string{}
+		}
+		key := aws.ToString(parameter.ParameterKey)
+		if secretSourced[key] {
+			record.Parameters[key] = redactedParameter
+		} else {
+			record.Parameters[key] = aws.ToString(parameter.ParameterValue)
+		}
+	}
+	return record
+}
+
+// specManager identifies the field manager which last changed the spec of the Stack from its managed fields, empty
+// when none is known.
+func specManager(instance *v1alpha1.Stack) string {
+	var manager string
+	var latest *metav1.Time
+	for _, entry := range instance.ManagedFields {
+		if entry.Subresource != "" || entry.FieldsV1 == nil || !bytes.Contains(entry.FieldsV1.Raw, []byte(`"f:spec"`)) {
+			continue
+		}
+		if latest == nil || (entry.Time != nil && entry.Time.After(latest.Time)) {
+			manager, latest = entry.Manager, entry.Time
+		}
+	}
+	return manager
+}
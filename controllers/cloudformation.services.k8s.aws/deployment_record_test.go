@@ -0,0 +1,162 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// recordingSink hands the deployment records over a channel
+type recordingSink chan *DeploymentRecord
+
+func (s recordingSink) RecordDeployment(ctx context.Context, record *DeploymentRecord) error {
+	s <- record
+	return nil
+}
+
+func TestDeploymentRecordedToWebhook(t *testing.T) {
+	received := make(chan DeploymentRecord, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record := DeploymentRecord{}
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			t.Error(err)
+		}
+		received <- record
+	}))
+	defer server.Close()
+
+	specChanged := metav1.NewTime(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-db", Namespace: "default", UID: "1234",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kubectl-client-side-apply", Operation: metav1.ManagedFieldsOperationUpdate,
+					Time: &metav1.Time{Time: specChanged.Add(-time.Hour)}, FieldsType: "FieldsV1",
+					FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{}}}`)}},
+				{Manager: "argocd-controller", Operation: metav1.ManagedFieldsOperationApply,
+					Time: &specChanged, FieldsType: "FieldsV1",
+					FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:parameters":{}}}`)}},
+				{Manager: "cloudformation-operator", Operation: metav1.ManagedFieldsOperationUpdate,
+					Time: &metav1.Time{Time: specChanged.Add(time.Hour)}, Subresource: "status", FieldsType: "FieldsV1",
+					FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:stackStatus":{}}}`)}},
+			}},
+		Spec: v1alpha1.StackSpec{StackName: "my-db", ParametersFrom: []v1alpha1.ParameterSource{{Name: "Password",
+			SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db"},
+				Key: "password"}}}},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_IN_PROGRESS",
+			LastAppliedTemplateHash: "abc123", CurrentOperationToken: "update-2-xyz"},
+	}
+	cfn := newFakeCloudFormation()
+	stack := cfn.addStack("my-db", testStackID, cfTypes.StackStatusUpdateComplete)
+	stack.LastUpdatedTime = aws.Time(specChanged.Add(time.Minute))
+	stack.Parameters = []cfTypes.Parameter{
+		{ParameterKey: aws.String("InstanceClass"), ParameterValue: aws.String("db.t3.micro")},
+		{ParameterKey: aws.String("Password"), ParameterValue: aws.String("hunter2")},
+	}
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	follower.DeploymentSink = &WebhookDeploymentSink{URL: server.URL}
+
+	follower.startFollowing(instance)
+	follower.mapPollingList.Range(follower.processStack)
+
+	select {
+	case record := <-received:
+		if record.Namespace != "default" || record.Name != "my-db" || record.UID != "1234" ||
+			record.StackID != testStackID || record.Status != "UPDATE_COMPLETE" || record.TemplateHash != "abc123" ||
+			record.ClientRequestToken != "update-2-xyz" || record.Initiator != "argocd-controller" ||
+			record.StartedTime == nil || !record.StartedTime.Equal(*stack.LastUpdatedTime) ||
+			record.CompletedTime.IsZero() {
+			t.Errorf("unexpected record %+v", record)
+		}
+		expected := map[string]string{"InstanceClass": "db.t3.micro", "Password": redactedParameter}
+		if !reflect.DeepEqual(record.Parameters, expected) {
+			t.Errorf("expected the parameters %v, got %v", expected, record.Parameters)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the deployment recorded")
+	}
+}
+
+func TestDeploymentRecordedOnlyOnSuccess(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_ROLLBACK_IN_PROGRESS"},
+	}
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateRollbackComplete)
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	sink := make(recordingSink, 1)
+	follower.DeploymentSink = sink
+
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case record := <-sink:
+		t.Fatalf("expected no record of a rolled back update, got %+v", record)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestS3DeploymentSinkKeysRecords(t *testing.T) {
+	s3Client := &fakeS3{}
+	sink := &S3DeploymentSink{CloudFormationHelper: &CloudFormationHelper{S3: s3Client}, Bucket: "audit",
+		Prefix: "deployments/"}
+	record := &DeploymentRecord{Namespace: "default", Name: "my-bucket", Status: "UPDATE_COMPLETE",
+		CompletedTime: time.Date(2026, 10, 16, 9, 30, 5, 0, time.UTC)}
+
+	if err := sink.RecordDeployment(context.TODO(), record); err != nil {
+		t.Fatal(err)
+	}
+	if len(s3Client.puts) != 1 {
+		t.Fatalf("expected the record written once, got %d", len(s3Client.puts))
+	}
+	put := s3Client.puts[0]
+	if aws.ToString(put.Bucket) != "audit" ||
+		aws.ToString(put.Key) != "deployments/default/my-bucket/20261016T093005Z-update_complete.json" {
+		t.Errorf("unexpected object %s/%s", aws.ToString(put.Bucket), aws.ToString(put.Key))
+	}
+	body, err := io.ReadAll(put.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := &DeploymentRecord{}
+	if err := json.Unmarshal(body, written); err != nil || !reflect.DeepEqual(written, record) {
+		t.Errorf("expected the record as JSON, got %s (%v)", body, err)
+	}
+}
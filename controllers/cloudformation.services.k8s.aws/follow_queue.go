@@ -0,0 +1,79 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"sync"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+)
+
+// FollowQueue hands the stacks submitted for following over to the StackFollower. Submissions of a stack already
+// pending are coalesced, keeping its latest submission in its original place, so bursts of reconciles of stacks in
+// progress queue each stack once and never block the reconciler.
+type FollowQueue struct {
+	lock    sync.Mutex
+	ready   *sync.Cond
+	pending map[string]*v1alpha1.Stack // StackID -> latest submission
+	order   []string
+}
+
+func NewFollowQueue() *FollowQueue {
+	q := &FollowQueue{pending: map[string]*v1alpha1.Stack{}}
+	q.ready = sync.NewCond(&q.lock)
+	return q
+}
+
+// Add submits the stack, replacing its submission still pending.
+func (q *FollowQueue) Add(stack *v1alpha1.Stack) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if _, pending := q.pending[stack.Status.StackID]; !pending {
+		q.order = append(q.order, stack.Status.StackID)
+	}
+	q.pending[stack.Status.StackID] = stack
+	q.ready.Signal()
+}
+
+// Get waits for the next stack submitted.
+func (q *FollowQueue) Get() *v1alpha1.Stack {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for len(q.order) == 0 {
+		q.ready.Wait()
+	}
+	stackId := q.order[0]
+	q.order = q.order[1:]
+	stack := q.pending[stackId]
+	delete(q.pending, stackId)
+	return stack
+}
+
+// Len is the number of stacks pending.
+func (q *FollowQueue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.order)
+}
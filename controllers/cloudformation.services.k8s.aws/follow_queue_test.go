@@ -0,0 +1,82 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func followedStack(stackId string, resourceVersion int) *v1alpha1.Stack {
+	return &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default",
+			ResourceVersion: fmt.Sprintf("%d", resourceVersion)},
+		Status: v1alpha1.StackStatus{StackID: stackId},
+	}
+}
+
+func TestFollowQueueCoalescesSubmissions(t *testing.T) {
+	q := NewFollowQueue()
+	for i := 1; i <= 5; i++ {
+		q.Add(followedStack(testStackID, i))
+	}
+	q.Add(followedStack("other", 1))
+	q.Add(followedStack(testStackID, 6))
+	if q.Len() != 2 {
+		t.Fatalf("expected the repeated submissions coalesced, got %d pending", q.Len())
+	}
+
+	first := q.Get()
+	if first.Status.StackID != testStackID || first.ResourceVersion != "6" {
+		t.Errorf("expected the latest submission of the first stack, got %s at %s", first.Status.StackID,
+			first.ResourceVersion)
+	}
+	if second := q.Get(); second.Status.StackID != "other" {
+		t.Errorf("expected the other stack next, got %s", second.Status.StackID)
+	}
+	if q.Len() != 0 {
+		t.Errorf("expected nothing pending, got %d", q.Len())
+	}
+}
+
+func TestFollowQueueGetWaits(t *testing.T) {
+	q := NewFollowQueue()
+	received := make(chan *v1alpha1.Stack)
+	go func() { received <- q.Get() }()
+
+	q.Add(followedStack(testStackID, 1))
+	select {
+	case stack := <-received:
+		if stack.Status.StackID != testStackID {
+			t.Errorf("unexpected stack %s", stack.Status.StackID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the waiting Get to receive the stack")
+	}
+}
@@ -26,6 +26,10 @@ package cloudformation_services_k8s_aws
 
 import (
 	"context"
+	coreerrors "errors"
+	"strings"
+	"unicode"
+
 	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
 	"github.com/go-logr/logr"
 	v1 "k8s.io/api/core/v1"
@@ -33,10 +37,20 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
+var ErrOutputsSecretNotControlled = coreerrors.New("outputs secret exists and is not controlled by the stack")
+
+const (
+	// Labels and annotation on the ConfigMap and Secret of the outputs, tracing their values back to the stack
+	sourceStackNameLabel      = "cloudformation.services.k8s.aws.cuppett.dev/stack-name"
+	sourceStackNamespaceLabel = "cloudformation.services.k8s.aws.cuppett.dev/stack-namespace"
+	sourceStackIDAnnotation   = "cloudformation.services.k8s.aws.cuppett.dev/stack-id"
+)
+
 type MapWriter struct {
 	client.Client
 	Log logr.Logger
@@ -45,6 +59,7 @@ type MapWriter struct {
 }
 
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch
 
 // Worker
 func (w *MapWriter) Worker() {
@@ -65,7 +80,8 @@ func (w *MapWriter) Worker() {
 		}
 
 		// Writing map outputs
-		m.Data = toBeMapped.Status.Outputs
+		m.Data = configMapOutputs(toBeMapped)
+		setSourceStack(m, toBeMapped)
 
 		// Setting the owner reference
 		err = controllerutil.SetControllerReference(toBeMapped, m, w.Scheme)
@@ -84,6 +100,16 @@ func (w *MapWriter) Worker() {
 		} else {
 			w.Log.Info("Map written", "Namespace", toBeMapped.Namespace, "Name", m.Name, "Stack ID", toBeMapped.Status.StackID)
 		}
+
+		// Writing the selected outputs to the secret, when requested
+		if toBeMapped.Spec.OutputsSecretRef != nil {
+			secretName := toBeMapped.Spec.OutputsSecretRef.Name
+			if err = w.writeSecret(toBeMapped); err != nil {
+				w.Log.Error(err, "Failed to create or update outputs secret.", "Namespace", toBeMapped.Namespace, "Name", secretName, "Stack ID", toBeMapped.Status.StackID)
+			} else {
+				w.Log.Info("Outputs secret written", "Namespace", toBeMapped.Namespace, "Name", secretName, "Stack ID", toBeMapped.Status.StackID)
+			}
+		}
 	}
 }
 
@@ -95,3 +121,122 @@ func (w *MapWriter) createMap(stack *v1alpha1.Stack) v1.ConfigMap {
 		},
 	}
 }
+
+// writeSecret writes the selected outputs of the stack to the referenced secret, refusing secrets it doesn't control.
+func (w *MapWriter) writeSecret(stack *v1alpha1.Stack) error {
+	ref := stack.Spec.OutputsSecretRef
+	secret := &v1.Secret{}
+	created := false
+	err := w.Client.Get(context.TODO(), types.NamespacedName{Namespace: stack.Namespace, Name: ref.Name}, secret)
+	if errors.IsNotFound(err) {
+		secret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ref.Name,
+				Namespace: stack.Namespace,
+			},
+			Type: v1.SecretTypeOpaque,
+		}
+		created = true
+	} else if err != nil {
+		return err
+	} else if !metav1.IsControlledBy(secret, stack) {
+		return ErrOutputsSecretNotControlled
+	}
+
+	secret.Data = selectOutputs(stack.Status.Outputs, ref.Outputs)
+	setSourceStack(secret, stack)
+	if err = controllerutil.SetControllerReference(stack, secret, w.Scheme); err != nil {
+		return err
+	}
+	if created {
+		return w.Client.Create(context.TODO(), secret)
+	}
+	return w.Client.Update(context.TODO(), secret)
+}
+
+// setSourceStack labels the object written from the outputs with the stack they come from, its stack ID annotated as
+// ARNs aren't valid label values. Names too long for a label value are only found in the owner reference.
+func setSourceStack(obj metav1.Object, stack *v1alpha1.Stack) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for key, value := range map[string]string{
+		sourceStackNameLabel:      stack.Name,
+		sourceStackNamespaceLabel: stack.Namespace,
+	} {
+		if len(validation.IsValidLabelValue(value)) == 0 {
+			labels[key] = value
+		}
+	}
+	obj.SetLabels(labels)
+
+	if stack.Status.StackID != "" {
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[sourceStackIDAnnotation] = stack.Status.StackID
+		obj.SetAnnotations(annotations)
+	}
+}
+
+// configMapOutputs keys the outputs of the stack as its OutputsConfigMapRef asks, as is by default.
+func configMapOutputs(stack *v1alpha1.Stack) map[string]string {
+	ref := stack.Spec.OutputsConfigMapRef
+	if ref == nil || (ref.KeyPrefix == "" && ref.KeyCase == "") {
+		return stack.Status.Outputs
+	}
+	data := make(map[string]string, len(stack.Status.Outputs))
+	for key, value := range stack.Status.Outputs {
+		switch ref.KeyCase {
+		case v1alpha1.OutputKeyCaseUpper:
+			key = strings.ToUpper(key)
+		case v1alpha1.OutputKeyCaseUpperSnake:
+			key = upperSnakeCase(key)
+		}
+		data[ref.KeyPrefix+key] = value
+	}
+	return data
+}
+
+// upperSnakeCase converts a PascalCase or camelCase output key to UPPER_SNAKE_CASE, keeping acronyms together (e.g.
+// DBEndpointAddress to DB_ENDPOINT_ADDRESS).
+func upperSnakeCase(key string) string {
+	runes := []rune(key)
+	var converted strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextLower) {
+				converted.WriteRune('_')
+			}
+		}
+		converted.WriteRune(unicode.ToUpper(r))
+	}
+	return converted.String()
+}
+
+// selectOutputs picks the selected outputs under their new keys, all outputs when none are selected.
+func selectOutputs(outputs map[string]string, selectors []v1alpha1.OutputSelector) map[string][]byte {
+	data := map[string][]byte{}
+	if len(selectors) == 0 {
+		for k, v := range outputs {
+			data[k] = []byte(v)
+		}
+		return data
+	}
+	for _, selector := range selectors {
+		value, found := outputs[selector.Output]
+		if !found {
+			continue
+		}
+		key := selector.Key
+		if key == "" {
+			key = selector.Output
+		}
+		data[key] = []byte(value)
+	}
+	return data
+}
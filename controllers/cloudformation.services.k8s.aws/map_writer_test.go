@@ -0,0 +1,205 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestOutputsSecretWritesSelectedOutputs(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", UID: "5a1b2c3d"},
+		Spec: v1alpha1.StackSpec{
+			OutputsSecretRef: &v1alpha1.OutputsSecretReference{
+				Name: "my-bucket-env",
+				Outputs: []v1alpha1.OutputSelector{
+					{Output: "BucketName", Key: "BUCKET_NAME"},
+					{Output: "BucketArn"},
+				},
+			},
+		},
+		Status: v1alpha1.StackStatus{Outputs: map[string]string{
+			"BucketName":     "my-bucket-1a2b3c",
+			"BucketArn":      "arn:aws:s3:::my-bucket-1a2b3c",
+			"InternalRoleId": "AROA1234",
+		}},
+	}
+	k8sClient := newFakeClient(instance)
+	writer := &MapWriter{Client: k8sClient, Log: logr.Discard(), Scheme: newTestScheme()}
+	if err := writer.writeSecret(instance); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := &v1.Secret{}
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: "my-bucket-env", Namespace: "default"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]byte{
+		"BUCKET_NAME": []byte("my-bucket-1a2b3c"),
+		"BucketArn":   []byte("arn:aws:s3:::my-bucket-1a2b3c"),
+	}
+	if !reflect.DeepEqual(secret.Data, expected) {
+		t.Errorf("expected only the selected outputs, got %v", secret.Data)
+	}
+	if !metav1.IsControlledBy(secret, instance) {
+		t.Error("expected the secret to be controlled by the stack")
+	}
+}
+
+func TestOutputsSecretNotControlledRefused(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", UID: "5a1b2c3d"},
+		Spec: v1alpha1.StackSpec{
+			OutputsSecretRef: &v1alpha1.OutputsSecretReference{Name: "database-credentials"},
+		},
+		Status: v1alpha1.StackStatus{Outputs: map[string]string{"BucketName": "my-bucket-1a2b3c"}},
+	}
+	existing := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "database-credentials", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	}
+	k8sClient := newFakeClient(instance, existing)
+	writer := &MapWriter{Client: k8sClient, Log: logr.Discard(), Scheme: newTestScheme()}
+	if err := writer.writeSecret(instance); err != ErrOutputsSecretNotControlled {
+		t.Fatalf("expected %v, got %v", ErrOutputsSecretNotControlled, err)
+	}
+}
+
+func TestOutputsTracedToStack(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", UID: "5a1b2c3d"},
+		Spec: v1alpha1.StackSpec{
+			OutputsSecretRef: &v1alpha1.OutputsSecretReference{Name: "my-bucket-env"},
+		},
+		Status: v1alpha1.StackStatus{StackID: testStackID, Outputs: map[string]string{"BucketName": "my-bucket-1a2b3c"}},
+	}
+	k8sClient := newFakeClient(instance)
+	writer := &MapWriter{Client: k8sClient, Log: logr.Discard(), Scheme: newTestScheme()}
+	if err := writer.writeSecret(instance); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := &v1.Secret{}
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: "my-bucket-env", Namespace: "default"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	if secret.Labels[sourceStackNameLabel] != "my-bucket" || secret.Labels[sourceStackNamespaceLabel] != "default" {
+		t.Errorf("expected the secret labeled with the stack, got %v", secret.Labels)
+	}
+	if secret.Annotations[sourceStackIDAnnotation] != testStackID {
+		t.Errorf("expected the secret annotated with the stack ID, got %v", secret.Annotations)
+	}
+}
+
+func TestSetSourceStack(t *testing.T) {
+	longName := strings.Repeat("a", 64)
+	tests := []struct {
+		name        string
+		stack       *v1alpha1.Stack
+		labels      map[string]string
+		annotations map[string]string
+	}{
+		{
+			name: "created stack",
+			stack: &v1alpha1.Stack{ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+				Status: v1alpha1.StackStatus{StackID: testStackID}},
+			labels: map[string]string{"app": "web", sourceStackNameLabel: "my-bucket",
+				sourceStackNamespaceLabel: "default"},
+			annotations: map[string]string{sourceStackIDAnnotation: testStackID},
+		},
+		{
+			name:   "no stack ID yet",
+			stack:  &v1alpha1.Stack{ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"}},
+			labels: map[string]string{"app": "web", sourceStackNameLabel: "my-bucket", sourceStackNamespaceLabel: "default"},
+		},
+		{
+			name:   "name too long for a label",
+			stack:  &v1alpha1.Stack{ObjectMeta: metav1.ObjectMeta{Name: longName, Namespace: "default"}},
+			labels: map[string]string{"app": "web", sourceStackNamespaceLabel: "default"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}}}
+			setSourceStack(m, tt.stack)
+			if !reflect.DeepEqual(m.Labels, tt.labels) {
+				t.Errorf("labels = %v, want %v", m.Labels, tt.labels)
+			}
+			if len(m.Annotations) != len(tt.annotations) || !reflect.DeepEqual(m.Annotations, tt.annotations) &&
+				len(tt.annotations) > 0 {
+				t.Errorf("annotations = %v, want %v", m.Annotations, tt.annotations)
+			}
+		})
+	}
+}
+
+func TestConfigMapOutputs(t *testing.T) {
+	outputs := map[string]string{"VpcId": "vpc-1", "DBEndpointAddress": "db.local", "Subnet1Id": "subnet-1"}
+	tests := []struct {
+		name string
+		ref  *v1alpha1.OutputsConfigMapReference
+		want map[string]string
+	}{
+		{
+			name: "as is",
+			want: outputs,
+		},
+		{
+			name: "prefixed",
+			ref:  &v1alpha1.OutputsConfigMapReference{KeyPrefix: "App"},
+			want: map[string]string{"AppVpcId": "vpc-1", "AppDBEndpointAddress": "db.local", "AppSubnet1Id": "subnet-1"},
+		},
+		{
+			name: "upper",
+			ref:  &v1alpha1.OutputsConfigMapReference{KeyCase: v1alpha1.OutputKeyCaseUpper},
+			want: map[string]string{"VPCID": "vpc-1", "DBENDPOINTADDRESS": "db.local", "SUBNET1ID": "subnet-1"},
+		},
+		{
+			name: "prefixed upper snake",
+			ref: &v1alpha1.OutputsConfigMapReference{KeyPrefix: "APP_",
+				KeyCase: v1alpha1.OutputKeyCaseUpperSnake},
+			want: map[string]string{"APP_VPC_ID": "vpc-1", "APP_DB_ENDPOINT_ADDRESS": "db.local",
+				"APP_SUBNET1_ID": "subnet-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := &v1alpha1.Stack{Spec: v1alpha1.StackSpec{OutputsConfigMapRef: tt.ref},
+				Status: v1alpha1.StackStatus{Outputs: outputs}}
+			if got := configMapOutputs(stack); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("configMapOutputs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
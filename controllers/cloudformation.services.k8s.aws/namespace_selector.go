@@ -0,0 +1,74 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// namespaceNotAllowed identifies Stacks in namespaces not matching the NamespaceSelector, recording the
+// NamespaceNotAllowed condition. Their stacks are neither created nor updated, deletions still go through.
+func (r *StackReconciler) namespaceNotAllowed(loop *StackLoop) (bool, error) {
+	if r.NamespaceSelector == nil {
+		return false, nil
+	}
+
+	namespace := &v1.Namespace{}
+	if err := r.Get(loop.ctx, types.NamespacedName{Name: loop.instance.Namespace}, namespace); err != nil {
+		loop.Log.Error(err, "Failed to get the namespace of the Stack")
+		return false, err
+	}
+	if r.NamespaceSelector.Matches(labels.Set(namespace.Labels)) {
+		if removeCondition(loop.instance, v1alpha1.ConditionNamespaceNotAllowed) {
+			return false, r.updateStatus(loop)
+		}
+		return false, nil
+	}
+
+	message := fmt.Sprintf("Namespace %s does not match the selector %s required to manage stacks",
+		loop.instance.Namespace, r.NamespaceSelector)
+	loop.Log.Info("Namespace not allowed to manage stacks", "selector", r.NamespaceSelector.String())
+	if setCondition(loop.instance, v1alpha1.ConditionNamespaceNotAllowed, metav1.ConditionTrue, "NamespaceNotSelected",
+		message) {
+		return true, r.updateStatus(loop)
+	}
+	return true, nil
+}
+
+// stacksOfNamespace maps a Namespace to its Stacks, reconciling them as its labels change.
+func (r *StackReconciler) stacksOfNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.stacksInNamespace(ctx, obj.GetName())
+}
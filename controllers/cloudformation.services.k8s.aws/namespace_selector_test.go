@@ -0,0 +1,87 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestNamespaceSelectorGatesStackCreation(t *testing.T) {
+	namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "team-a"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+	}
+	k8sClient := newFakeClient(namespace, instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	r.NamespaceSelector = labels.SelectorFromSet(labels.Set{"cloudformation": "allowed"})
+	name := types.NamespacedName{Name: "my-bucket", Namespace: "team-a"}
+
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 0 {
+		t.Fatalf("expected no stack created, got %d creates", len(cfn.createInputs))
+	}
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionNamespaceNotAllowed)
+	if condition == nil || condition.Reason != "NamespaceNotSelected" {
+		t.Fatalf("expected the NamespaceNotAllowed condition, got %v", updated.Status.Conditions)
+	}
+
+	// Labelling the namespace lets the stack through
+	namespace.Labels = map[string]string{"cloudformation": "allowed"}
+	if err := k8sClient.Update(context.TODO(), namespace); err != nil {
+		t.Fatal(err)
+	}
+	// The first pass adds the finalizer, the second creates the stack
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the stack created, got %d creates", len(cfn.createInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionNamespaceNotAllowed) != nil {
+		t.Errorf("expected the NamespaceNotAllowed condition removed, got %v", updated.Status.Conditions)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ConfigMap whose data are the default tags of the stacks in its namespace
+const namespaceTagsConfigMap = "stack-default-tags"
+
+// namespaceTags provides the default tags of the namespace of the Stack, none when the namespace has no
+// namespaceTagsConfigMap.
+func (r *StackReconciler) namespaceTags(loop *StackLoop) (map[string]string, error) {
+	configMap := &v1.ConfigMap{}
+	err := r.Get(loop.ctx, types.NamespacedName{Namespace: loop.instance.Namespace, Name: namespaceTagsConfigMap},
+		configMap)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		loop.Log.Error(err, "Failed to get the default tags of the namespace", "ConfigMap", namespaceTagsConfigMap)
+		return nil, err
+	}
+	return configMap.Data, nil
+}
+
+// namespaceLabelTags provides the tags of the Stack from the labels of its namespace, as mapped by NamespaceLabelTags.
+func (r *StackReconciler) namespaceLabelTags(loop *StackLoop) (map[string]string, error) {
+	if len(r.NamespaceLabelTags) == 0 {
+		return nil, nil
+	}
+	namespace := &v1.Namespace{}
+	if err := r.Get(loop.ctx, types.NamespacedName{Name: loop.instance.Namespace}, namespace); err != nil {
+		loop.Log.Error(err, "Failed to get the namespace of the Stack")
+		return nil, err
+	}
+	tags := map[string]string{}
+	for label, tag := range r.NamespaceLabelTags {
+		if value, ok := namespace.Labels[label]; ok {
+			tags[tag] = value
+		}
+	}
+	return tags, nil
+}
+
+// stacksTaggedBy maps the namespaceTagsConfigMap to the Stacks of its namespace, reconciling them when the default
+// tags change.
+func (r *StackReconciler) stacksTaggedBy(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetName() != namespaceTagsConfigMap {
+		return nil
+	}
+	return r.stacksInNamespace(ctx, obj.GetNamespace())
+}
+
+// stacksInNamespace lists the Stacks of the namespace as requests to reconcile them.
+func (r *StackReconciler) stacksInNamespace(ctx context.Context, namespace string) []reconcile.Request {
+	list := &v1alpha1.StackList{}
+	if err := r.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		r.Log.Error(err, "Failed to list the Stacks of the namespace", "Namespace", namespace)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, stack := range list.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: stack.Namespace, Name: stack.Name},
+		})
+	}
+	return requests
+}
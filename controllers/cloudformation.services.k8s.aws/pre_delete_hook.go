@@ -0,0 +1,89 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"time"
+)
+
+const (
+	// Delay before running pre-delete hooks again while any are still working
+	preDeleteRecheckInterval = 10 * time.Second
+)
+
+// PreDeleteHook performs cleanup before the CloudFormation stack of a Stack is deleted (e.g. emptying an S3 bucket
+// managed by the stack). Hooks run in the order registered and the stack is only deleted once all of them report
+// done. Hooks should bound the work done per call and report not done to be called again on a later pass. Hooks may
+// surface their progress as conditions on the Stack, which are persisted after the hooks run.
+type PreDeleteHook interface {
+	// Name identifies the hook in logs
+	Name() string
+	// BeforeDelete performs (some of) the cleanup, reporting whether it is complete
+	BeforeDelete(ctx context.Context, instance *v1alpha1.Stack) (bool, error)
+}
+
+// runPreDeleteHooks runs the registered hooks in order, reporting whether all are done and the stack may be deleted.
+func (r *StackReconciler) runPreDeleteHooks(loop *StackLoop) (bool, error) {
+	if r.DryRun || len(r.PreDeleteHooks) == 0 {
+		return true, nil
+	}
+
+	// Once the delete is submitted, the hooks have already completed
+	if loop.instance.Status.StackStatus == "DELETE_IN_PROGRESS" {
+		return true, nil
+	}
+
+	hasOwnership, err := r.hasOwnership(loop)
+	if err != nil || !hasOwnership {
+		return true, err
+	}
+
+	conditions := make([]metav1.Condition, len(loop.instance.Status.Conditions))
+	copy(conditions, loop.instance.Status.Conditions)
+
+	done := true
+	for _, hook := range r.PreDeleteHooks {
+		done, err = hook.BeforeDelete(loop.ctx, loop.instance)
+		if err != nil {
+			loop.Log.Error(err, "Pre-delete hook failed", "hook", hook.Name())
+			break
+		}
+		if !done {
+			loop.Log.Info("Waiting on pre-delete hook", "hook", hook.Name())
+			break
+		}
+	}
+
+	if !reflect.DeepEqual(conditions, loop.instance.Status.Conditions) {
+		if updateErr := r.updateStatus(loop); updateErr != nil && err == nil {
+			err = updateErr
+		}
+	}
+	return done && err == nil, err
+}
@@ -0,0 +1,86 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// countingHook reports done once it has been called the given number of times
+type countingHook struct {
+	calls  int
+	passes int
+}
+
+func (h *countingHook) Name() string {
+	return "counting"
+}
+
+func (h *countingHook) BeforeDelete(_ context.Context, _ *v1alpha1.Stack) (bool, error) {
+	h.calls++
+	return h.calls >= h.passes, nil
+}
+
+func TestPreDeleteHooksCompleteBeforeDelete(t *testing.T) {
+	now := metav1.Now()
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", DeletionTimestamp: &now,
+			Finalizers: []string{stacksFinalizer}},
+		Spec:   v1alpha1.StackSpec{StackName: "my-bucket", Template: "Resources: {}"},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	hook := &countingHook{passes: 2}
+	r := newTestReconciler(k8sClient, cfn)
+	r.PreDeleteHooks = []PreDeleteHook{hook}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+	result, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter == 0 || len(cfn.deleteInputs) != 0 {
+		t.Fatalf("expected the delete to wait on the hook, got %v and %d deletes", result, len(cfn.deleteInputs))
+	}
+
+	if _, err = r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.deleteInputs) != 1 {
+		t.Errorf("expected the stack to be deleted once the hook is done, got %d deletes", len(cfn.deleteInputs))
+	}
+}
@@ -0,0 +1,144 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	coreerrors "errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+const (
+	s3BucketType = "AWS::S3::Bucket"
+	// Batches of (up to 1000) object versions deleted from a bucket on each pass
+	defaultS3DeleteBatches = 10
+)
+
+// S3EmptyBucketsHook is a PreDeleteHook emptying (all object versions and delete markers of) the S3 buckets created
+// by stacks with emptyS3BucketsOnDelete set, which would otherwise fail the stack deletion. Large buckets are emptied
+// over several passes, the progress reported in the EmptyingS3Buckets condition.
+type S3EmptyBucketsHook struct {
+	Log                  logr.Logger
+	CloudFormationHelper *CloudFormationHelper
+	// Batches deleted from each bucket per pass, defaults to 10
+	MaxBatches int
+}
+
+func (h *S3EmptyBucketsHook) Name() string {
+	return "s3-empty-buckets"
+}
+
+func (h *S3EmptyBucketsHook) BeforeDelete(ctx context.Context, instance *v1alpha1.Stack) (bool, error) {
+	if !instance.Spec.EmptyS3BucketsOnDelete || instance.Status.StackID == "" {
+		return true, nil
+	}
+	resources, err := h.CloudFormationHelper.GetStackResources(ctx, instance.Status.StackID)
+	if err != nil {
+		return false, err
+	}
+
+	deleted := 0
+	var remaining []string
+	for _, resource := range resources {
+		// Buckets retained on delete keep their objects
+		if resource.Type != s3BucketType || resource.PhysicalId == "" ||
+			instance.Spec.DeletionPolicy[resource.LogicalId] == v1alpha1.DeletionPolicyRetain {
+			continue
+		}
+		count, empty, err := h.emptyBucket(ctx, resource.PhysicalId)
+		deleted += count
+		if err != nil {
+			setCondition(instance, v1alpha1.ConditionEmptyingS3Buckets, metav1.ConditionFalse, "EmptyFailed",
+				fmt.Sprintf("Failed to empty bucket %s: %v", resource.PhysicalId, err))
+			return false, err
+		}
+		if !empty {
+			remaining = append(remaining, resource.PhysicalId)
+		}
+	}
+
+	if len(remaining) > 0 {
+		setCondition(instance, v1alpha1.ConditionEmptyingS3Buckets, metav1.ConditionTrue, "Emptying",
+			fmt.Sprintf("Deleted %d objects on the last pass, still emptying: %s", deleted,
+				strings.Join(remaining, ", ")))
+		return false, nil
+	}
+	removeCondition(instance, v1alpha1.ConditionEmptyingS3Buckets)
+	return true, nil
+}
+
+// emptyBucket deletes a bounded number of batches of object versions from the bucket, reporting the count deleted and
+// whether it is empty.
+func (h *S3EmptyBucketsHook) emptyBucket(ctx context.Context, bucket string) (int, bool, error) {
+	batches := h.MaxBatches
+	if batches <= 0 {
+		batches = defaultS3DeleteBatches
+	}
+
+	deleted := 0
+	for i := 0; i < batches; i++ {
+		// Deleted versions no longer list, so each batch starts from the beginning
+		output, err := h.CloudFormationHelper.GetS3().ListObjectVersions(ctx, &s3.ListObjectVersionsInput{
+			Bucket: aws.String(bucket),
+		})
+		if err != nil {
+			var apiErr smithy.APIError
+			if coreerrors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchBucket" {
+				return deleted, true, nil
+			}
+			return deleted, false, err
+		}
+
+		var objects []s3Types.ObjectIdentifier
+		for _, version := range output.Versions {
+			objects = append(objects, s3Types.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+		}
+		for _, marker := range output.DeleteMarkers {
+			objects = append(objects, s3Types.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+		}
+		if len(objects) == 0 {
+			return deleted, true, nil
+		}
+
+		h.Log.Info("Emptying S3 bucket", "bucket", bucket, "objects", len(objects))
+		_, err = h.CloudFormationHelper.GetS3().DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3Types.Delete{Objects: objects, Quiet: true},
+		})
+		if err != nil {
+			return deleted, false, err
+		}
+		deleted += len(objects)
+	}
+	return deleted, false, nil
+}
@@ -0,0 +1,142 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeS3 holds the object versions of each bucket and the entity tags of objects, recording the objects put
+type fakeS3 struct {
+	buckets map[string][]string
+	etags   map[string]string
+	puts    []*s3.PutObjectInput
+}
+
+func (f *fakeS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	etag, found := f.etags[*params.Bucket+"/"+*params.Key]
+	if !found {
+		return nil, &smithy.GenericAPIError{Code: "NotFound", Message: "Not Found"}
+	}
+	return &s3.HeadObjectOutput{ETag: aws.String(etag)}, nil
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.puts = append(f.puts, params)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	versions := f.buckets[*params.Bucket]
+	if len(versions) > 1000 {
+		versions = versions[:1000]
+	}
+	output := &s3.ListObjectVersionsOutput{}
+	for _, version := range versions {
+		output.Versions = append(output.Versions, s3Types.ObjectVersion{Key: aws.String(version),
+			VersionId: aws.String("v1")})
+	}
+	return output, nil
+}
+
+func (f *fakeS3) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	deleted := map[string]bool{}
+	for _, object := range params.Delete.Objects {
+		deleted[*object.Key] = true
+	}
+	var remaining []string
+	for _, version := range f.buckets[*params.Bucket] {
+		if !deleted[version] {
+			remaining = append(remaining, version)
+		}
+	}
+	f.buckets[*params.Bucket] = remaining
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func TestS3EmptyBucketsHookBoundedPasses(t *testing.T) {
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	cfn.resources[testStackID] = []cfTypes.StackResourceSummary{{
+		LogicalResourceId:  aws.String("Bucket"),
+		PhysicalResourceId: aws.String("my-bucket-1a2b3c"),
+		ResourceType:       aws.String(s3BucketType),
+		ResourceStatus:     cfTypes.ResourceStatusCreateComplete,
+	}}
+	objects := make([]string, 2500)
+	for i := range objects {
+		objects[i] = fmt.Sprintf("object-%d", i)
+	}
+	s3Client := &fakeS3{buckets: map[string][]string{"my-bucket-1a2b3c": objects}}
+	hook := &S3EmptyBucketsHook{
+		Log:                  logr.Discard(),
+		CloudFormationHelper: &CloudFormationHelper{CloudFormation: cfn, S3: s3Client},
+		MaxBatches:           2,
+	}
+
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{EmptyS3BucketsOnDelete: true},
+		Status:     v1alpha1.StackStatus{StackID: testStackID},
+	}
+
+	done, err := hook.BeforeDelete(context.TODO(), instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done || len(s3Client.buckets["my-bucket-1a2b3c"]) != 500 {
+		t.Fatalf("expected a bounded pass leaving 500 objects, got done=%v with %d", done,
+			len(s3Client.buckets["my-bucket-1a2b3c"]))
+	}
+	if !meta.IsStatusConditionTrue(instance.Status.Conditions, v1alpha1.ConditionEmptyingS3Buckets) {
+		t.Error("expected the EmptyingS3Buckets condition while objects remain")
+	}
+
+	if done, err = hook.BeforeDelete(context.TODO(), instance); err != nil || !done {
+		t.Fatalf("expected the bucket to be emptied on the second pass, got done=%v err=%v", done, err)
+	}
+	if meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionEmptyingS3Buckets) != nil {
+		t.Error("expected the EmptyingS3Buckets condition to be removed")
+	}
+
+	// Stacks which didn't opt in are left alone
+	instance.Spec.EmptyS3BucketsOnDelete = false
+	s3Client.buckets["my-bucket-1a2b3c"] = objects
+	if done, _ = hook.BeforeDelete(context.TODO(), instance); !done || len(s3Client.buckets["my-bucket-1a2b3c"]) != 2500 {
+		t.Error("expected buckets of stacks not opting in to be untouched")
+	}
+}
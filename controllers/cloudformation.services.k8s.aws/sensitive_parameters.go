@@ -0,0 +1,111 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"unsafe"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// secretReader reads the Secrets of sensitive parameters, bypassing the cache when possible.
+func (r *StackReconciler) secretReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// sensitiveSecretVersion checks a Secret sourcing a sensitive parameter holds the key, returning the version of the
+// Secret. The value itself is cleared without being kept.
+func (r *StackReconciler) sensitiveSecretVersion(loop *StackLoop, ref *v1.SecretKeySelector) (string, bool, error) {
+	secret := &v1.Secret{}
+	err := r.secretReader().Get(loop.ctx, types.NamespacedName{Namespace: loop.instance.Namespace, Name: ref.Name}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	defer clearSecret(secret)
+
+	_, found := secret.Data[ref.Key]
+	return secret.ResourceVersion, found, nil
+}
+
+// sensitiveParameters reads the values of the sensitive parameters just before the stack is submitted. The values
+// share the memory of the Secrets read, zeroed by the release function once the request is sent. This is best effort:
+// the SDK still copies the values into the request it serializes.
+func (r *StackReconciler) sensitiveParameters(loop *StackLoop) ([]cfTypes.Parameter, func(), error) {
+	var parameters []cfTypes.Parameter
+	var secrets []*v1.Secret
+	release := func() {
+		for _, secret := range secrets {
+			clearSecret(secret)
+		}
+	}
+
+	for _, source := range loop.instance.Spec.ParametersFrom {
+		if !source.Sensitive || source.SecretKeyRef == nil {
+			continue
+		}
+		secret := &v1.Secret{}
+		name := types.NamespacedName{Namespace: loop.instance.Namespace, Name: source.SecretKeyRef.Name}
+		if err := r.secretReader().Get(loop.ctx, name, secret); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			release()
+			loop.Log.Error(err, "Failed to get the Secret of a sensitive parameter", "parameter", source.Name)
+			return nil, func() {}, err
+		}
+		secrets = append(secrets, secret)
+
+		value, found := secret.Data[source.SecretKeyRef.Key]
+		if !found {
+			continue
+		}
+		parameters = append(parameters, cfTypes.Parameter{
+			ParameterKey:   aws.String(source.Name),
+			ParameterValue: aws.String(unsafe.String(unsafe.SliceData(value), len(value))),
+		})
+	}
+	return parameters, release, nil
+}
+
+// clearSecret zeroes the values of a Secret.
+func clearSecret(secret *v1.Secret) {
+	for _, value := range secret.Data {
+		clear(value)
+	}
+	for key := range secret.StringData {
+		delete(secret.StringData, key)
+	}
+}
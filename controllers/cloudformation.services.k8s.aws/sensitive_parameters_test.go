@@ -0,0 +1,106 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// sendingCloudFormation copies the parameters of each update as they are sent.
+type sendingCloudFormation struct {
+	*fakeCloudFormation
+	sent map[string]string
+}
+
+func (s *sendingCloudFormation) UpdateStack(ctx context.Context, params *cloudformation.UpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateStackOutput, error) {
+	for _, parameter := range params.Parameters {
+		s.sent[aws.ToString(parameter.ParameterKey)] = strings.Clone(aws.ToString(parameter.ParameterValue))
+	}
+	return s.fakeCloudFormation.UpdateStack(ctx, params, optFns...)
+}
+
+func TestSensitiveParametersReadAtSubmission(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{
+		StackName: "my-bucket",
+		Template:  testTemplate,
+		ParametersFrom: []v1alpha1.ParameterSource{{
+			Name: "DatabasePassword",
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "database"},
+				Key:                  "password",
+			},
+			Sensitive: true,
+		}},
+	})
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "database", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("s3cr3t")},
+	}
+	secrets := newFakeClient(secret)
+	r.APIReader = secrets
+	sending := &sendingCloudFormation{fakeCloudFormation: cfn, sent: map[string]string{}}
+	r.CloudFormationHelper.CloudFormation = sending
+
+	if resolved, err := r.resolveParameters(loop); err != nil || !resolved {
+		t.Fatalf("expected the parameters resolved, got %v (%v)", resolved, err)
+	}
+	if _, kept := loop.parameters["DatabasePassword"]; kept {
+		t.Fatal("expected the sensitive value not resolved ahead of the submission")
+	}
+	hash, err := r.appliedTemplateHash(loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+	if sending.sent["DatabasePassword"] != "s3cr3t" {
+		t.Fatalf("expected the Secret value submitted, got %q", sending.sent["DatabasePassword"])
+	}
+	if value := aws.ToString(cfn.updateInputs[0].Parameters[0].ParameterValue); strings.Trim(value, "\x00") != "" {
+		t.Errorf("expected the submitted value zeroed, got %q", value)
+	}
+
+	// Rotating the Secret changes the applied hash
+	secret.Data["password"] = []byte("n3w-s3cr3t")
+	if err := secrets.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.resolveParameters(loop); err != nil {
+		t.Fatal(err)
+	}
+	if rotated, _ := r.appliedTemplateHash(loop); rotated == hash {
+		t.Error("expected the rotated Secret to change the applied hash")
+	}
+}
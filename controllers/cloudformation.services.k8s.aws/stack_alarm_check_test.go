@@ -0,0 +1,144 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// fakeCloudWatch answers DescribeAlarms from the states of its metric and composite alarms, by name
+type fakeCloudWatch struct {
+	metric    map[string]cwTypes.StateValue
+	composite map[string]cwTypes.StateValue
+	describes int
+}
+
+func (f *fakeCloudWatch) DescribeAlarms(ctx context.Context, params *cloudwatch.DescribeAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DescribeAlarmsOutput, error) {
+	f.describes++
+	output := &cloudwatch.DescribeAlarmsOutput{}
+	for _, name := range params.AlarmNames {
+		if state, ok := f.metric[name]; ok {
+			output.MetricAlarms = append(output.MetricAlarms,
+				cwTypes.MetricAlarm{AlarmName: aws.String(name), StateValue: state})
+		}
+		if state, ok := f.composite[name]; ok {
+			output.CompositeAlarms = append(output.CompositeAlarms,
+				cwTypes.CompositeAlarm{AlarmName: aws.String(name), StateValue: state})
+		}
+	}
+	return output, nil
+}
+
+func TestGetAlarmsNotOK(t *testing.T) {
+	cw := &fakeCloudWatch{
+		metric:    map[string]cwTypes.StateValue{"errors": cwTypes.StateValueAlarm, "latency": cwTypes.StateValueOk},
+		composite: map[string]cwTypes.StateValue{"health": cwTypes.StateValueInsufficientData},
+	}
+	helper := &CloudFormationHelper{CloudWatch: cw}
+
+	notOK, err := helper.GetAlarmsNotOK(context.TODO(), []string{
+		"arn:aws:cloudwatch:us-east-1:123456789012:alarm:errors", "errors", "latency", "health", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"errors (ALARM)", "health (INSUFFICIENT_DATA)", "missing (not found)"}
+	if !reflect.DeepEqual(notOK, expected) {
+		t.Errorf("expected %v, got %v", expected, notOK)
+	}
+}
+
+func TestAlarmsNotOKDeferUpdate(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate,
+			PreUpdateAlarmCheck: []string{"errors"}},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	cw := &fakeCloudWatch{metric: map[string]cwTypes.StateValue{"errors": cwTypes.StateValueAlarm}}
+	r := newTestReconciler(k8sClient, cfn)
+	r.CloudFormationHelper.CloudWatch = cw
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	result, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 0 {
+		t.Fatalf("expected the update deferred while the alarm fires, got %d updates", len(cfn.updateInputs))
+	}
+	if result.RequeueAfter != alarmRecheckInterval {
+		t.Errorf("expected the alarms checked again in %v, got %v", alarmRecheckInterval, result.RequeueAfter)
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionBlockedByAlarm)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != "AlarmNotOK" {
+		t.Fatalf("expected the BlockedByAlarm condition, got %v", instance.Status.Conditions)
+	}
+
+	// Back to OK, the update goes ahead
+	cw.metric["errors"] = cwTypes.StateValueOk
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Fatalf("expected the stack updated once the alarm is OK, got %d updates", len(cfn.updateInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	condition = meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionBlockedByAlarm)
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != "AlarmsOK" {
+		t.Errorf("expected the BlockedByAlarm condition cleared, got %v", instance.Status.Conditions)
+	}
+
+	// Up to date, the alarms are not checked at all
+	describes := cw.describes
+	cw.metric["errors"] = cwTypes.StateValueAlarm
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if cw.describes != describes {
+		t.Errorf("expected no alarm check without an update due, got %d", cw.describes-describes)
+	}
+}
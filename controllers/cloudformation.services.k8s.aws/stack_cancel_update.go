@@ -0,0 +1,101 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Annotation requesting the update in progress be cancelled, removed once handled
+	cancelUpdateAnnotation = "cloudformation.services.k8s.aws.cuppett.dev/cancel-update"
+)
+
+// cancelRequestedUpdate cancels the update in progress when the cancel-update annotation is set, recording the
+// UpdateCancelled condition until the next operation. The annotation is removed once handled, not to cancel the
+// updates to come, including when there is no update to cancel.
+func (r *StackReconciler) cancelRequestedUpdate(loop *StackLoop) error {
+	if loop.instance.Annotations[cancelUpdateAnnotation] != "true" {
+		return nil
+	}
+
+	if loop.stack.StackStatus != cfTypes.StackStatusUpdateInProgress {
+		loop.Log.Info("No update in progress to cancel", "status", loop.stack.StackStatus)
+		r.Recorder.Eventf(loop.instance, v1.EventTypeWarning, "NoUpdateToCancel",
+			"Stack is %s, there is no update in progress to cancel", loop.stack.StackStatus)
+		return r.dropCancelUpdate(loop)
+	}
+
+	loop.Log.Info("Cancelling the update on request")
+	if !r.DryRun {
+		callCtx, cancel := r.CloudFormationHelper.callContextFor(loop.ctx, loop.instance)
+		_, err := r.CloudFormationHelper.CloudFormationFor(loop.instance).CancelUpdateStack(callCtx,
+			&cloudformation.CancelUpdateStackInput{StackName: loop.stack.StackId})
+		err = callError(loop.ctx, callCtx, err)
+		cancel()
+		if err != nil {
+			if !IsPermanentError(err) {
+				loop.Log.Error(err, "Failed to cancel the update")
+				return err
+			}
+			// The update finished in the meantime or can no longer be cancelled
+			r.Recorder.Eventf(loop.instance, v1.EventTypeWarning, "NoUpdateToCancel",
+				"The update could not be cancelled: %s", err.Error())
+			return r.dropCancelUpdate(loop)
+		}
+	}
+
+	message := "Update cancelled on request, rolling back"
+	if started := loop.stack.LastUpdatedTime; started != nil {
+		message = fmt.Sprintf("Update started at %s cancelled on request, rolling back",
+			aws.ToTime(started).UTC().Format(time.RFC3339))
+	}
+	r.Recorder.Event(loop.instance, v1.EventTypeNormal, v1alpha1.ConditionUpdateCancelled, message)
+	if setCondition(loop.instance, v1alpha1.ConditionUpdateCancelled, metav1.ConditionTrue, "CancelRequested",
+		message) {
+		if err := r.updateStatus(loop); err != nil {
+			return err
+		}
+	}
+	return r.dropCancelUpdate(loop)
+}
+
+// dropCancelUpdate removes the cancel-update annotation once the request was handled.
+func (r *StackReconciler) dropCancelUpdate(loop *StackLoop) error {
+	delete(loop.instance.Annotations, cancelUpdateAnnotation)
+	if err := r.Update(loop.ctx, loop.instance); err != nil {
+		loop.Log.Error(err, "Failed to remove the cancel-update annotation")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestCancelUpdateAnnotation(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    cfTypes.StackStatus
+		cancelled bool
+		event     string
+	}{
+		{"update in progress", cfTypes.StackStatusUpdateInProgress, true,
+			"Normal UpdateCancelled Update started at 2026-10-16T09:00:00Z cancelled on request, rolling back"},
+		{"nothing to cancel", cfTypes.StackStatusUpdateComplete, false,
+			"Warning NoUpdateToCancel Stack is UPDATE_COMPLETE, there is no update in progress to cancel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &v1alpha1.Stack{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default",
+					Finalizers:  []string{stacksFinalizer},
+					Annotations: map[string]string{cancelUpdateAnnotation: "true"}},
+				Spec:   v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+				Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: string(tt.status)},
+			}
+			k8sClient := newFakeClient(instance)
+			cfn := newFakeCloudFormation()
+			stack := cfn.addStack("my-bucket", testStackID, tt.status)
+			stack.Tags = []cfTypes.Tag{{Key: aws.String(controllerKey), Value: aws.String(controllerValue)}}
+			stack.LastUpdatedTime = aws.Time(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+			cfn.templates[testStackID] = testTemplate
+			r := newTestReconciler(k8sClient, cfn)
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+			if _, err := r.Reconcile(context.TODO(), req); err != nil {
+				t.Fatal(err)
+			}
+			if cancelled := len(cfn.cancelInputs) == 1; cancelled != tt.cancelled {
+				t.Fatalf("expected the update cancelled %v, got %d cancels", tt.cancelled, len(cfn.cancelInputs))
+			}
+			if tt.cancelled && aws.ToString(cfn.cancelInputs[0].StackName) != testStackID {
+				t.Errorf("expected the stack cancelled by ID, got %v", aws.ToString(cfn.cancelInputs[0].StackName))
+			}
+			if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+				t.Fatal(err)
+			}
+			if _, found := instance.Annotations[cancelUpdateAnnotation]; found {
+				t.Errorf("expected the annotation removed once handled, got %v", instance.Annotations)
+			}
+			condition := meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionUpdateCancelled)
+			if (condition != nil) != tt.cancelled {
+				t.Errorf("expected the UpdateCancelled condition %v, got %v", tt.cancelled, instance.Status.Conditions)
+			}
+			found := false
+			for len(r.Recorder.(*record.FakeRecorder).Events) > 0 {
+				if event := <-r.Recorder.(*record.FakeRecorder).Events; strings.HasPrefix(event, tt.event) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("expected the event %q", tt.event)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestCapabilityChangeReusesPreviousTemplate(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate,
+			Capabilities: []string{"CAPABILITY_IAM"}},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	stack.Tags = []cfTypes.Tag{{Key: aws.String(controllerKey), Value: aws.String(controllerValue)}}
+	stack.Capabilities = []cfTypes.Capability{cfTypes.CapabilityCapabilityIam}
+	cfn.templates[testStackID] = testTemplate
+	r := newTestReconciler(k8sClient, cfn)
+	name := types.NamespacedName{Name: "my-bucket", Namespace: "default"}
+
+	// Recording the applied template and inputs
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Fatalf("expected an update applying the spec, got %d updates", len(cfn.updateInputs))
+	}
+
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	updated.Spec.Capabilities = append(updated.Spec.Capabilities, "CAPABILITY_AUTO_EXPAND")
+	if err := k8sClient.Update(context.TODO(), updated); err != nil {
+		t.Fatal(err)
+	}
+	gets := cfn.templateGets
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfn.updateInputs) != 2 {
+		t.Fatalf("expected the capability change to update the stack, got %d updates", len(cfn.updateInputs))
+	}
+	input := cfn.updateInputs[1]
+	if !aws.ToBool(input.UsePreviousTemplate) || input.TemplateBody != nil || input.TemplateURL != nil {
+		t.Errorf("expected the previous template to be used, got body %v", input.TemplateBody)
+	}
+	if len(input.Capabilities) != 2 || input.Capabilities[1] != cfTypes.CapabilityCapabilityAutoExpand {
+		t.Errorf("expected CAPABILITY_AUTO_EXPAND to be submitted, got %v", input.Capabilities)
+	}
+	if cfn.templateGets != gets {
+		t.Errorf("expected the template not fetched, got %d fetches", cfn.templateGets-gets)
+	}
+}
+
+func TestCapabilityReorderIsNoChange(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate,
+			Capabilities: []string{"CAPABILITY_IAM", "CAPABILITY_AUTO_EXPAND"}},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	r := newTestReconciler(k8sClient, cfn)
+	name := types.NamespacedName{Name: "my-bucket", Namespace: "default"}
+
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	updated.Spec.Capabilities = []string{"CAPABILITY_AUTO_EXPAND", "CAPABILITY_IAM"}
+	if err := k8sClient.Update(context.TODO(), updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Errorf("expected the same capabilities in another order not to update the stack, got %d updates",
+			len(cfn.updateInputs))
+	}
+}
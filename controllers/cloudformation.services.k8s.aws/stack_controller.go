@@ -27,19 +27,32 @@ package cloudformation_services_k8s_aws
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	coreerrors "errors"
+	"fmt"
 	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"net/url"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"slices"
 	"strings"
+	"time"
 
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -53,10 +66,28 @@ const (
 	controllerValue = "cloudformation.services.k8s.aws.cuppett.dev/controller"
 	stacksFinalizer = "cloudformation.services.k8s.aws.cuppett.dev/finalizer"
 	ownerKey        = "kubernetes.io/owned-by"
+	instanceKey     = "kubernetes.io/controller-instance"
+	gitRevisionKey  = "kubernetes.io/git-revision"
+
+	// Default annotation carrying the Git revision deploying a stack
+	DefaultGitRevisionAnnotation = "cloudformation.services.k8s.aws.cuppett.dev/git-revision"
+
+	// Annotation confirming deletion of a stack with spec.preventDeletion
+	confirmDeletionAnnotation = "cloudformation.services.k8s.aws.cuppett.dev/confirm-deletion"
+
+	// How long to wait before checking the pre-update alarms again
+	alarmRecheckInterval = time.Minute
 )
 
 var (
 	ErrMissingTemplateSpec = coreerrors.New("template or templateUrl must be provided")
+
+	// Statuses of a healthy stack where the last applied template is in effect
+	upToDateStatuses = map[string]bool{
+		string(cfTypes.StackStatusCreateComplete): true,
+		string(cfTypes.StackStatusUpdateComplete): true,
+		string(cfTypes.StackStatusImportComplete): true,
+	}
 )
 
 // StackReconciler reconciles a Stack object
@@ -68,19 +99,64 @@ type StackReconciler struct {
 	WatchNamespaces      []string
 	CloudFormationHelper *CloudFormationHelper
 	DryRun               bool
+	// Identity of this installation of the controller, tagged on the stacks it creates or updates
+	InstanceID string
+	// Delay before rechecking a stack after submitting a create or update, zero to rely on the follower alone
+	SubmitRequeueAfter time.Duration
+	// Optional extension customizing the inputs before submission
+	InputMutator StackInputMutator
+	// Cleanup run in order before stacks are deleted
+	PreDeleteHooks []PreDeleteHook
+	Recorder       record.EventRecorder
+	// Annotation whose value (the deploying Git revision) is tagged on the stack
+	GitRevisionAnnotation string
+	// Failed deletions before a stack annotated for force deletion is forced, never forced when zero
+	ForceDeleteAttempts int
+	// Optional S3 staging of inline templates too large to submit directly
+	TemplateUploader *TemplateUploader
+	// Optional counters of the operations submitted
+	Metrics *StackMetrics
+	// Optional bound on the operations running in CloudFormation at once, shared with the StackFollower
+	OperationLimiter *OperationLimiter
+	// Optional uncached reader of the Secrets sourcing sensitive parameters, the client otherwise
+	APIReader client.Reader
+	// Optional selector of the namespaces (by label) allowed to create and update stacks
+	NamespaceSelector labels.Selector
+	// Optional namespace labels (keys) tagged on the stacks of the namespace, under the tag keys (values)
+	NamespaceLabelTags map[string]string
+	// Only validate the Stacks, reporting those broken in their status, never creating, updating or deleting stacks
+	ValidateOnly bool
+	// Validating only, also has CloudFormation validate the templates
+	ValidateTemplates bool
+	// Capabilities submitted for the stacks not listing their own, less those they disable
+	DefaultCapabilities []string
+	// Optional ordered list of regions to fail over to, named on stacks the region of which is unreachable
+	FailoverRegions []string
 }
 
 type StackLoop struct {
-	ctx      context.Context
-	req      ctrl.Request
-	instance *v1alpha1.Stack
-	stack    *cfTypes.Stack
-	Log      logr.Logger
+	ctx        context.Context
+	req        ctrl.Request
+	instance   *v1alpha1.Stack
+	stack      *cfTypes.Stack
+	parameters map[string]string
+	// Versions of the Secrets sourcing the sensitive parameters, by parameter name
+	sensitiveVersions map[string]string
+	submitted         bool
+	// Whether the spec is found already in effect on the stack, CloudFormation having nothing to update
+	upToDate bool
+	// Status last read or written, the base of the status patches
+	status v1alpha1.StackStatus
+	// Delay before retrying an operation which failed, the default backoff when zero
+	retryAfter time.Duration
+	Log        logr.Logger
 }
 
 // +kubebuilder:rbac:groups=cloudformation.services.k8s.aws.cuppett.dev,resources=stacks,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cloudformation.services.k8s.aws.cuppett.dev,resources=stacks/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cloudformation.services.k8s.aws.cuppett.dev,resources=stacks/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -88,8 +164,8 @@ type StackLoop struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.10.3/pkg/reconcile
 func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	loop := &StackLoop{ctx, req, &v1alpha1.Stack{}, nil,
-		log.FromContext(ctx).WithValues("Request.Namespace", req.Namespace, "Request.Name", req.Name)}
+	loop := &StackLoop{ctx: ctx, req: req, instance: &v1alpha1.Stack{},
+		Log: log.FromContext(ctx).WithValues("Request.Namespace", req.Namespace, "Request.Name", req.Name)}
 
 	// Fetch the Stack instance
 	err := r.Client.Get(loop.ctx, loop.req.NamespacedName, loop.instance)
@@ -97,11 +173,17 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		loop.Log.Error(err, "Failed to get Stack")
 		return ctrl.Result{}, err
 	}
+	loop.status = *loop.instance.Status.DeepCopy()
 
 	if loop.instance.Status.StackStatus != "" {
 		loop.Log = loop.Log.WithValues("stackName", loop.instance.Status.StackID)
 	}
 
+	// Auditing the Stacks, nothing else happens
+	if r.ValidateOnly {
+		return ctrl.Result{}, r.validateOnly(loop)
+	}
+
 	// Check if the Stack instance is marked to be deleted, which is
 	// indicated by the deletion timestamp being set.
 	isStackMarkedToBeDeleted := loop.instance.GetDeletionTimestamp() != nil
@@ -109,7 +191,9 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		if controllerutil.ContainsFinalizer(loop.instance, stacksFinalizer) {
 			// Remove stacksFinalizer. Once all finalizers have been
 			// removed, the object will be deleted.
-			if loop.instance.Status.StackStatus == "DELETE_COMPLETE" || loop.instance.Status.StackStatus == "" {
+			// Stacks in another region than the one configured are not reachable, they are left in place
+			if loop.instance.Status.StackStatus == "DELETE_COMPLETE" || loop.instance.Status.StackStatus == "" ||
+				r.leaveStackInOtherRegion(loop) {
 				controllerutil.RemoveFinalizer(loop.instance, stacksFinalizer)
 				err := r.Update(loop.ctx, loop.instance)
 				if err != nil {
@@ -118,10 +202,56 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 				}
 				loop.Log.Info("Successfully finalized stack")
 			} else {
+				// Protected stacks are held until the deletion is confirmed
+				if r.deletionBlocked(loop) {
+					return ctrl.Result{}, nil
+				}
+
+				// Deletions wait out maintenance windows
+				if after, err := r.deferredByMaintenance(loop); err != nil || after > 0 {
+					return ctrl.Result{RequeueAfter: after}, err
+				}
+
+				// Resources are retained (or not) as the template says, updating it with the spec overrides first
+				if applied, err := r.applyDeletionPolicies(loop); err != nil || !applied {
+					return ctrl.Result{RequeueAfter: deletionPolicyRecheckInterval}, err
+				}
+
+				// Pre-delete hooks must all complete before the stack is deleted
+				done, err := r.runPreDeleteHooks(loop)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+				if !done {
+					return ctrl.Result{RequeueAfter: preDeleteRecheckInterval}, nil
+				}
+
+				// Last chance warning of the stateful resources about to be destroyed
+				if loop.instance.Status.StackStatus != "DELETE_IN_PROGRESS" {
+					r.warnStatefulResources(loop)
+				}
+
+				if !r.acquireOperation(loop) {
+					return ctrl.Result{RequeueAfter: operationLimitRecheckInterval}, nil
+				}
+				defer r.releaseOperation(loop)
+
+				// Stacks stuck failing to delete may be forced
+				if loop.instance.Status.StackStatus == string(cfTypes.StackStatusDeleteFailed) {
+					handled, err := r.forceDelete(loop)
+					if err != nil {
+						loop.Log.Error(err, "Failed to force delete stack")
+						return ctrl.Result{}, err
+					}
+					if handled {
+						return ctrl.Result{}, nil
+					}
+				}
+
 				// Run finalization logic for stacksFinalizer. If the
 				// finalization logic fails, don't remove the finalizer so
 				// that we can retry during the next reconciliation.
-				err := r.deleteStack(loop)
+				err = r.deleteStack(loop, nil)
 				if err != nil {
 					loop.Log.Error(err, "Failed to delete stack")
 					return ctrl.Result{}, err
@@ -131,6 +261,26 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, nil
 	}
 
+	// Stacks created in another region than the one configured are not reachable
+	if mismatch, err := r.regionMismatch(loop); err != nil || mismatch {
+		return ctrl.Result{}, err
+	}
+
+	// Paused stacks are left alone until resumed, deletions still proceed
+	if isPaused, err := r.pausedStack(loop); err != nil || isPaused {
+		return ctrl.Result{}, err
+	}
+
+	// Only namespaces selected may create and update stacks
+	if notAllowed, err := r.namespaceNotAllowed(loop); err != nil || notAllowed {
+		return ctrl.Result{}, err
+	}
+
+	// Refusing the specs the webhook rejects, should it not be deployed
+	if invalid, err := r.invalidSpec(loop); err != nil || invalid {
+		return ctrl.Result{}, err
+	}
+
 	// Add finalizer for this CR
 	if !controllerutil.ContainsFinalizer(loop.instance, stacksFinalizer) {
 		controllerutil.AddFinalizer(loop.instance, stacksFinalizer)
@@ -138,27 +288,360 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, err
 	}
 
+	// Deleting the stack once it has outlived its TTL, otherwise checking back when it expires
+	result := ctrl.Result{}
+	if remaining, expiring := r.timeToLive(loop); expiring {
+		if remaining <= 0 {
+			return ctrl.Result{}, r.expireStack(loop)
+		}
+		result.RequeueAfter = remaining
+	}
+
 	exists, err := r.stackExists(loop)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
+	ownership := false
 	if exists {
-		ownership, _ := r.hasOwnership(loop)
+		ownership, _ = r.hasOwnership(loop)
 		if ownership {
 			// If the stack is in progress but not being followed, follow it to catch updates
 			// If it is being followed, we want the same thing, just send it over to the other thread to check it in all
 			// IN_PROGRESS cases.
+			// Cancelling the update in progress on request
+			if err := r.cancelRequestedUpdate(loop); err != nil {
+				return result, err
+			}
+
 			if !r.CloudFormationHelper.StackInTerminalState(loop.stack.StackStatus) {
-				r.ChannelHub.FollowChannel <- loop.instance
-				return ctrl.Result{}, nil
+				r.ChannelHub.FollowQueue.Add(loop.instance)
+				return result, nil
 			}
 
-			return reconcile.Result{}, r.updateStack(loop)
+			// Warning of changes to create-only fields, which the update can't apply
+			if err := r.onFailureChanged(loop); err != nil {
+				return result, err
+			}
 		}
 	}
 
-	return ctrl.Result{}, r.createStack(loop)
+	// Reading the body of a referenced Template, waiting until it exists
+	if resolved, err := r.resolveTemplateRef(loop); err != nil || !resolved {
+		return result, err
+	}
+
+	// Resolving parameters sourced from elsewhere in the cluster, waiting until all are available
+	resolved, err := r.resolveParameters(loop)
+	if err != nil || !resolved {
+		return result, err
+	}
+
+	// Holding stacks whose parameters break the policy of their schema
+	if violated, err := r.parametersViolateSchema(loop); err != nil || violated {
+		return result, err
+	}
+
+	// Checking back on tracked template URLs, whose content changes without the Stack resource changing
+	if loop.instance.Spec.TrackTemplateUrl && loop.instance.Spec.TemplateUrl != "" {
+		result = requeueAfter(result, templateUrlRecheckInterval)
+	}
+
+	appliedHash, err := r.appliedTemplateHash(loop)
+	if err != nil {
+		return result, err
+	}
+
+	// Stack policies are set on their own, without updating the stack
+	if ownership {
+		if err := r.applyStackPolicy(loop); err != nil {
+			return result, err
+		}
+	}
+
+	// A create which failed and was deleted is only retried once the spec changes
+	if !ownership && appliedHash == loop.instance.Status.LastAppliedTemplateHash &&
+		meta.IsStatusConditionTrue(loop.instance.Status.Conditions, v1alpha1.ConditionCreateFailed) {
+		loop.Log.Info("Stack failed to create, waiting on a change to the spec")
+		return result, nil
+	}
+
+	// An operation CloudFormation rejected outright fails the same way until the spec changes
+	if rejected(loop.instance) {
+		loop.Log.Info("Stack operation rejected, waiting on a change to the spec")
+		return result, nil
+	}
+
+	// A template too large to submit inline stays so until the spec changes, or the operator uploads large templates
+	if templateTooLarge(loop.instance) && r.TemplateUploader == nil {
+		loop.Log.Info("Template too large to submit inline, waiting on a change to the spec")
+		return result, nil
+	}
+
+	// Skipping the update when the healthy stack already has everything the spec asks for
+	if ownership && appliedHash == loop.instance.Status.LastAppliedTemplateHash &&
+		upToDateStatuses[loop.instance.Status.StackStatus] && !r.notificationsDrifted(loop) {
+		loop.Log.V(1).Info("Stack already up to date")
+		return result, nil
+	}
+
+	// Creates and updates wait out maintenance windows
+	if after, err := r.deferredByMaintenance(loop); err != nil || after > 0 {
+		return requeueAfter(result, after), err
+	}
+
+	// Updates are spaced by the minimum update interval of the stack
+	if ownership {
+		if after, err := r.updateRateLimited(loop); err != nil || after > 0 {
+			return requeueAfter(result, after), err
+		}
+
+		// Holding updates while any of the pre-update alarms are not OK, only checked with an update due
+		blocked, err := r.blockedByAlarms(loop)
+		if err != nil {
+			return result, err
+		}
+		if blocked {
+			return requeueAfter(result, alarmRecheckInterval), nil
+		}
+	}
+
+	if !r.acquireOperation(loop) {
+		return requeueAfter(result, operationLimitRecheckInterval), nil
+	}
+	defer r.releaseOperation(loop)
+
+	if ownership {
+		err = r.updateStack(loop)
+	} else {
+		err = r.createStack(loop)
+	}
+
+	if loop.retryAfter > 0 {
+		result = requeueAfter(result, loop.retryAfter)
+	}
+
+	// Recording what was submitted, or found already applied, not to submit it again
+	if err == nil && (loop.submitted || loop.upToDate) {
+		loop.instance.Status.LastAppliedTemplateHash = appliedHash
+		if loop.instance.Status.LastAppliedTemplateSourceHash, err = r.templateSourceHash(loop); err != nil {
+			return result, err
+		}
+		loop.instance.Status.TemplateVersionId = loop.instance.Spec.TemplateVersionId
+		r.recordAppliedTemplate(loop)
+		loop.instance.Status.FailureSummary = ""
+		removeCondition(loop.instance, v1alpha1.ConditionHookBlocked)
+		removeCondition(loop.instance, v1alpha1.ConditionInvalidServiceRole)
+		removeCondition(loop.instance, v1alpha1.ConditionCreateFailed)
+		removeCondition(loop.instance, v1alpha1.ConditionInvalidParameters)
+		removeCondition(loop.instance, v1alpha1.ConditionRejected)
+		removeCondition(loop.instance, v1alpha1.ConditionTemplateTooLarge)
+		removeCondition(loop.instance, v1alpha1.ConditionQuotaExceeded)
+		removeCondition(loop.instance, v1alpha1.ConditionUpdateCancelled)
+		removeCondition(loop.instance, v1alpha1.ConditionRegionUnavailable)
+		if err = r.updateStatus(loop); err != nil {
+			return result, err
+		}
+
+		// Rechecking shortly after submission in case the follower missed it
+		if loop.submitted && r.SubmitRequeueAfter > 0 {
+			result = requeueAfter(result, r.SubmitRequeueAfter)
+		}
+	}
+	return result, err
+}
+
+// notificationsDrifted identifies if the notification ARNs on the stack differ from those in the spec, e.g. changed
+// outside the operator.
+func (r *StackReconciler) notificationsDrifted(loop *StackLoop) bool {
+	if loop.stack == nil {
+		return false
+	}
+	live := slices.Clone(loop.stack.NotificationARNs)
+	desired := slices.Clone(loop.instance.Spec.NotificationArns)
+	slices.Sort(live)
+	slices.Sort(desired)
+	return !slices.Equal(live, desired)
+}
+
+// appliedTemplateHash computes a hash of the template and inputs the stack would be submitted with.
+func (r *StackReconciler) appliedTemplateHash(loop *StackLoop) (string, error) {
+	stackTags, err := r.stackTags(loop)
+	if err != nil {
+		loop.Log.Error(err, "Error compiling tags")
+		return "", err
+	}
+	tags := map[string]string{}
+	for _, tag := range stackTags {
+		if aws.ToString(tag.Key) == instanceKey {
+			// Tagged as the stacks are next submitted, rather than updating every existing stack to tag it
+			continue
+		}
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	// Capabilities are a set, listing them in another order changes nothing
+	capabilities := slices.Clone(r.requestedCapabilities(loop.instance))
+	slices.Sort(capabilities)
+
+	// Maps are marshalled with sorted keys, keeping the hash stable
+	inputs := map[string]interface{}{
+		"parameters":       loop.parameters,
+		"tags":             tags,
+		"capabilities":     capabilities,
+		"roleArn":          loop.instance.Spec.RoleARN,
+		"notificationArns": loop.instance.Spec.NotificationArns,
+	}
+	templateInputs, err := r.templateSourceInputsOf(loop)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range templateInputs {
+		inputs[k] = v
+	}
+	// Only part of the hash when set, leaving the hashes of existing stacks untouched
+	if loop.instance.Spec.InferCapabilities {
+		inputs["inferCapabilities"] = true
+	}
+	if len(loop.sensitiveVersions) > 0 {
+		// Changes to sensitive values are seen through the versions of their Secrets, the values are never hashed
+		inputs["sensitiveParameters"] = loop.sensitiveVersions
+	}
+	return hashInputs(inputs)
+}
+
+// templateSourceHash computes a hash of the template alone the stack would be submitted with.
+func (r *StackReconciler) templateSourceHash(loop *StackLoop) (string, error) {
+	templateInputs, err := r.templateSourceInputsOf(loop)
+	if err != nil {
+		return "", err
+	}
+	return hashInputs(templateInputs)
+}
+
+// templateSourceInputsOf provides the inputs identifying the template of the spec: its body or URL, with the version
+// or entity tag of the object behind the URL.
+func (r *StackReconciler) templateSourceInputsOf(loop *StackLoop) (map[string]interface{}, error) {
+	inputs := map[string]interface{}{
+		"template":    loop.instance.Spec.Template,
+		"templateUrl": loop.instance.Spec.TemplateUrl,
+	}
+	// Only part of the hash when set, leaving the hashes of existing stacks untouched
+	if loop.instance.Spec.TemplateVersionId != "" {
+		inputs["templateVersionId"] = loop.instance.Spec.TemplateVersionId
+	}
+	if loop.instance.Spec.TrackTemplateUrl && loop.instance.Spec.TemplateUrl != "" {
+		etag, err := r.templateUrlETag(loop)
+		if err != nil {
+			return nil, err
+		}
+		inputs["templateUrlETag"] = etag
+	}
+	return inputs, nil
+}
+
+// hashInputs hashes the inputs marshalled to JSON.
+func hashInputs(inputs map[string]interface{}) (string, error) {
+	marshalled, err := json.Marshal(inputs)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(marshalled)), nil
+}
+
+// deletionBlocked identifies if deletion of a protected stack still needs confirming, recording the DeletionBlocked
+// condition.
+func (r *StackReconciler) deletionBlocked(loop *StackLoop) bool {
+	if !loop.instance.Spec.PreventDeletion || loop.instance.Annotations[confirmDeletionAnnotation] == "true" {
+		if removeCondition(loop.instance, v1alpha1.ConditionDeletionBlocked) {
+			_ = r.updateStatus(loop)
+		}
+		return false
+	}
+
+	loop.Log.Info("Stack deletion blocked, waiting on confirmation", "annotation", confirmDeletionAnnotation)
+	if setCondition(loop.instance, v1alpha1.ConditionDeletionBlocked, metav1.ConditionTrue, "AwaitingConfirmation",
+		"Deletion is prevented until the "+confirmDeletionAnnotation+"=true annotation is set") {
+		_ = r.updateStatus(loop)
+	}
+	return true
+}
+
+// requeueAfter ensures the result requeues no later than the duration given.
+func requeueAfter(result ctrl.Result, after time.Duration) ctrl.Result {
+	if result.RequeueAfter == 0 || after < result.RequeueAfter {
+		result.RequeueAfter = after
+	}
+	return result
+}
+
+// updateStatus persists the status of the Stack being reconciled.
+func (r *StackReconciler) updateStatus(loop *StackLoop) error {
+	err := patchStatus(loop.ctx, r.Client, loop.instance, &loop.status)
+	if err != nil {
+		loop.Log.Error(err, "Failed to update Stack Status")
+		return err
+	}
+	loop.status = *loop.instance.Status.DeepCopy()
+	return nil
+}
+
+// blockedByAlarms checks the pre-update CloudWatch alarms, recording the BlockedByAlarm condition.
+func (r *StackReconciler) blockedByAlarms(loop *StackLoop) (bool, error) {
+	var changed bool
+	blocked := false
+
+	if len(loop.instance.Spec.PreUpdateAlarmCheck) == 0 {
+		changed = removeCondition(loop.instance, v1alpha1.ConditionBlockedByAlarm)
+	} else {
+		notOK, err := r.CloudFormationHelper.GetAlarmsNotOK(loop.ctx, loop.instance.Spec.PreUpdateAlarmCheck)
+		if err != nil {
+			loop.Log.Error(err, "Failed to check pre-update alarms")
+			return false, err
+		}
+		if len(notOK) > 0 {
+			blocked = true
+			loop.Log.Info("Update deferred, pre-update alarms are not OK", "alarms", notOK)
+			changed = setCondition(loop.instance, v1alpha1.ConditionBlockedByAlarm, metav1.ConditionTrue,
+				"AlarmNotOK", "Update deferred until alarms are OK: "+strings.Join(notOK, ", "))
+		} else {
+			changed = setCondition(loop.instance, v1alpha1.ConditionBlockedByAlarm, metav1.ConditionFalse,
+				"AlarmsOK", "All pre-update alarms are OK")
+		}
+	}
+
+	if changed {
+		if err := r.updateStatus(loop); err != nil {
+			return blocked, err
+		}
+	}
+	return blocked, nil
+}
+
+// timeToLive identifies how long a stack with a TTL has left before it expires.
+func (r *StackReconciler) timeToLive(loop *StackLoop) (time.Duration, bool) {
+	if loop.instance.Spec.TTL == nil || loop.instance.Status.CreatedTime == nil {
+		return 0, false
+	}
+	expiration := loop.instance.Status.CreatedTime.Add(loop.instance.Spec.TTL.Duration)
+	return time.Until(expiration), true
+}
+
+// expireStack deletes the Stack resource, the finalizer then takes care of the CloudFormation stack.
+func (r *StackReconciler) expireStack(loop *StackLoop) error {
+	loop.Log.Info("Stack TTL expired, deleting", "ttl", loop.instance.Spec.TTL.Duration)
+
+	if r.DryRun {
+		loop.Log.Info("Skipping expired stack deletion")
+		return nil
+	}
+
+	err := r.Delete(loop.ctx, loop.instance)
+	if err != nil && !errors.IsNotFound(err) {
+		loop.Log.Error(err, "Failed to delete expired stack")
+		return err
+	}
+	return nil
 }
 
 func (r *StackReconciler) createStack(loop *StackLoop) error {
@@ -178,15 +661,11 @@ func (r *StackReconciler) createStack(loop *StackLoop) error {
 	stackName := r.CloudFormationHelper.GetStackName(loop.ctx, loop.instance, false)
 	loop.Log = loop.Log.WithValues("stackName", stackName)
 
-	capabilities := make([]cfTypes.Capability, len(loop.instance.Spec.Capabilities))
-	for i, x := range loop.instance.Spec.Capabilities {
-		capabilities[i] = cfTypes.Capability(x)
-	}
 	input := &cloudformation.CreateStackInput{
-		Capabilities: capabilities,
-		StackName:    aws.String(stackName),
-		Parameters:   r.stackParameters(loop),
-		Tags:         stackTags,
+		StackName:          aws.String(stackName),
+		Parameters:         r.stackParameters(loop),
+		Tags:               stackTags,
+		ClientRequestToken: aws.String(operationToken(loop, "create")),
 	}
 
 	if loop.instance.Spec.RoleARN != "" {
@@ -200,24 +679,76 @@ func (r *StackReconciler) createStack(loop *StackLoop) error {
 		return ErrMissingTemplateSpec
 	}
 
-	if loop.instance.Spec.Template != "" {
-		input.TemplateBody = aws.String(loop.instance.Spec.Template)
-	} else {
-		input.TemplateURL = aws.String(loop.instance.Spec.TemplateUrl)
+	if input.TemplateBody, input.TemplateURL, err = r.templateSource(loop); err != nil {
+		return err
+	}
+
+	if input.Capabilities, err = r.stackCapabilities(loop, input.TemplateBody, input.TemplateURL, stackName); err != nil {
+		return err
 	}
 
 	if loop.instance.Spec.OnFailure != "" {
 		input.OnFailure = cfTypes.OnFailure(loop.instance.Spec.OnFailure)
 	}
 
-	output, err := r.CloudFormationHelper.GetCloudFormation().CreateStack(loop.ctx, input)
+	if loop.instance.Spec.StackPolicy != "" {
+		input.StackPolicyBody = aws.String(loop.instance.Spec.StackPolicy)
+	} else if loop.instance.Spec.StackPolicyUrl != "" {
+		input.StackPolicyURL = aws.String(loop.instance.Spec.StackPolicyUrl)
+	}
+
+	if r.InputMutator != nil {
+		if err := r.InputMutator.MutateCreateStackInput(loop.ctx, loop.instance, input); err != nil {
+			loop.Log.Error(err, "Failed to mutate create stack input")
+			return err
+		}
+	}
+
+	sensitive, release, err := r.sensitiveParameters(loop)
 	if err != nil {
 		return err
 	}
+	input.Parameters = append(input.Parameters, sensitive...)
+	callCtx, cancel := r.CloudFormationHelper.callContextFor(loop.ctx, loop.instance)
+	output, err := r.CloudFormationHelper.CloudFormationFor(loop.instance).CreateStack(callCtx, input)
+	err = callError(loop.ctx, callCtx, err)
+	cancel()
+	release()
+	r.Metrics.ObserveOperation(loop.instance, "create", err)
+	if IsCallTimeout(err) {
+		// Not knowing whether the stack was created, the retry submits the same token
+		r.submissionTimedOut(loop, aws.ToString(input.ClientRequestToken))
+		return err
+	}
+	loop.instance.Status.PendingOperationToken = ""
+	if err != nil {
+		r.recordFailureSummary(loop, OperationFailureSummary("CreateStack", err))
+		r.regionUnavailable(loop, err)
+		if r.quotaExceeded(loop, err) || r.recordOperationFailure(loop, err) {
+			// Retrying right away won't help, be it the stack, its role or the account limits to fix
+			return nil
+		}
+		return err
+	}
 	loop.instance.Status.StackID = *output.StackId
+	loop.instance.Status.CurrentOperationToken = aws.ToString(input.ClientRequestToken)
+	loop.instance.Status.AppliedStackPolicyHash = stackPolicyHash(loop.instance)
+	loop.submitted = true
 
-	r.ChannelHub.FollowChannel <- loop.instance
-	return nil
+	// Recording the stack ID right away, a restart before the status is next written would create the stack again
+	err = r.persistStackID(loop)
+	r.ChannelHub.FollowQueue.Add(loop.instance)
+	return err
+}
+
+// persistStackID writes the stack ID of the stack just created to the status. Being patched, it is written even should
+// the resource have changed in the meantime.
+func (r *StackReconciler) persistStackID(loop *StackLoop) error {
+	err := r.updateStatus(loop)
+	if err != nil {
+		loop.Log.Error(err, "Failed to record the stack ID", "stackID", loop.instance.Status.StackID)
+	}
+	return err
 }
 
 func (r *StackReconciler) updateStack(loop *StackLoop) error {
@@ -237,48 +768,251 @@ func (r *StackReconciler) updateStack(loop *StackLoop) error {
 	stackName := r.CloudFormationHelper.GetStackName(loop.ctx, loop.instance, true)
 	loop.Log = loop.Log.WithValues("stackName", stackName)
 
-	capabilities := make([]cfTypes.Capability, len(loop.instance.Spec.Capabilities))
-	for i, x := range loop.instance.Spec.Capabilities {
-		capabilities[i] = cfTypes.Capability(x)
-	}
 	input := &cloudformation.UpdateStackInput{
-		Capabilities: capabilities,
-		StackName:    aws.String(stackName),
-		Parameters:   r.stackParameters(loop),
-		Tags:         stackTags,
+		StackName:          aws.String(stackName),
+		Parameters:         r.stackParameters(loop),
+		Tags:               stackTags,
+		ClientRequestToken: aws.String(operationToken(loop, "update")),
 	}
 
 	input.NotificationARNs = loop.instance.Spec.NotificationArns
+	if len(input.NotificationARNs) == 0 && r.notificationsDrifted(loop) {
+		// Leaving the notification ARNs out keeps those on the stack, an empty list removes them
+		input.NotificationARNs = []string{}
+	}
 
 	if loop.instance.Spec.RoleARN != "" {
 		input.RoleARN = aws.String(loop.instance.Spec.RoleARN)
 	}
 
 	if loop.instance.Spec.Template == "" && loop.instance.Spec.TemplateUrl == "" {
-		loop.Log.Error(ErrMissingTemplateSpec, "Missing template spec")
-		return ErrMissingTemplateSpec
+		// Without a template given, the existing stack keeps its current template
+		loop.Log.Info("No template spec, using the previous template")
+		input.UsePreviousTemplate = aws.Bool(true)
+	} else if r.templateUnchanged(loop) {
+		loop.Log.Info("Template unchanged, using the previous template")
+		input.UsePreviousTemplate = aws.Bool(true)
+	} else if input.TemplateBody, input.TemplateURL, err = r.templateSource(loop); err != nil {
+		return err
 	}
 
-	if loop.instance.Spec.Template != "" {
-		input.TemplateBody = aws.String(loop.instance.Spec.Template)
-	} else {
-		input.TemplateURL = aws.String(loop.instance.Spec.TemplateUrl)
+	if input.Capabilities, err = r.stackCapabilities(loop, input.TemplateBody, input.TemplateURL, stackName); err != nil {
+		return err
 	}
 
-	if _, err := r.CloudFormationHelper.GetCloudFormation().UpdateStack(loop.ctx, input); err != nil {
-		if strings.Contains(err.Error(), "No updates are to be performed.") {
+	if r.InputMutator != nil {
+		if err := r.InputMutator.MutateUpdateStackInput(loop.ctx, loop.instance, input); err != nil {
+			loop.Log.Error(err, "Failed to mutate update stack input")
+			return err
+		}
+	}
+
+	// Retrying won't help until the parameters are given again or the template changed
+	if refused, err := r.refuseRemovedParameters(loop, input); err != nil || refused {
+		return err
+	}
+
+	sensitive, release, err := r.sensitiveParameters(loop)
+	if err != nil {
+		return err
+	}
+	input.Parameters = append(input.Parameters, sensitive...)
+	callCtx, cancel := r.CloudFormationHelper.callContextFor(loop.ctx, loop.instance)
+	_, updateErr := r.CloudFormationHelper.CloudFormationFor(loop.instance).UpdateStack(callCtx, input)
+	updateErr = callError(loop.ctx, callCtx, updateErr)
+	cancel()
+	release()
+	if IsCallTimeout(updateErr) {
+		// Not knowing whether the update was submitted, the stack is checked again and the retry submits the same token
+		r.Metrics.ObserveOperation(loop.instance, "update", updateErr)
+		r.submissionTimedOut(loop, aws.ToString(input.ClientRequestToken))
+		return updateErr
+	}
+	loop.instance.Status.PendingOperationToken = ""
+	if updateErr != nil {
+		if strings.Contains(updateErr.Error(), "No updates are to be performed.") {
 			loop.Log.Info("Stack already updated")
-		} else if strings.Contains(err.Error(), "does not exist") {
+			loop.upToDate = true
+		} else if strings.Contains(updateErr.Error(), "does not exist") {
 			loop.Log.Info("Stack does not exist in AWS. Re-creating it.")
 			return r.createStack(loop)
+		} else {
+			r.Metrics.ObserveOperation(loop.instance, "update", updateErr)
+			r.recordFailureSummary(loop, OperationFailureSummary("UpdateStack", updateErr))
+			r.regionUnavailable(loop, updateErr)
+			if r.quotaExceeded(loop, updateErr) {
+				return nil
+			}
+			if IsStackStateError(updateErr) {
+				// The stack is read afresh and followed until it settles
+				r.CloudFormationHelper.InvalidateStack(loop.instance.Status.StackID)
+				r.ChannelHub.FollowQueue.Add(loop.instance)
+			}
+			if IsTransientError(updateErr) {
+				// Retried with backoff
+				return updateErr
+			}
+			r.recordOperationFailure(loop, updateErr)
 		}
+	} else {
+		r.Metrics.ObserveOperation(loop.instance, "update", nil)
+		r.CloudFormationHelper.InvalidateStack(loop.instance.Status.StackID)
+		loop.instance.Status.CurrentOperationToken = aws.ToString(input.ClientRequestToken)
+		loop.submitted = true
 	}
 
-	r.ChannelHub.FollowChannel <- loop.instance
+	r.ChannelHub.FollowQueue.Add(loop.instance)
 	return err
 }
 
-func (r *StackReconciler) deleteStack(loop *StackLoop) error {
+// requestedCapabilities provides the capabilities the stack requests: those in the spec, replacing the defaults, or
+// the defaults less those the spec disables.
+func (r *StackReconciler) requestedCapabilities(instance *v1alpha1.Stack) []string {
+	if len(instance.Spec.Capabilities) > 0 {
+		return instance.Spec.Capabilities
+	}
+	var capabilities []string
+	for _, capability := range r.DefaultCapabilities {
+		if !slices.Contains(instance.Spec.DisableCapabilities, capability) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return capabilities
+}
+
+// stackCapabilities provides the capabilities to submit: those requested along with, with inferCapabilities, those
+// CloudFormation reports the template requires but the spec disables. Without a template body or URL, the template of
+// the stack is inspected.
+func (r *StackReconciler) stackCapabilities(loop *StackLoop, templateBody *string, templateURL *string,
+	stackName string) ([]cfTypes.Capability, error) {
+	requested := r.requestedCapabilities(loop.instance)
+	capabilities := make([]cfTypes.Capability, len(requested))
+	for i, x := range requested {
+		capabilities[i] = cfTypes.Capability(x)
+	}
+	if !loop.instance.Spec.InferCapabilities {
+		return capabilities, nil
+	}
+
+	input := &cloudformation.GetTemplateSummaryInput{TemplateBody: templateBody, TemplateURL: templateURL}
+	if templateBody == nil && templateURL == nil {
+		input.StackName = aws.String(stackName)
+	}
+	callCtx, cancel := r.CloudFormationHelper.callContextFor(loop.ctx, loop.instance)
+	summary, err := r.CloudFormationHelper.CloudFormationFor(loop.instance).GetTemplateSummary(callCtx, input)
+	err = callError(loop.ctx, callCtx, err)
+	cancel()
+	if err != nil {
+		loop.Log.Error(err, "Failed to infer the capabilities of the template")
+		return nil, err
+	}
+
+	inferred := make([]string, 0, len(summary.Capabilities))
+	for _, capability := range summary.Capabilities {
+		inferred = append(inferred, string(capability))
+		if !slices.Contains(capabilities, capability) &&
+			!slices.Contains(loop.instance.Spec.DisableCapabilities, string(capability)) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	if len(inferred) > 0 {
+		loop.Log.Info("Inferred the capabilities of the template", "capabilities", inferred,
+			"reason", aws.ToString(summary.CapabilitiesReason))
+	}
+	loop.instance.Status.InferredCapabilities = inferred
+	return capabilities, nil
+}
+
+// templateSource provides either the template body or the template URL to submit, uploading inline templates too
+// large for CloudFormation to accept directly when a TemplateUploader is configured.
+func (r *StackReconciler) templateSource(loop *StackLoop) (*string, *string, error) {
+	if loop.instance.Spec.Template == "" {
+		templateURL, err := versionedTemplateURL(loop.instance.Spec.TemplateUrl, loop.instance.Spec.TemplateVersionId)
+		if err != nil {
+			loop.Log.Error(err, "Invalid template URL")
+			return nil, nil, err
+		}
+		return nil, aws.String(templateURL), nil
+	}
+	if r.TemplateUploader == nil || !r.TemplateUploader.NeedsUpload(loop.instance.Spec.Template) {
+		r.Metrics.ObserveTemplate(loop.instance.Spec.Template, false)
+		return aws.String(loop.instance.Spec.Template), nil, nil
+	}
+
+	url, err := r.TemplateUploader.Upload(loop.ctx, loop.instance, loop.instance.Spec.Template)
+	if err != nil {
+		loop.Log.Error(err, "Failed to upload the template", "bucket", r.TemplateUploader.Bucket)
+		return nil, nil, err
+	}
+	loop.Log.Info("Uploaded template too large to submit inline", "templateUrl", url)
+	r.Metrics.ObserveTemplate(loop.instance.Spec.Template, true)
+	return nil, aws.String(url), nil
+}
+
+// versionedTemplateURL pins the template URL to the S3 object version given, if any.
+func versionedTemplateURL(templateURL string, versionId string) (string, error) {
+	if versionId == "" {
+		return templateURL, nil
+	}
+	parsed, err := url.Parse(templateURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	query.Set("versionId", versionId)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// recordOperationFailure surfaces errors retrying won't resolve as distinct conditions rather than generic reconcile
+// errors: a CloudFormation Hook rejecting the operation (HookBlocked, not a problem with the template itself), a
+// service role CloudFormation cannot use (InvalidServiceRole), an inline template over the size limit
+// (TemplateTooLarge) or any other request CloudFormation rejects outright (Rejected). Returns true when the error was one of these.
+func (r *StackReconciler) recordOperationFailure(loop *StackLoop, err error) bool {
+	var conditionType, reason, message string
+	switch {
+	case IsHookFailure(err):
+		conditionType, reason, message = v1alpha1.ConditionHookBlocked, "HookFailed", err.Error()
+	case IsServiceRoleFailure(err):
+		conditionType, reason = v1alpha1.ConditionInvalidServiceRole, "RoleNotUsable"
+		message = "The service role in roleArn must exist, trust cloudformation.amazonaws.com and be passable by " +
+			"the operator (iam:PassRole): " + err.Error()
+	case IsTemplateTooLarge(err):
+		conditionType, reason, message = v1alpha1.ConditionTemplateTooLarge, "TemplateBodyTooLarge",
+			r.templateTooLargeMessage(loop)
+	case IsPermanentError(err):
+		conditionType, reason, message = v1alpha1.ConditionRejected, errorCode(err), err.Error()
+	default:
+		return false
+	}
+
+	loop.Log.Info("Stack operation refused", "condition", conditionType, "reason", err.Error())
+	if setCondition(loop.instance, conditionType, metav1.ConditionTrue, reason, message) {
+		if err := r.updateStatus(loop); err != nil {
+			loop.Log.Error(err, "Failed to record the operation failure", "condition", conditionType)
+		}
+		r.Recorder.Event(loop.instance, v1.EventTypeWarning, conditionType, message)
+	}
+	return true
+}
+
+// templateUnchanged identifies if the template of the spec is the one last submitted to the healthy stack, whose
+// template can then be reused when only its tags, capabilities or other inputs change.
+func (r *StackReconciler) templateUnchanged(loop *StackLoop) bool {
+	// After a rollback, the template on the stack is no longer the one last submitted
+	if loop.stack == nil || loop.instance.Status.LastAppliedTemplateSourceHash == "" ||
+		!upToDateStatuses[string(loop.stack.StackStatus)] {
+		return false
+	}
+	hash, err := r.templateSourceHash(loop)
+	if err != nil {
+		loop.Log.Info("Unable to hash the template", "error", err)
+		return false
+	}
+	return hash == loop.instance.Status.LastAppliedTemplateSourceHash
+}
+
+func (r *StackReconciler) deleteStack(loop *StackLoop, retainResources []string) error {
 	loop.Log.Info("Deleting stack")
 
 	if r.DryRun {
@@ -296,15 +1030,47 @@ func (r *StackReconciler) deleteStack(loop *StackLoop) error {
 		return nil
 	}
 
+	// Retrying a failed deletion, the resources the spec retains can be retained explicitly
+	if loop.instance.Status.StackStatus == string(cfTypes.StackStatusDeleteFailed) {
+		for _, logicalId := range retainedResources(loop.instance) {
+			if !slices.Contains(retainResources, logicalId) {
+				retainResources = append(retainResources, logicalId)
+			}
+		}
+	}
+
 	input := &cloudformation.DeleteStackInput{
-		StackName: aws.String(r.CloudFormationHelper.GetStackName(loop.ctx, loop.instance, true)),
+		StackName:          aws.String(r.CloudFormationHelper.GetStackName(loop.ctx, loop.instance, true)),
+		RetainResources:    retainResources,
+		ClientRequestToken: aws.String(operationToken(loop, "delete")),
 	}
 
-	if _, err := r.CloudFormationHelper.GetCloudFormation().DeleteStack(loop.ctx, input); err != nil {
+	callCtx, cancel := r.CloudFormationHelper.callContextFor(loop.ctx, loop.instance)
+	_, err = r.CloudFormationHelper.CloudFormationFor(loop.instance).DeleteStack(callCtx, input)
+	err = callError(loop.ctx, callCtx, err)
+	cancel()
+	r.Metrics.ObserveOperation(loop.instance, "delete", err)
+	if err != nil {
+		r.regionUnavailable(loop, err)
+		if r.recordOperationFailure(loop, err) {
+			// Retrying won't help until the stack or its role are fixed
+			return nil
+		}
 		return err
 	}
+	r.CloudFormationHelper.InvalidateStack(loop.instance.Status.StackID)
+	loop.submitted = true
 
-	r.ChannelHub.FollowChannel <- loop.instance
+	// Recording the token of the deletion, and the retry of a failed deletion, counted again only once it fails again
+	loop.instance.Status.CurrentOperationToken = aws.ToString(input.ClientRequestToken)
+	if loop.instance.Status.StackStatus == string(cfTypes.StackStatusDeleteFailed) {
+		loop.instance.Status.StackStatus = string(cfTypes.StackStatusDeleteInProgress)
+	}
+	if err := r.updateStatus(loop); err != nil {
+		return err
+	}
+
+	r.ChannelHub.FollowQueue.Add(loop.instance)
 	return nil
 }
 
@@ -314,7 +1080,11 @@ func (r *StackReconciler) getStack(loop *StackLoop, noCache bool) (*cfTypes.Stac
 
 	if noCache || loop.stack == nil {
 		// Must use the stack ID to get details/finalization for deleted stacks
-		loop.stack, err = r.CloudFormationHelper.GetStack(loop.ctx, loop.instance)
+		if noCache {
+			loop.stack, err = r.CloudFormationHelper.GetStackNoCache(loop.ctx, loop.instance)
+		} else {
+			loop.stack, err = r.CloudFormationHelper.GetStack(loop.ctx, loop.instance)
+		}
 		if err != nil {
 			if strings.Contains(err.Error(), "does not exist") {
 				return nil, ErrStackNotFound
@@ -356,20 +1126,39 @@ func (r *StackReconciler) hasOwnership(loop *StackLoop) (bool, error) {
 		return false, err
 	}
 
+	controlled := false
+	owner := ""
 	for _, tag := range cfs.Tags {
-		if *tag.Key == controllerKey && *tag.Value == controllerValue {
-			return true, nil
+		switch aws.ToString(tag.Key) {
+		case controllerKey:
+			controlled = aws.ToString(tag.Value) == controllerValue
+		case ownerKey:
+			owner = aws.ToString(tag.Value)
 		}
 	}
+	if !controlled {
+		return false, nil
+	}
+	if owner == "" || owner == string(loop.instance.UID) {
+		return true, nil
+	}
 
-	return false, nil
+	// A Stack resource restored from backup comes back with a new UID. It still owns the stack when the stack carries
+	// the name it was given for the previous UID, the next update moves the owner tag over to the new UID.
+	previous := loop.instance.DeepCopy()
+	previous.UID = types.UID(owner)
+	if aws.ToString(cfs.StackName) != r.CloudFormationHelper.GetStackName(loop.ctx, previous, false) {
+		return false, nil
+	}
+	loop.Log.Info("Stack resource was recreated, adopting its stack", "previousUID", owner)
+	return true, nil
 }
 
-// stackParameters converts the parameters field on a Stack resource to CloudFormation Parameters.
+// stackParameters converts the resolved parameters of a Stack resource to CloudFormation Parameters.
 func (r *StackReconciler) stackParameters(loop *StackLoop) []cfTypes.Parameter {
 	var params []cfTypes.Parameter
-	if loop.instance.Spec.Parameters != nil {
-		for k, v := range loop.instance.Spec.Parameters {
+	if loop.parameters != nil {
+		for k, v := range loop.parameters {
 			params = append(params, cfTypes.Parameter{
 				ParameterKey:   aws.String(k),
 				ParameterValue: aws.String(v),
@@ -393,15 +1182,53 @@ func (r *StackReconciler) stackTags(loop *StackLoop) ([]cfTypes.Tag, error) {
 			Value: aws.String(string(loop.instance.UID)),
 		},
 	}
+	if r.InstanceID != "" {
+		tags = append(tags, cfTypes.Tag{
+			Key:   aws.String(instanceKey),
+			Value: aws.String(r.InstanceID),
+		})
+	}
 
-	// default tags
+	// default tags, those of the namespace (its labels, then its ConfigMap) taking precedence over the global ones and
+	// the Stack's over all
+	namespaceTags, err := r.namespaceTags(loop)
+	if err != nil {
+		return nil, err
+	}
+	labelTags, err := r.namespaceLabelTags(loop)
+	if err != nil {
+		return nil, err
+	}
+	defaultTags := map[string]string{}
 	for k, v := range r.CloudFormationHelper.ConfigReconciler.GetTags(loop.ctx) {
+		defaultTags[k] = v
+	}
+	for k, v := range labelTags {
+		defaultTags[k] = v
+	}
+	for k, v := range namespaceTags {
+		defaultTags[k] = v
+	}
+	for k, v := range defaultTags {
+		if _, overridden := loop.instance.Spec.Tags[k]; overridden {
+			continue
+		}
 		tags = append(tags, cfTypes.Tag{
 			Key:   aws.String(k),
 			Value: aws.String(v),
 		})
 	}
 
+	// Git revision deploying the Stack resource, when annotated
+	if r.GitRevisionAnnotation != "" {
+		if revision := loop.instance.Annotations[r.GitRevisionAnnotation]; revision != "" {
+			tags = append(tags, cfTypes.Tag{
+				Key:   aws.String(gitRevisionKey),
+				Value: aws.String(revision),
+			})
+		}
+	}
+
 	// tags specified on the Stack resource
 	if loop.instance.Spec.Tags != nil {
 		for k, v := range loop.instance.Spec.Tags {
@@ -417,27 +1244,59 @@ func (r *StackReconciler) stackTags(loop *StackLoop) ([]cfTypes.Tag, error) {
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *StackReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.Stack{}, stackRefIndex,
+		stackRefIndexer); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.Stack{}, configMapRefIndex,
+		configMapRefIndexer); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.Stack{}, secretRefIndex,
+		secretRefIndexer); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.Stack{}, templateRefIndex,
+		templateRefIndexer); err != nil {
+		return err
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr)
+	if r.NamespaceSelector != nil || len(r.NamespaceLabelTags) > 0 {
+		builder = builder.Watches(&v1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.stacksOfNamespace))
+	}
+	return builder.
 		For(&v1alpha1.Stack{}).
-		Owns(&v1.ConfigMap{}).
+		Watches(&v1alpha1.Stack{}, handler.EnqueueRequestsFromMapFunc(r.stacksReferencing(stackRefIndex))).
+		Watches(&v1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.stacksOfConfigMap)).
+		Watches(&v1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.stacksReferencing(secretRefIndex))).
+		Watches(&v1alpha1.Template{}, handler.EnqueueRequestsFromMapFunc(r.stacksReferencing(templateRefIndex))).
 		WithEventFilter(predicate.Funcs{
 			CreateFunc: func(e event.CreateEvent) bool {
-				return r.isWatchingNamespace(e.Object.GetNamespace())
+				return r.isWatchingNamespace(namespaceOf(e.Object))
 			},
 			UpdateFunc: func(e event.UpdateEvent) bool {
-				return r.isWatchingNamespace(e.ObjectOld.GetNamespace())
+				return r.isWatchingNamespace(namespaceOf(e.ObjectOld))
 			},
 			DeleteFunc: func(e event.DeleteEvent) bool {
 				// Ignoring these since we have a finalizer
 				return false
 			},
 			GenericFunc: func(e event.GenericEvent) bool {
-				return r.isWatchingNamespace(e.Object.GetNamespace())
+				return r.isWatchingNamespace(namespaceOf(e.Object))
 			},
 		}).
 		Complete(r)
 }
 
+// namespaceOf provides the namespace of the object, Namespaces being their own.
+func namespaceOf(obj client.Object) string {
+	if _, ok := obj.(*v1.Namespace); ok {
+		return obj.GetName()
+	}
+	return obj.GetNamespace()
+}
+
 func (r *StackReconciler) isWatchingNamespace(str string) bool {
 	for _, v := range r.WatchNamespaces {
 		if v == str {
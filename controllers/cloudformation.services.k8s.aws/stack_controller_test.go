@@ -0,0 +1,682 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	coreerrors "errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	servicesv1alpha1 "github.com/cuppett/aws-cloudformation-operator/apis/services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const testTemplate = "Resources:\n  Bucket:\n    Type: AWS::S3::Bucket\n"
+
+// newUpdateLoop prepares an existing stack deployed with testTemplate and the loop to update it to the spec given.
+func newUpdateLoop(t *testing.T, spec v1alpha1.StackSpec) (*StackReconciler, *fakeCloudFormation, *StackLoop) {
+	t.Helper()
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       spec,
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE",
+			LastAppliedTemplateSourceHash: templateSourceHashOf(t, testTemplate)},
+	}
+	cfn := newFakeCloudFormation()
+	stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	cfn.templates[testStackID] = testTemplate
+	r := newTestReconciler(newFakeClient(instance), cfn)
+	return r, cfn, &StackLoop{ctx: context.TODO(), instance: instance, stack: stack,
+		status: *instance.Status.DeepCopy(), Log: logr.Discard()}
+}
+
+// templateSourceHashOf provides the template source hash recorded once the inline template given is applied.
+func templateSourceHashOf(t *testing.T, template string) string {
+	t.Helper()
+	hash, err := hashInputs(map[string]interface{}{"template": template, "templateUrl": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestUpdateTagsOnlyUsesPreviousTemplate(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{
+		StackName: "my-bucket",
+		Template:  testTemplate,
+		Tags:      map[string]string{"team": "storage"},
+	})
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+
+	input := cfn.updateInputs[0]
+	if !aws.ToBool(input.UsePreviousTemplate) || input.TemplateBody != nil || input.TemplateURL != nil {
+		t.Errorf("expected the previous template to be used, got body %v", input.TemplateBody)
+	}
+	found := false
+	for _, tag := range input.Tags {
+		found = found || (aws.ToString(tag.Key) == "team" && aws.ToString(tag.Value) == "storage")
+	}
+	if !found {
+		t.Errorf("expected the new tag to be submitted, got %v", input.Tags)
+	}
+	if cfn.templateGets != 0 {
+		t.Errorf("expected the template not fetched, got %d fetches", cfn.templateGets)
+	}
+}
+
+func TestUpdateCapabilitiesOnlyUsesPreviousTemplate(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{
+		StackName:    "my-bucket",
+		Template:     testTemplate,
+		Capabilities: []string{"CAPABILITY_IAM"},
+	})
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+
+	input := cfn.updateInputs[0]
+	if !aws.ToBool(input.UsePreviousTemplate) || input.TemplateBody != nil {
+		t.Errorf("expected the previous template to be used, got body %v", input.TemplateBody)
+	}
+	if len(input.Capabilities) != 1 || input.Capabilities[0] != cfTypes.CapabilityCapabilityIam {
+		t.Errorf("expected CAPABILITY_IAM to be submitted, got %v", input.Capabilities)
+	}
+}
+
+func TestUpdateTemplateUrlTagsOnlyUsesPreviousTemplate(t *testing.T) {
+	const templateUrl = "https://my-templates.s3.amazonaws.com/bucket.yaml"
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", TemplateUrl: templateUrl})
+	hash, err := hashInputs(map[string]interface{}{"template": "", "templateUrl": templateUrl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop.instance.Status.LastAppliedTemplateSourceHash = hash
+	loop.instance.Spec.Tags = map[string]string{"team": "storage"}
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+
+	input := cfn.updateInputs[0]
+	if !aws.ToBool(input.UsePreviousTemplate) || input.TemplateBody != nil || input.TemplateURL != nil {
+		t.Errorf("expected the previous template to be used, got URL %v", input.TemplateURL)
+	}
+	if cfn.templateGets != 0 {
+		t.Errorf("expected the template not fetched, got %d fetches", cfn.templateGets)
+	}
+}
+
+func TestUpdateAfterRollbackSubmitsTemplate(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{
+		StackName: "my-bucket",
+		Template:  testTemplate,
+		Tags:      map[string]string{"team": "storage"},
+	})
+	// The template last submitted was rolled back, the stack has another
+	loop.stack.StackStatus = cfTypes.StackStatusUpdateRollbackComplete
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+
+	input := cfn.updateInputs[0]
+	if aws.ToBool(input.UsePreviousTemplate) || aws.ToString(input.TemplateBody) != testTemplate {
+		t.Errorf("expected the template to be submitted again, got %v", input.TemplateBody)
+	}
+}
+
+func TestUpdateChangedTemplateSubmitsBody(t *testing.T) {
+	changed := testTemplate + "  Queue:\n    Type: AWS::SQS::Queue\n"
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", Template: changed})
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+
+	input := cfn.updateInputs[0]
+	if aws.ToBool(input.UsePreviousTemplate) || aws.ToString(input.TemplateBody) != changed {
+		t.Errorf("expected the changed template to be submitted, got %v", input.TemplateBody)
+	}
+}
+
+func TestGitRevisionTagged(t *testing.T) {
+	r, _, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate})
+	r.GitRevisionAnnotation = DefaultGitRevisionAnnotation
+
+	hasRevision := func(tags []cfTypes.Tag) string {
+		for _, tag := range tags {
+			if aws.ToString(tag.Key) == gitRevisionKey {
+				return aws.ToString(tag.Value)
+			}
+		}
+		return ""
+	}
+
+	tags, _ := r.stackTags(loop)
+	if revision := hasRevision(tags); revision != "" {
+		t.Errorf("expected no revision tag without the annotation, got %s", revision)
+	}
+
+	loop.instance.Annotations = map[string]string{DefaultGitRevisionAnnotation: "4f2d9c1"}
+	tags, _ = r.stackTags(loop)
+	if revision := hasRevision(tags); revision != "4f2d9c1" {
+		t.Errorf("expected the annotated revision to be tagged, got %q", revision)
+	}
+}
+
+func TestInstanceTag(t *testing.T) {
+	r, _, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate})
+	untaggedHash, err := r.appliedTemplateHash(loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.InstanceID = "cluster-uid"
+	tags, err := r.stackTags(loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var instanceID string
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == instanceKey {
+			instanceID = aws.ToString(tag.Value)
+		}
+	}
+	if instanceID != "cluster-uid" {
+		t.Errorf("expected the stack tagged with the instance ID, got %q", instanceID)
+	}
+
+	// Existing stacks aren't all updated for the tag
+	if hash, err := r.appliedTemplateHash(loop); err != nil || hash != untaggedHash {
+		t.Errorf("expected the instance tag left out of the applied hash, got %v", err)
+	}
+}
+
+func TestUpdateSkippedWhenAlreadyApplied(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Fatalf("expected a single update while nothing changed, got %d", len(cfn.updateInputs))
+	}
+
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.LastAppliedTemplateHash == "" {
+		t.Fatal("expected the applied template hash to be recorded")
+	}
+
+	updated.Spec.Tags = map[string]string{"team": "storage"}
+	if err := k8sClient.Update(context.TODO(), updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 2 {
+		t.Errorf("expected the tag change to be submitted, got %d updates", len(cfn.updateInputs))
+	}
+}
+
+func TestNoUpdatesRecordsAppliedHash(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	cfn.updateErr = coreerrors.New("operation error CloudFormation: UpdateStack, api error ValidationError: " +
+		"No updates are to be performed.")
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Fatalf("expected the update not attempted again once found applied, got %d", len(cfn.updateInputs))
+	}
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.LastAppliedTemplateHash == "" {
+		t.Error("expected the applied template hash to be recorded")
+	}
+	if updated.Status.CurrentOperationToken != "" {
+		t.Errorf("expected no operation token without an operation, got %q", updated.Status.CurrentOperationToken)
+	}
+}
+
+func TestUpdateBlockedByHook(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate + "# changed\n"})
+	cfn.updateErr = coreerrors.New("operation error CloudFormation: UpdateStack, api error ValidationError: " +
+		"The following hook(s) failed: [MyCompany::Governance::BucketPolicy]")
+
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(loop.instance.Status.Conditions, v1alpha1.ConditionHookBlocked)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Fatalf("expected the HookBlocked condition, got %v", loop.instance.Status.Conditions)
+	}
+	if !strings.Contains(condition.Message, "MyCompany::Governance::BucketPolicy") {
+		t.Errorf("expected the hook failure message, got %s", condition.Message)
+	}
+
+	// Template problems are not attributed to hooks
+	cfn.updateErr = coreerrors.New("operation error CloudFormation: UpdateStack, api error ValidationError: " +
+		"Template format error: Unresolved resource dependencies [Webhook] in the Resources block of the template")
+	loop.instance.Status.Conditions = nil
+	_ = r.updateStack(loop)
+	if meta.FindStatusCondition(loop.instance.Status.Conditions, v1alpha1.ConditionHookBlocked) != nil {
+		t.Errorf("expected no HookBlocked condition for a template error")
+	}
+}
+
+func TestRestoredStackAdoptsOwnership(t *testing.T) {
+	// The Stack resource was restored from backup with its status, but under a new UID
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", UID: "restored-uid",
+			Finalizers: []string{stacksFinalizer}},
+		Spec:   v1alpha1.StackSpec{Template: testTemplate},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+
+	previous := instance.DeepCopy()
+	previous.UID = "original-uid"
+	stackName := r.CloudFormationHelper.GetStackName(context.TODO(), previous, false)
+	cfn.addStack(stackName, testStackID, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+		{Key: aws.String(ownerKey), Value: aws.String("original-uid")},
+	}
+	cfn.templates[testStackID] = testTemplate
+
+	loop := &StackLoop{ctx: context.TODO(), instance: instance, Log: logr.Discard()}
+	if owned, err := r.hasOwnership(loop); err != nil || !owned {
+		t.Fatalf("expected the restored resource to own the stack, got %v (%v)", owned, err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 0 || len(cfn.updateInputs) != 1 {
+		t.Fatalf("expected a single update, got %d creates and %d updates", len(cfn.createInputs),
+			len(cfn.updateInputs))
+	}
+	owner := ""
+	for _, tag := range cfn.updateInputs[0].Tags {
+		if aws.ToString(tag.Key) == ownerKey {
+			owner = aws.ToString(tag.Value)
+		}
+	}
+	if owner != "restored-uid" {
+		t.Errorf("expected the owner tag to move to the new UID, got %s", owner)
+	}
+
+	// A stack named for some other Stack resource is not adopted
+	other := instance.DeepCopy()
+	other.Name = "other-bucket"
+	loop = &StackLoop{ctx: context.TODO(), instance: other, Log: logr.Discard()}
+	if owned, _ := r.hasOwnership(loop); owned {
+		t.Error("expected a stack named for another resource not to be owned")
+	}
+}
+
+func TestCreateWithUnusableServiceRole(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate,
+			RoleARN: "arn:aws:iam::123456789012:role/cloudformation-deployer"},
+	}
+	cfn := newFakeCloudFormation()
+	cfn.createErr = coreerrors.New("operation error CloudFormation: CreateStack, api error ValidationError: " +
+		"Role arn:aws:iam::123456789012:role/cloudformation-deployer is invalid or cannot be assumed")
+	r := newTestReconciler(newFakeClient(instance), cfn)
+	loop := &StackLoop{ctx: context.TODO(), instance: instance, Log: logr.Discard()}
+
+	if err := r.createStack(loop); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionInvalidServiceRole)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != "RoleNotUsable" {
+		t.Fatalf("expected the InvalidServiceRole condition, got %v", instance.Status.Conditions)
+	}
+	if !strings.Contains(condition.Message, "iam:PassRole") {
+		t.Errorf("expected guidance in the message, got %s", condition.Message)
+	}
+	if meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionHookBlocked) != nil {
+		t.Error("expected no HookBlocked condition")
+	}
+}
+
+func TestUpdateWithoutTemplateUsesPreviousTemplate(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{
+		StackName:  "my-bucket",
+		Parameters: map[string]string{"VersioningStatus": "Suspended"},
+	})
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+	input := cfn.updateInputs[0]
+	if !aws.ToBool(input.UsePreviousTemplate) || input.TemplateBody != nil || input.TemplateURL != nil {
+		t.Errorf("expected the previous template to be kept, got body %v", input.TemplateBody)
+	}
+
+	// Creating still requires a template
+	if err := r.createStack(loop); err != ErrMissingTemplateSpec {
+		t.Errorf("expected %v, got %v", ErrMissingTemplateSpec, err)
+	}
+}
+
+func TestInferCapabilities(t *testing.T) {
+	changed := testTemplate + "  Role:\n    Type: AWS::IAM::Role\n"
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{
+		StackName:         "my-bucket",
+		Template:          changed,
+		Capabilities:      []string{"CAPABILITY_IAM"},
+		InferCapabilities: true,
+	})
+	cfn.requiredCapabilities = []cfTypes.Capability{cfTypes.CapabilityCapabilityNamedIam, cfTypes.CapabilityCapabilityIam}
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+
+	if aws.ToString(cfn.summaryInputs[0].TemplateBody) != changed {
+		t.Errorf("expected the submitted template to be summarized, got %v", cfn.summaryInputs[0])
+	}
+	capabilities := cfn.updateInputs[0].Capabilities
+	if len(capabilities) != 2 || capabilities[0] != cfTypes.CapabilityCapabilityIam ||
+		capabilities[1] != cfTypes.CapabilityCapabilityNamedIam {
+		t.Errorf("expected the given and inferred capabilities, got %v", capabilities)
+	}
+	if inferred := loop.instance.Status.InferredCapabilities; len(inferred) != 2 {
+		t.Errorf("expected the inferred capabilities recorded, got %v", inferred)
+	}
+
+	// Reusing the previous template, the template of the stack is summarized
+	loop.instance.Status.LastAppliedTemplateSourceHash = templateSourceHashOf(t, changed)
+	loop.instance.Spec.Tags = map[string]string{"team": "storage"}
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+	if input := cfn.summaryInputs[1]; aws.ToString(input.StackName) != testStackID || input.TemplateBody != nil {
+		t.Errorf("expected the stack template to be summarized, got %v", input)
+	}
+}
+
+func TestDefaultCapabilities(t *testing.T) {
+	defaults := []string{"CAPABILITY_IAM", "CAPABILITY_NAMED_IAM"}
+	tests := []struct {
+		name     string
+		spec     v1alpha1.StackSpec
+		expected []cfTypes.Capability
+	}{
+		{
+			name:     "defaults",
+			expected: []cfTypes.Capability{cfTypes.CapabilityCapabilityIam, cfTypes.CapabilityCapabilityNamedIam},
+		},
+		{
+			name:     "listed replace the defaults",
+			spec:     v1alpha1.StackSpec{Capabilities: []string{"CAPABILITY_AUTO_EXPAND"}},
+			expected: []cfTypes.Capability{cfTypes.CapabilityCapabilityAutoExpand},
+		},
+		{
+			name:     "disabled removed from the defaults",
+			spec:     v1alpha1.StackSpec{DisableCapabilities: []string{"CAPABILITY_NAMED_IAM"}},
+			expected: []cfTypes.Capability{cfTypes.CapabilityCapabilityIam},
+		},
+		{
+			name: "disabled not inferred",
+			spec: v1alpha1.StackSpec{DisableCapabilities: []string{"CAPABILITY_NAMED_IAM", "CAPABILITY_IAM"},
+				InferCapabilities: true},
+			expected: []cfTypes.Capability{cfTypes.CapabilityCapabilityAutoExpand},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			spec.StackName = "my-bucket"
+			spec.Template = testTemplate + "# changed\n"
+			r, cfn, loop := newUpdateLoop(t, spec)
+			r.DefaultCapabilities = defaults
+			cfn.requiredCapabilities = []cfTypes.Capability{cfTypes.CapabilityCapabilityNamedIam,
+				cfTypes.CapabilityCapabilityAutoExpand}
+			if err := r.updateStack(loop); err != nil {
+				t.Fatal(err)
+			}
+			if capabilities := cfn.updateInputs[0].Capabilities; !reflect.DeepEqual(capabilities, tt.expected) {
+				t.Errorf("expected %v submitted, got %v", tt.expected, capabilities)
+			}
+		})
+	}
+}
+
+func TestTemplateVersionId(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{
+		StackName:         "my-bucket",
+		TemplateUrl:       "https://my-bucket.s3.amazonaws.com/stack.yaml?region=us-east-1",
+		TemplateVersionId: "3HL4kqtJlcpXroDTDmJ",
+	})
+	previous, err := r.appliedTemplateHash(loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "https://my-bucket.s3.amazonaws.com/stack.yaml?region=us-east-1&versionId=3HL4kqtJlcpXroDTDmJ"
+	if templateURL := aws.ToString(cfn.updateInputs[0].TemplateURL); templateURL != expected {
+		t.Errorf("expected %s, got %s", expected, templateURL)
+	}
+
+	loop.instance.Spec.TemplateVersionId = "Wb2mq0t1T4QH9DqB"
+	if current, _ := r.appliedTemplateHash(loop); current == previous {
+		t.Error("expected a new template version to change the applied hash")
+	}
+}
+
+func TestNamespaceDefaultTags(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{Template: testTemplate, Tags: map[string]string{"team": "storage"}},
+	}
+	config := &servicesv1alpha1.Config{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec: servicesv1alpha1.ConfigSpec{Tags: map[string]string{
+			"cost-center": "platform", "team": "platform", "cluster": "prod1"}},
+	}
+	namespaceTags := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: namespaceTagsConfigMap, Namespace: "default"},
+		Data:       map[string]string{"cost-center": "storage-1234", "owner": "storage@example.com"},
+	}
+	r := newTestReconciler(newFakeClient(instance, config, namespaceTags), newFakeCloudFormation())
+	loop := &StackLoop{ctx: context.TODO(), instance: instance, Log: logr.Discard()}
+
+	tags, err := r.stackTags(loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]string{}
+	for _, tag := range tags {
+		if _, duplicate := found[aws.ToString(tag.Key)]; duplicate {
+			t.Errorf("duplicate tag %s", aws.ToString(tag.Key))
+		}
+		found[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	expected := map[string]string{"cost-center": "storage-1234", "owner": "storage@example.com", "team": "storage",
+		"cluster": "prod1"}
+	for k, v := range expected {
+		if found[k] != v {
+			t.Errorf("expected tag %s=%s, got %q", k, v, found[k])
+		}
+	}
+
+	// Changes to the default tags reconcile the Stacks of the namespace
+	if requests := r.stacksTaggedBy(context.TODO(), namespaceTags); len(requests) != 1 {
+		t.Errorf("expected the Stack of the namespace to be reconciled, got %v", requests)
+	}
+}
+
+func TestNamespaceLabelTags(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{Template: testTemplate, Tags: map[string]string{"Team": "storage"}},
+	}
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{
+			"team": "platform", "cost-center": "1234", "env": "prod"}},
+	}
+	r := newTestReconciler(newFakeClient(instance, namespace), newFakeCloudFormation())
+	r.NamespaceLabelTags = map[string]string{"team": "Team", "cost-center": "CostCenter", "owner": "Owner"}
+	loop := &StackLoop{ctx: context.TODO(), instance: instance, Log: logr.Discard()}
+
+	tags, err := r.stackTags(loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]string{}
+	for _, tag := range tags {
+		if _, duplicate := found[aws.ToString(tag.Key)]; duplicate {
+			t.Errorf("duplicate tag %s", aws.ToString(tag.Key))
+		}
+		found[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	// The spec tags take precedence, labels not mapped or missing are left out
+	expected := map[string]string{"Team": "storage", "CostCenter": "1234"}
+	for k, v := range expected {
+		if found[k] != v {
+			t.Errorf("expected tag %s=%s, got %q", k, v, found[k])
+		}
+	}
+	for _, k := range []string{"env", "Owner"} {
+		if _, ok := found[k]; ok {
+			t.Errorf("expected no tag %s, got %v", k, found)
+		}
+	}
+}
+
+func TestNotificationArnsPropagate(t *testing.T) {
+	const topic = "arn:aws:sns:us-east-1:123456789012:stack-events"
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate,
+			NotificationArns: []string{topic}},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE",
+			LastAppliedTemplateSourceHash: templateSourceHashOf(t, testTemplate)},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	stack.Tags = []cfTypes.Tag{{Key: aws.String(controllerKey), Value: aws.String(controllerValue)}}
+	cfn.templates[testStackID] = testTemplate
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Fatalf("expected the notification ARNs to be submitted, got %d updates", len(cfn.updateInputs))
+	}
+	input := cfn.updateInputs[0]
+	if !aws.ToBool(input.UsePreviousTemplate) || len(input.NotificationARNs) != 1 || input.NotificationARNs[0] != topic {
+		t.Errorf("expected the previous template with the notification ARNs, got %v", input.NotificationARNs)
+	}
+	stack.NotificationARNs = []string{topic}
+
+	// Already applied, nothing to do
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Fatalf("expected no update once applied, got %d updates", len(cfn.updateInputs))
+	}
+
+	// Topics changed outside the operator are put back
+	stack.NotificationARNs = []string{topic, "arn:aws:sns:us-east-1:123456789012:other"}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 2 || len(cfn.updateInputs[1].NotificationARNs) != 1 {
+		t.Fatalf("expected the drifted notification ARNs to be reverted, got %d updates", len(cfn.updateInputs))
+	}
+
+	// Even with none left in the spec
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	updated.Spec.NotificationArns = nil
+	if err := k8sClient.Update(context.TODO(), updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 3 {
+		t.Fatalf("expected the notification ARNs to be removed, got %d updates", len(cfn.updateInputs))
+	}
+	if arns := cfn.updateInputs[2].NotificationARNs; arns == nil || len(arns) != 0 {
+		t.Errorf("expected an empty list of notification ARNs, got %v", arns)
+	}
+}
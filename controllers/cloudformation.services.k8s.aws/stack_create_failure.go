@@ -0,0 +1,81 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// deletedOnFailure identifies stacks CloudFormation deleted after they failed to create (onFailure: DELETE), rather
+// than deleted along with their Stack resource.
+func deletedOnFailure(instance *v1alpha1.Stack, cfs *cfTypes.Stack) bool {
+	if cfs.StackStatus != cfTypes.StackStatusDeleteComplete || instance.DeletionTimestamp != nil ||
+		instance.Spec.OnFailure != string(cfTypes.OnFailureDelete) {
+		return false
+	}
+	for _, transition := range instance.Status.History {
+		if transition.Status == string(cfTypes.StackStatusCreateComplete) {
+			return false
+		}
+	}
+	return true
+}
+
+// clearFailedCreate forgets the stack deleted after failing to create, whose StackID no longer resolves, recording
+// the failure in the CreateFailed condition.
+func (f *StackFollower) clearFailedCreate(ctx context.Context, log logr.Logger, instance *v1alpha1.Stack,
+	cfs *cfTypes.Stack) error {
+	// The failure is reported along the deletion CloudFormation started
+	message := "The stack failed to create and was deleted"
+	if reason := aws.ToString(cfs.StackStatusReason); reason != "" {
+		message += ": " + reason
+	} else {
+		for i := len(instance.Status.History) - 1; i >= 0; i-- {
+			if reason := instance.Status.History[i].Reason; reason != "" {
+				message += ": " + reason
+				break
+			}
+		}
+	}
+	log.Info("Stack failed to create and was deleted", "reason", message)
+
+	base := instance.Status.DeepCopy()
+	instance.Status.StackID = ""
+	instance.Status.StackStatus = ""
+	instance.Status.Outputs = nil
+	instance.Status.Resources = nil
+	instance.Status.NestedStacks = nil
+	instance.Status.ResourceCount = 0
+	instance.Status.Progress = ""
+	setCondition(instance, v1alpha1.ConditionCreateFailed, metav1.ConditionTrue, "DeletedOnFailure", message)
+	setCondition(instance, v1alpha1.ConditionReady, metav1.ConditionFalse, "CreateFailed", message)
+	return patchStatus(ctx, f.Client, instance, base)
+}
@@ -0,0 +1,111 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestCreateFailureDeletedOnFailure(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate, OnFailure: "DELETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+	stack := &v1alpha1.Stack{}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the stack created, got %d creates", len(cfn.createInputs))
+	}
+
+	// CloudFormation deletes the stack failing to create
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	createdID := stack.Status.StackID
+	cfn.addStack("my-bucket", createdID, cfTypes.StackStatusDeleteComplete).StackStatusReason =
+		aws.String("The following resource(s) failed to create: [Bucket]. Delete requested by user.")
+	follower := newTestFollower(k8sClient, cfn)
+	follower.startFollowing(stack)
+	follower.mapPollingList.Range(follower.processStack)
+
+	if follower.beingFollowed(createdID) {
+		t.Error("expected the deleted stack no longer followed")
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if stack.Status.StackID != "" || stack.Status.StackStatus != "" {
+		t.Errorf("expected the stale stack ID cleared, got %s %s", stack.Status.StackID, stack.Status.StackStatus)
+	}
+	condition := meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionCreateFailed)
+	if condition == nil || condition.Status != metav1.ConditionTrue || !strings.Contains(condition.Message, "[Bucket]") {
+		t.Fatalf("expected the CreateFailed condition with the failure, got %v", stack.Status.Conditions)
+	}
+
+	// Not retried until the spec changes
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the failed create not retried, got %d creates", len(cfn.createInputs))
+	}
+
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	stack.Spec.Parameters = map[string]string{"BucketName": "my-fixed-bucket"}
+	if err := k8sClient.Update(context.TODO(), stack); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 2 {
+		t.Fatalf("expected the stack created again once the spec changed, got %d creates", len(cfn.createInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionCreateFailed) != nil {
+		t.Errorf("expected the CreateFailed condition removed, got %v", stack.Status.Conditions)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// onFailureChanged records the CreateOnlyChanged condition when the onFailure of the spec no longer matches how the
+// stack was created, as told by its rollback being disabled (DO_NOTHING) or not. CloudFormation only takes it on
+// create, the change applying should the stack be created again (e.g. after failing to create with onFailure: DELETE).
+func (r *StackReconciler) onFailureChanged(loop *StackLoop) error {
+	onFailure := loop.instance.Spec.OnFailure
+	changed := onFailure != "" && loop.stack != nil &&
+		(onFailure == string(cfTypes.OnFailureDoNothing)) != aws.ToBool(loop.stack.DisableRollback)
+	if !changed {
+		if removeCondition(loop.instance, v1alpha1.ConditionCreateOnlyChanged) {
+			return r.updateStatus(loop)
+		}
+		return nil
+	}
+
+	rollback := "enabled"
+	if onFailure != string(cfTypes.OnFailureDoNothing) {
+		rollback = "disabled"
+	}
+	message := fmt.Sprintf("onFailure is create-only: the stack was created with rollback %s, %s only applies "+
+		"should it be created again", rollback, onFailure)
+	if setCondition(loop.instance, v1alpha1.ConditionCreateOnlyChanged, metav1.ConditionTrue, "OnFailureChanged",
+		message) {
+		loop.Log.Info("Create-only onFailure changed after the stack was created", "onFailure", onFailure)
+		r.Recorder.Event(loop.instance, v1.EventTypeWarning, v1alpha1.ConditionCreateOnlyChanged, message)
+		return r.updateStatus(loop)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestOnFailureChangedAfterCreate(t *testing.T) {
+	tests := []struct {
+		name            string
+		onFailure       string
+		disableRollback bool
+		changed         bool
+	}{
+		{"unset", "", false, false},
+		{"rollback as created", "ROLLBACK", false, false},
+		{"delete as created", "DELETE", false, false},
+		{"do nothing as created", "DO_NOTHING", true, false},
+		{"do nothing after rollback", "DO_NOTHING", false, true},
+		{"rollback after do nothing", "ROLLBACK", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &v1alpha1.Stack{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default",
+					Finalizers: []string{stacksFinalizer}},
+				Spec:   v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate, OnFailure: tt.onFailure},
+				Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+			}
+			k8sClient := newFakeClient(instance)
+			cfn := newFakeCloudFormation()
+			stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+			stack.Tags = []cfTypes.Tag{{Key: aws.String(controllerKey), Value: aws.String(controllerValue)}}
+			stack.DisableRollback = aws.Bool(tt.disableRollback)
+			r := newTestReconciler(k8sClient, cfn)
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+			if _, err := r.Reconcile(context.TODO(), req); err != nil {
+				t.Fatal(err)
+			}
+			if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+				t.Fatal(err)
+			}
+			changed := meta.IsStatusConditionTrue(instance.Status.Conditions, v1alpha1.ConditionCreateOnlyChanged)
+			if changed != tt.changed {
+				t.Errorf("expected CreateOnlyChanged %v, got %v", tt.changed, instance.Status.Conditions)
+			}
+			warned := len(r.Recorder.(*record.FakeRecorder).Events) > 0
+			if tt.changed && !warned {
+				t.Error("expected a warning event")
+			}
+		})
+	}
+}
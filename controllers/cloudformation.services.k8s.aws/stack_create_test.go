@@ -0,0 +1,181 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	coreerrors "errors"
+	"testing"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newStatusInterceptingClient runs the hook ahead of every status write of a Stack made through the client, the write
+// failing with the error of the hook.
+func newStatusInterceptingClient(hook func(c client.Client, stack *v1alpha1.Stack) error,
+	objects ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(objects...).
+		WithStatusSubresource(&v1alpha1.Stack{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object,
+				opts ...client.SubResourceUpdateOption) error {
+				if stack, ok := obj.(*v1alpha1.Stack); ok {
+					if err := hook(c, stack); err != nil {
+						return err
+					}
+				}
+				return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+			},
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object,
+				patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				if stack, ok := obj.(*v1alpha1.Stack); ok {
+					if err := hook(c, stack); err != nil {
+						return err
+					}
+				}
+				return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+}
+
+func TestCreatePersistsStackID(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+	}
+	var written []string
+	k8sClient := newStatusInterceptingClient(func(c client.Client, stack *v1alpha1.Stack) error {
+		written = append(written, stack.Status.StackID)
+		return nil
+	}, instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the stack created, got %d creates", len(cfn.createInputs))
+	}
+	if len(written) < 2 || written[0] == "" {
+		t.Fatalf("expected the stack ID written on its own right after the create, got %v", written)
+	}
+	if r.ChannelHub.FollowQueue.Len() != 1 {
+		t.Errorf("expected the stack followed, got %d queued", r.ChannelHub.FollowQueue.Len())
+	}
+}
+
+func TestCreateSurvivesRestartBeforeFollowing(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+	}
+	// The controller stops right after recording the stack ID, nothing else gets written
+	crashed := false
+	k8sClient := newStatusInterceptingClient(func(c client.Client, stack *v1alpha1.Stack) error {
+		if crashed {
+			return coreerrors.New("controller stopped")
+		}
+		crashed = stack.Status.StackID != ""
+		return nil
+	}, instance)
+	cfn := newFakeCloudFormation()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := newTestReconciler(k8sClient, cfn).Reconcile(context.TODO(), req); err == nil {
+		t.Fatal("expected the status write after the create to fail")
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the stack created, got %d creates", len(cfn.createInputs))
+	}
+
+	// Restarted, the stack is picked up rather than created again
+	crashed = false
+	restarted := newTestReconciler(k8sClient, cfn)
+	if _, err := restarted.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Errorf("expected no duplicate stack created after the restart, got %d creates", len(cfn.createInputs))
+	}
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if stack.Status.StackID == "" {
+		t.Error("expected the stack ID recorded")
+	}
+	if restarted.ChannelHub.FollowQueue.Len() != 1 {
+		t.Errorf("expected the stack followed after the restart, got %d queued", restarted.ChannelHub.FollowQueue.Len())
+	}
+}
+
+func TestCreateStackIDWrittenDespiteConflict(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+	}
+	// The resource changes while the stack is being created
+	changed := false
+	k8sClient := newStatusInterceptingClient(func(c client.Client, stack *v1alpha1.Stack) error {
+		if changed || stack.Status.StackID == "" {
+			return nil
+		}
+		changed = true
+		latest := &v1alpha1.Stack{}
+		if err := c.Get(context.TODO(), client.ObjectKeyFromObject(stack), latest); err != nil {
+			return err
+		}
+		latest.Labels = map[string]string{"team": "storage"}
+		return c.Update(context.TODO(), latest)
+	}, instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if stack.Status.StackID == "" {
+		t.Error("expected the stack ID written to the latest version of the resource")
+	}
+	if stack.Labels["team"] != "storage" {
+		t.Errorf("expected the concurrent change kept, got %v", stack.Labels)
+	}
+}
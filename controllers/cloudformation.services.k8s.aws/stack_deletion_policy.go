@@ -0,0 +1,211 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Delay before checking back on the update applying the deletion policies
+	deletionPolicyRecheckInterval = 15 * time.Second
+)
+
+// retainedResources lists the logical IDs of the resources the spec retains on delete.
+func retainedResources(instance *v1alpha1.Stack) []string {
+	var retained []string
+	for logicalId, policy := range instance.Spec.DeletionPolicy {
+		if policy == v1alpha1.DeletionPolicyRetain {
+			retained = append(retained, logicalId)
+		}
+	}
+	sort.Strings(retained)
+	return retained
+}
+
+// applyDeletionPolicies updates the stack with the DeletionPolicy overrides of the spec set on the resources of its
+// template, ahead of its deletion. CloudFormation only accepts retaining resources on deletion of a stack which
+// failed to delete, the policies of the template are what the first deletion goes by. Reports whether the policies
+// are in effect and the stack may be deleted.
+func (r *StackReconciler) applyDeletionPolicies(loop *StackLoop) (bool, error) {
+	if len(loop.instance.Spec.DeletionPolicy) == 0 || r.DryRun ||
+		currentCondition(loop.instance, v1alpha1.ConditionDeletionPolicyRefused) {
+		return true, nil
+	}
+	hasOwnership, err := r.hasOwnership(loop)
+	if err != nil || !hasOwnership {
+		return true, err
+	}
+
+	stack, err := r.getStack(loop, true)
+	if err != nil {
+		return false, err
+	}
+	status := string(stack.StackStatus)
+	if strings.HasPrefix(status, "DELETE_") {
+		// Submitted already, failed deletions retain the resources through RetainResources
+		return true, nil
+	}
+	if strings.HasSuffix(status, "_IN_PROGRESS") {
+		r.ChannelHub.FollowQueue.Add(loop.instance)
+		return false, nil
+	}
+
+	callCtx, cancel := r.CloudFormationHelper.callContextFor(loop.ctx, loop.instance)
+	output, err := r.CloudFormationHelper.CloudFormationFor(loop.instance).GetTemplate(callCtx,
+		&cloudformation.GetTemplateInput{
+			StackName:     stack.StackId,
+			TemplateStage: cfTypes.TemplateStageOriginal,
+		})
+	err = callError(loop.ctx, callCtx, err)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	template, changed, err := withDeletionPolicies(aws.ToString(output.TemplateBody), loop.instance.Spec.DeletionPolicy)
+	if err != nil || !changed {
+		return err == nil, err
+	}
+
+	// Only the template changes, everything else about the stack is kept as is
+	input := &cloudformation.UpdateStackInput{
+		StackName:    stack.StackId,
+		Capabilities: stack.Capabilities,
+	}
+	if r.TemplateUploader != nil && r.TemplateUploader.NeedsUpload(template) {
+		url, err := r.TemplateUploader.Upload(loop.ctx, loop.instance, template)
+		if err != nil {
+			loop.Log.Error(err, "Failed to upload the template", "bucket", r.TemplateUploader.Bucket)
+			return false, err
+		}
+		input.TemplateURL = aws.String(url)
+	} else {
+		input.TemplateBody = aws.String(template)
+	}
+	for _, parameter := range stack.Parameters {
+		input.Parameters = append(input.Parameters, cfTypes.Parameter{
+			ParameterKey:     parameter.ParameterKey,
+			UsePreviousValue: aws.Bool(true),
+		})
+	}
+	loop.Log.Info("Applying the deletion policies before deleting the stack",
+		"policies", loop.instance.Spec.DeletionPolicy)
+	callCtx, cancel = r.CloudFormationHelper.callContextFor(loop.ctx, loop.instance)
+	_, err = r.CloudFormationHelper.CloudFormationFor(loop.instance).UpdateStack(callCtx, input)
+	err = callError(loop.ctx, callCtx, err)
+	cancel()
+	r.Metrics.ObserveOperation(loop.instance, "update", err)
+	if IsPermanentError(err) {
+		// Submitting the update again fails the same way, holding the deletion forever
+		return true, r.refuseDeletionPolicies(loop, err)
+	}
+	if err != nil {
+		r.Recorder.Event(loop.instance, v1.EventTypeWarning, "DeletionPolicyFailed",
+			"Failed to apply the deletion policies: "+err.Error())
+		return false, err
+	}
+	r.CloudFormationHelper.InvalidateStack(aws.ToString(stack.StackId))
+	r.ChannelHub.FollowQueue.Add(loop.instance)
+	return false, nil
+}
+
+// refuseDeletionPolicies records the DeletionPolicyRefused condition when CloudFormation refused the update setting the
+// deletion policies outright (e.g. a template too large to submit inline), the stack then deleted with the policies of
+// its template.
+func (r *StackReconciler) refuseDeletionPolicies(loop *StackLoop, err error) error {
+	message := "Deleting the stack with the deletion policies of its template, CloudFormation refused the update " +
+		"applying those of the spec: " + err.Error()
+	loop.Log.Info("Deletion policies refused, deleting the stack without them", "reason", err.Error())
+	r.Recorder.Event(loop.instance, v1.EventTypeWarning, v1alpha1.ConditionDeletionPolicyRefused, message)
+	if setCondition(loop.instance, v1alpha1.ConditionDeletionPolicyRefused, metav1.ConditionTrue, errorCode(err),
+		message) {
+		return r.updateStatus(loop)
+	}
+	return nil
+}
+
+// withDeletionPolicies sets the DeletionPolicy of the resources of a YAML or JSON template, keeping the rest of the
+// template (intrinsic function tags included) as is. Reports whether any policy changed; resources missing from the
+// template are left out.
+func withDeletionPolicies(template string, policies map[string]v1alpha1.ResourceDeletionPolicy) (string, bool,
+	error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(template), &document); err != nil {
+		return "", false, fmt.Errorf("unable to read the template: %w", err)
+	}
+	if len(document.Content) == 0 {
+		return template, false, nil
+	}
+	resources := mappingValue(document.Content[0], "Resources")
+
+	changed := false
+	for logicalId, policy := range policies {
+		resource := mappingValue(resources, logicalId)
+		if resource == nil || resource.Kind != yaml.MappingNode {
+			continue
+		}
+		if current := mappingValue(resource, "DeletionPolicy"); current == nil {
+			resource.Content = append(resource.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "DeletionPolicy"},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(policy)})
+			changed = true
+		} else if current.Value != string(policy) {
+			current.Kind, current.Tag, current.Value, current.Content = yaml.ScalarNode, "!!str", string(policy), nil
+			changed = true
+		}
+	}
+	if !changed {
+		return template, false, nil
+	}
+	out, err := yaml.Marshal(&document)
+	if err != nil {
+		return "", false, err
+	}
+	return string(out), true, nil
+}
+
+// mappingValue looks up the value of a key in a YAML mapping, nil when missing or not a mapping.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
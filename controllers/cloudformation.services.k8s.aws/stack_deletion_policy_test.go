@@ -0,0 +1,235 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const deletionPolicyTemplate = `Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Sub "${AWS::StackName}-data"
+  Queue:
+    Type: AWS::SQS::Queue
+    DeletionPolicy: Retain
+`
+
+func TestWithDeletionPolicies(t *testing.T) {
+	template, changed, err := withDeletionPolicies(deletionPolicyTemplate, map[string]v1alpha1.ResourceDeletionPolicy{
+		"Bucket":  v1alpha1.DeletionPolicyRetain,
+		"Queue":   v1alpha1.DeletionPolicyDelete,
+		"Missing": v1alpha1.DeletionPolicyRetain,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("expected the template to change")
+	}
+	if !strings.Contains(template, "!Sub") {
+		t.Errorf("expected the intrinsic function tags to be kept, got %s", template)
+	}
+	if !strings.Contains(template, "DeletionPolicy: Retain") || !strings.Contains(template, "DeletionPolicy: Delete") {
+		t.Errorf("expected both policies to be set, got %s", template)
+	}
+	if strings.Contains(template, "Missing") {
+		t.Errorf("expected resources missing from the template to be left out, got %s", template)
+	}
+
+	_, changed, err = withDeletionPolicies(deletionPolicyTemplate, map[string]v1alpha1.ResourceDeletionPolicy{
+		"Queue": v1alpha1.DeletionPolicyRetain,
+	})
+	if err != nil || changed {
+		t.Errorf("expected a policy already in place to leave the template alone, got %v, %v", changed, err)
+	}
+
+	json := `{"Resources": {"Topic": {"Type": "AWS::SNS::Topic"}}}`
+	template, changed, err = withDeletionPolicies(json, map[string]v1alpha1.ResourceDeletionPolicy{
+		"Topic": v1alpha1.DeletionPolicySnapshot,
+	})
+	if err != nil || !changed || !strings.Contains(template, "DeletionPolicy: Snapshot") {
+		t.Errorf("expected the JSON template to be updated, got %q, %v, %v", template, changed, err)
+	}
+}
+
+func TestDeletionPoliciesAppliedBeforeDelete(t *testing.T) {
+	now := metav1.Now()
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", DeletionTimestamp: &now,
+			Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: deletionPolicyTemplate,
+			DeletionPolicy: map[string]v1alpha1.ResourceDeletionPolicy{"Bucket": v1alpha1.DeletionPolicyRetain}},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	stack.Tags = []cfTypes.Tag{{Key: aws.String(controllerKey), Value: aws.String(controllerValue)}}
+	stack.Parameters = []cfTypes.Parameter{{ParameterKey: aws.String("Env"), ParameterValue: aws.String("dev")}}
+	cfn.templates[testStackID] = deletionPolicyTemplate
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Fatalf("expected the deletion policies to be applied first, got %d updates", len(cfn.updateInputs))
+	}
+	if len(cfn.deleteInputs) != 0 {
+		t.Fatalf("expected no deletion before the policies are applied, got %d", len(cfn.deleteInputs))
+	}
+	update := cfn.updateInputs[0]
+	if !strings.Contains(aws.ToString(update.TemplateBody), "DeletionPolicy: Retain") {
+		t.Errorf("expected the bucket to be retained, got %s", aws.ToString(update.TemplateBody))
+	}
+	if len(update.Parameters) != 1 || !aws.ToBool(update.Parameters[0].UsePreviousValue) {
+		t.Errorf("expected the parameters to be kept, got %v", update.Parameters)
+	}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Errorf("expected no further update once applied, got %d", len(cfn.updateInputs))
+	}
+	if len(cfn.deleteInputs) != 1 {
+		t.Errorf("expected the stack to be deleted once the policies are applied, got %d", len(cfn.deleteInputs))
+	}
+}
+
+// newDeletingStack prepares a Stack being deleted with the Bucket of deletionPolicyTemplate retained, its stack
+// deployed with the template given.
+func newDeletingStack(template string) (*v1alpha1.Stack, *fakeCloudFormation) {
+	now := metav1.Now()
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", DeletionTimestamp: &now,
+			Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: template,
+			DeletionPolicy: map[string]v1alpha1.ResourceDeletionPolicy{"Bucket": v1alpha1.DeletionPolicyRetain}},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+	}
+	cfn := newFakeCloudFormation()
+	stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	stack.Tags = []cfTypes.Tag{{Key: aws.String(controllerKey), Value: aws.String(controllerValue)}}
+	cfn.templates[testStackID] = template
+	return instance, cfn
+}
+
+func TestDeletionPoliciesUploadLargeTemplate(t *testing.T) {
+	template := "Description: " + strings.Repeat("x", maxTemplateBodySize) + "\n" + deletionPolicyTemplate
+	instance, cfn := newDeletingStack(template)
+	s3Client := &fakeS3{}
+	r := newTestReconciler(newFakeClient(instance), cfn)
+	r.CloudFormationHelper.S3 = s3Client
+	r.TemplateUploader = &TemplateUploader{CloudFormationHelper: r.CloudFormationHelper, Bucket: "templates"}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(s3Client.puts) != 1 || len(cfn.updateInputs) != 1 {
+		t.Fatalf("expected the template uploaded and the update submitted, got %d uploads and %d updates",
+			len(s3Client.puts), len(cfn.updateInputs))
+	}
+	update := cfn.updateInputs[0]
+	if update.TemplateBody != nil ||
+		!strings.HasPrefix(aws.ToString(update.TemplateURL), "https://templates.s3.amazonaws.com/default/my-bucket/") {
+		t.Errorf("expected the uploaded template URL to be submitted, got %s", aws.ToString(update.TemplateURL))
+	}
+}
+
+func TestDeletionPoliciesRefusedStillDeletes(t *testing.T) {
+	instance, cfn := newDeletingStack(deletionPolicyTemplate)
+	cfn.updateErr = &smithy.GenericAPIError{Code: "ValidationError",
+		Message: "1 validation error detected: Value at 'templateBody' failed to satisfy constraint: Member must " +
+			"have length less than or equal to 51200"}
+	k8sClient := newFakeClient(instance)
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 || len(cfn.deleteInputs) != 1 {
+		t.Fatalf("expected the stack deleted once the update is refused, got %d updates and %d deletes",
+			len(cfn.updateInputs), len(cfn.deleteInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(instance.Status.Conditions, v1alpha1.ConditionDeletionPolicyRefused) {
+		t.Errorf("expected the DeletionPolicyRefused condition, got %v", instance.Status.Conditions)
+	}
+
+	// Not submitted again while deleting
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Errorf("expected the refused update not submitted again, got %d updates", len(cfn.updateInputs))
+	}
+}
+
+func TestDeletionPoliciesCallTimeout(t *testing.T) {
+	instance, cfn := newDeletingStack(deletionPolicyTemplate)
+	r := newTestReconciler(newFakeClient(instance), &hangingTemplateReads{cfn})
+	r.CloudFormationHelper.CallTimeout = 10 * time.Millisecond
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); !IsCallTimeout(err) {
+		t.Fatalf("expected reading the template bound by the call timeout, got %v", err)
+	}
+	if len(cfn.deleteInputs) != 0 {
+		t.Errorf("expected no deletion before the policies are applied, got %d deletes", len(cfn.deleteInputs))
+	}
+}
+
+func TestRetainedResources(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		Spec: v1alpha1.StackSpec{DeletionPolicy: map[string]v1alpha1.ResourceDeletionPolicy{
+			"Queue": v1alpha1.DeletionPolicyRetain, "Bucket": v1alpha1.DeletionPolicyRetain,
+			"Topic": v1alpha1.DeletionPolicyDelete,
+		}},
+	}
+	if retained := retainedResources(instance); len(retained) != 2 || retained[0] != "Bucket" ||
+		retained[1] != "Queue" {
+		t.Errorf("expected the retained resources sorted, got %v", retained)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"strings"
+)
+
+// Resource types holding data which is lost when the stack is deleted
+var statefulResourceTypes = map[string]bool{
+	"AWS::DocDB::DBCluster":              true,
+	"AWS::DynamoDB::GlobalTable":         true,
+	"AWS::DynamoDB::Table":               true,
+	"AWS::EC2::Volume":                   true,
+	"AWS::EFS::FileSystem":               true,
+	"AWS::ElastiCache::ReplicationGroup": true,
+	"AWS::Elasticsearch::Domain":         true,
+	"AWS::FSx::FileSystem":               true,
+	"AWS::Kinesis::Stream":               true,
+	"AWS::Neptune::DBCluster":            true,
+	"AWS::OpenSearchService::Domain":     true,
+	"AWS::RDS::DBCluster":                true,
+	"AWS::RDS::DBInstance":               true,
+	"AWS::Redshift::Cluster":             true,
+	"AWS::S3::Bucket":                    true,
+}
+
+// statefulResources lists the stateful resources (as Type/LogicalID/PhysicalID) known to the stack.
+func statefulResources(loop *StackLoop) []string {
+	var stateful []string
+	for _, resource := range loop.instance.Status.Resources {
+		if statefulResourceTypes[resource.Type] && !strings.HasPrefix(resource.Status, "DELETE_") {
+			stateful = append(stateful, resource.Type+"/"+resource.LogicalId+"/"+resource.PhysicalId)
+		}
+	}
+	return stateful
+}
+
+// warnStatefulResources previews the deletion of the stack, warning of the stateful resources to be destroyed.
+func (r *StackReconciler) warnStatefulResources(loop *StackLoop) {
+	stateful := statefulResources(loop)
+	if len(stateful) == 0 || r.Recorder == nil {
+		return
+	}
+	loop.Log.Info("Deleting stack with stateful resources", "resources", stateful)
+	r.Recorder.Eventf(loop.instance, v1.EventTypeWarning, "DeletingStatefulResources",
+		"Deleting the stack destroys %d stateful resources: %s", len(stateful), strings.Join(stateful, ", "))
+}
@@ -0,0 +1,77 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestDeletionWarnsOfStatefulResources(t *testing.T) {
+	now := metav1.Now()
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", DeletionTimestamp: &now,
+			Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE",
+			Resources: []v1alpha1.StackResource{
+				{LogicalId: "Bucket", PhysicalId: "my-bucket-1a2b3c", Type: "AWS::S3::Bucket", Status: "CREATE_COMPLETE"},
+				{LogicalId: "Topic", PhysicalId: "arn:aws:sns:us-east-1:123456789012:topic", Type: "AWS::SNS::Topic",
+					Status: "CREATE_COMPLETE"},
+			}},
+	}
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	r := newTestReconciler(newFakeClient(instance), cfn)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-r.Recorder.(*record.FakeRecorder).Events:
+		if !strings.Contains(event, "DeletingStatefulResources") || !strings.Contains(event, "AWS::S3::Bucket/Bucket") ||
+			strings.Contains(event, "AWS::SNS::Topic") {
+			t.Errorf("unexpected event %q", event)
+		}
+	default:
+		t.Fatal("expected a warning of the stateful resources being deleted")
+	}
+	if len(cfn.deleteInputs) != 1 {
+		t.Errorf("expected the stack to be deleted, got %d deletes", len(cfn.deleteInputs))
+	}
+}
@@ -0,0 +1,129 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/go-logr/logr"
+	"strings"
+	"time"
+)
+
+const (
+	// Long poll duration for each receive on the queue
+	eventWaitSeconds = 20
+	// Delay before receiving again after the queue could not be read
+	eventRetryInterval = 10 * time.Second
+)
+
+// StackEventListener receives CloudFormation stack notifications delivered from the SNS topics in the stacks'
+// notificationArns to an SQS queue, prompting the follower to process the stacks concerned without waiting on its
+// next poll.
+type StackEventListener struct {
+	ChannelHub
+	Log                  logr.Logger
+	CloudFormationHelper *CloudFormationHelper
+	QueueURL             string
+}
+
+// snsEnvelope is the portion of an SNS notification delivered to SQS without raw message delivery
+type snsEnvelope struct {
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// Start receives the stack notifications until the context is done.
+func (l *StackEventListener) Start(ctx context.Context) error {
+	for ctx.Err() == nil {
+		if err := l.receive(ctx); err != nil && ctx.Err() == nil {
+			l.Log.Error(err, "Failed to receive stack events", "QueueURL", l.QueueURL)
+			select {
+			case <-ctx.Done():
+			case <-time.After(eventRetryInterval):
+			}
+		}
+	}
+	return nil
+}
+
+// NeedLeaderElection has only the leader receive the notifications, as only its follower follows the stacks.
+// Notifications received (and deleted) by standby replicas would otherwise be lost.
+func (l *StackEventListener) NeedLeaderElection() bool {
+	return true
+}
+
+// receive reads a batch of notifications from the queue, forwarding the stack IDs to the follower.
+func (l *StackEventListener) receive(ctx context.Context) error {
+	output, err := l.CloudFormationHelper.GetSQS().ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(l.QueueURL),
+		MaxNumberOfMessages: 10,
+		WaitTimeSeconds:     eventWaitSeconds,
+	})
+	if err != nil {
+		return err
+	}
+
+	stackIds := map[string]bool{}
+	for _, message := range output.Messages {
+		if stackId := parseStackNotification(aws.ToString(message.Body)); stackId != "" && !stackIds[stackId] {
+			stackIds[stackId] = true
+			select {
+			case l.ChannelHub.EventChannel <- stackId:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		_, err = l.CloudFormationHelper.GetSQS().DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(l.QueueURL),
+			ReceiptHandle: message.ReceiptHandle,
+		})
+		if err != nil {
+			l.Log.Error(err, "Failed to delete stack event", "MessageId", aws.ToString(message.MessageId))
+		}
+	}
+	return nil
+}
+
+// parseStackNotification extracts the stack ID from a CloudFormation notification, either wrapped in an SNS
+// envelope or delivered raw. Returns an empty string for messages which are not stack notifications.
+func parseStackNotification(body string) string {
+	envelope := &snsEnvelope{}
+	if err := json.Unmarshal([]byte(body), envelope); err == nil && envelope.Type == "Notification" {
+		body = envelope.Message
+	}
+
+	// CloudFormation notifications are lines of the form Key='value'
+	for _, line := range strings.Split(body, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if found && key == "StackId" {
+			return strings.Trim(value, "'")
+		}
+	}
+	return ""
+}
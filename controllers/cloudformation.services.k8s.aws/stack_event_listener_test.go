@@ -0,0 +1,120 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/go-logr/logr"
+)
+
+const testStackNotification = "StackId='" + testStackID + "'\nTimestamp='2022-01-01T00:00:00.000Z'\n" +
+	"EventId='4a5b6c'\nLogicalResourceId='my-bucket'\nResourceStatus='UPDATE_COMPLETE'\n"
+
+func TestParseStackNotification(t *testing.T) {
+	cases := map[string]string{
+		"raw": testStackNotification,
+		"sns": `{"Type":"Notification","TopicArn":"arn:aws:sns:us-east-1:123456789012:stacks","Message":"` +
+			"StackId='" + testStackID + `'\nTimestamp='2022-01-01T00:00:00.000Z'\n"}`,
+	}
+	for name, body := range cases {
+		if stackId := parseStackNotification(body); stackId != testStackID {
+			t.Errorf("%s: expected %s, got %q", name, testStackID, stackId)
+		}
+	}
+
+	if stackId := parseStackNotification(`{"Type":"SubscriptionConfirmation","Message":"confirm"}`); stackId != "" {
+		t.Errorf("expected no stack ID, got %q", stackId)
+	}
+}
+
+// fakeSQS delivers the messages queued on the first receive, later receives waiting until the context is done.
+type fakeSQS struct {
+	messages []sqsTypes.Message
+	deleted  chan string
+}
+
+func (f *fakeSQS) ReceiveMessage(ctx context.Context, _ *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (
+	*sqs.ReceiveMessageOutput, error) {
+	if messages := f.messages; messages != nil {
+		f.messages = nil
+		return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (f *fakeSQS) DeleteMessage(_ context.Context, params *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (
+	*sqs.DeleteMessageOutput, error) {
+	f.deleted <- aws.ToString(params.ReceiptHandle)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestEventListenerRunsOnLeaderUntilStopped(t *testing.T) {
+	queue := &fakeSQS{
+		messages: []sqsTypes.Message{{Body: aws.String(testStackNotification), ReceiptHandle: aws.String("receipt-1")}},
+		deleted:  make(chan string, 1),
+	}
+	listener := &StackEventListener{
+		ChannelHub:           ChannelHub{EventChannel: make(chan string)},
+		Log:                  logr.Discard(),
+		CloudFormationHelper: &CloudFormationHelper{SQS: queue},
+		QueueURL:             "https://sqs.us-east-1.amazonaws.com/123456789012/stack-events",
+	}
+	if !listener.NeedLeaderElection() {
+		t.Error("expected only the leader to receive the notifications")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- listener.Start(ctx) }()
+
+	select {
+	case stackId := <-listener.EventChannel:
+		if stackId != testStackID {
+			t.Errorf("expected %s, got %q", testStackID, stackId)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stack of the notification to be followed")
+	}
+	if receipt := <-queue.deleted; receipt != "receipt-1" {
+		t.Errorf("expected the notification deleted, got %q", receipt)
+	}
+
+	cancel()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("expected a clean stop, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the listener to stop with its context")
+	}
+}
@@ -0,0 +1,82 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+)
+
+// requestIDPattern finds the AWS request ID quoted by resource providers in their failure reasons
+var requestIDPattern = regexp.MustCompile(`Request ID: ([\w-]+)`)
+
+// StackFailureSummary explains in a line why the stack failed, from its status and the resource which failed first.
+func StackFailureSummary(stack *cfTypes.Stack, failure *cfTypes.StackEvent) string {
+	summary := "Stack is " + string(stack.StackStatus)
+	reason := aws.ToString(stack.StackStatusReason)
+	if failure != nil {
+		summary += ": " + ResourceFailureMessage(failure)
+		reason = aws.ToString(failure.ResourceStatusReason)
+	} else if reason != "" {
+		summary += ": " + reason
+	}
+	if match := requestIDPattern.FindStringSubmatch(reason); match != nil {
+		summary += " (request ID " + match[1] + ")"
+	}
+	return summary
+}
+
+// OperationFailureSummary explains in a line why CloudFormation refused the operation, along with the request ID.
+func OperationFailureSummary(operation string, err error) string {
+	summary := operation + " failed: "
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		summary += fmt.Sprintf("%s: %s", apiErr.ErrorCode(), apiErr.ErrorMessage())
+	} else {
+		summary += err.Error()
+	}
+	var responseErr *awshttp.ResponseError
+	if errors.As(err, &responseErr) && responseErr.ServiceRequestID() != "" {
+		summary += " (request ID " + responseErr.ServiceRequestID() + ")"
+	}
+	return summary
+}
+
+// recordFailureSummary records the summary of the latest failure in the status, clearing it when empty.
+func (r *StackReconciler) recordFailureSummary(loop *StackLoop, summary string) {
+	if loop.instance.Status.FailureSummary == summary {
+		return
+	}
+	loop.instance.Status.FailureSummary = summary
+	if err := r.updateStatus(loop); err != nil {
+		loop.Log.Error(err, "Failed to record the failure summary")
+	}
+}
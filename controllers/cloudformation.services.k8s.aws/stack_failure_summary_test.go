@@ -0,0 +1,102 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFollowerRecordsFailureSummary(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_IN_PROGRESS"},
+	}
+	cfn := newFakeCloudFormation()
+	stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusRollbackComplete)
+	cfn.events[testStackID] = []cfTypes.StackEvent{
+		{
+			LogicalResourceId:  aws.String("Bucket"),
+			PhysicalResourceId: aws.String("my-bucket-1a2b"),
+			ResourceType:       aws.String("AWS::S3::Bucket"),
+			ResourceStatus:     cfTypes.ResourceStatusCreateFailed,
+			ResourceStatusReason: aws.String("Access Denied (Service: Amazon S3; Status Code: 403; " +
+				"Error Code: AccessDenied; Request ID: 8XK2M4V7; Proxy: null)"),
+		},
+	}
+
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Stack is ROLLBACK_COMPLETE: Bucket (AWS::S3::Bucket) CREATE_FAILED: Access Denied (Service: Amazon S3; " +
+		"Status Code: 403; Error Code: AccessDenied; Request ID: 8XK2M4V7; Proxy: null) (request ID 8XK2M4V7)"
+	if instance.Status.FailureSummary != expected {
+		t.Fatalf("expected the failure summarized, got %q", instance.Status.FailureSummary)
+	}
+
+	// Cleared once the stack settles healthy
+	stack.StackStatus = cfTypes.StackStatusUpdateComplete
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.FailureSummary != "" {
+		t.Errorf("expected the failure summary cleared, got %q", instance.Status.FailureSummary)
+	}
+}
+
+func TestUpdateRefusalRecordsFailureSummary(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate + "  Broken\n"})
+	cfn.updateErr = &smithy.OperationError{
+		ServiceID:     "CloudFormation",
+		OperationName: "UpdateStack",
+		Err: &awshttp.ResponseError{
+			ResponseError: &smithyhttp.ResponseError{
+				Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 400}},
+				Err:      &smithy.GenericAPIError{Code: "ValidationError", Message: "Template format error"},
+			},
+			RequestID: "5f1d7c2e-0b7a-4d2f-9c1e-2a6b3e8f4d10",
+		},
+	}
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "UpdateStack failed: ValidationError: Template format error " +
+		"(request ID 5f1d7c2e-0b7a-4d2f-9c1e-2a6b3e8f4d10)"
+	if loop.instance.Status.FailureSummary != expected {
+		t.Errorf("expected the refusal summarized, got %q", loop.instance.Status.FailureSummary)
+	}
+}
@@ -27,20 +27,36 @@ package cloudformation_services_k8s_aws
 
 import (
 	"context"
+	"fmt"
 	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
 	"github.com/prometheus/client_golang/prometheus"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// Number of status transitions kept in the history of each stack
+const maxStatusHistory = 10
+
+// Number of resources CloudFormation allows in a stack, and the count from which a warning is recorded
+const (
+	maxStackResources    = 500
+	resourceLimitWarning = 450
+)
+
 // StackFollower ensures a Stack object is monitored until it reaches a terminal state
 type StackFollower struct {
 	client.Client
@@ -49,12 +65,34 @@ type StackFollower struct {
 	CloudFormationHelper *CloudFormationHelper
 	StacksFollowing      prometheus.Gauge
 	StacksFollowed       prometheus.Counter
-	mapPollingList       sync.Map // StackID -> Kube Stack object
+	// Optional gauge of the stacks being followed by their latest CloudFormation status
+	StacksByStatus *prometheus.GaugeVec
+	// Optional histogram of the time each poll of all the stacks being followed takes
+	PollDuration prometheus.Histogram
+	// Optional counter of the polls failing, by namespace and name of the Stack
+	PollErrors *prometheus.CounterVec
+	// Interval between polls of the stacks being followed, defaults to every second
+	PollInterval time.Duration
+	// Optional bound the polls of stacks holding a status back off to, polled every PollInterval when not above it
+	MaxPollInterval time.Duration
+	// Optional webhook notified of each stack status transition
+	StatusNotifier *StatusNotifier
+	// Optional sink storing the record of each operation which succeeded
+	DeploymentSink DeploymentSink
+	// Optional bound on the operations running at once, released as the stacks settle
+	OperationLimiter *OperationLimiter
+	// Optional recorder of the warnings about stacks approaching the resource limit
+	Recorder record.EventRecorder
+	// Records the values CloudFormation resolved SSM-typed parameters to in the status
+	RecordResolvedParameters bool
+	mapPollingList           sync.Map // StackID -> *pollState
+	followedStatus           sync.Map // StackID -> latest cfTypes.StackStatus polled
+	cancelledAt              sync.Map // StackID -> start time of the update cancelled for running too long
 }
 
 func (f *StackFollower) Receiver() {
 	for {
-		toBeFollowed := <-f.ChannelHub.FollowChannel
+		toBeFollowed := f.ChannelHub.FollowQueue.Get()
 		if !f.beingFollowed(toBeFollowed.Status.StackID) {
 			f.startFollowing(toBeFollowed)
 		}
@@ -70,7 +108,7 @@ func (f *StackFollower) beingFollowed(stackId string) bool {
 // Identify if the follower is actively working this one.
 func (f *StackFollower) startFollowing(stack *v1alpha1.Stack) {
 	namespacedName := &types.NamespacedName{Name: stack.Name, Namespace: stack.Namespace}
-	f.mapPollingList.Store(stack.Status.StackID, namespacedName)
+	f.mapPollingList.Store(stack.Status.StackID, &pollState{namespacedName: namespacedName})
 	f.Log.Info("Now following Stack", "StackID", stack.Status.StackID)
 	f.StacksFollowed.Inc()
 	f.StacksFollowing.Inc()
@@ -78,9 +116,30 @@ func (f *StackFollower) startFollowing(stack *v1alpha1.Stack) {
 
 // Identify if the follower is actively working this one.
 func (f *StackFollower) stopFollowing(stackId string) {
-	f.mapPollingList.Delete(stackId)
+	if value, followed := f.mapPollingList.LoadAndDelete(stackId); followed {
+		f.OperationLimiter.Release(*value.(*pollState).namespacedName)
+	}
 	f.Log.Info("Stopped following Stack", "StackID", stackId)
 	f.StacksFollowing.Dec()
+	f.cancelledAt.Delete(stackId)
+	if previous, observed := f.followedStatus.LoadAndDelete(stackId); observed && f.StacksByStatus != nil {
+		f.StacksByStatus.WithLabelValues(string(previous.(cfTypes.StackStatus))).Dec()
+	}
+}
+
+// observeStatus moves a followed stack to the partition of StacksByStatus of the status polled.
+func (f *StackFollower) observeStatus(stackId string, status cfTypes.StackStatus) {
+	if f.StacksByStatus == nil {
+		return
+	}
+	previous, observed := f.followedStatus.Swap(stackId, status)
+	if observed && previous.(cfTypes.StackStatus) == status {
+		return
+	}
+	if observed {
+		f.StacksByStatus.WithLabelValues(string(previous.(cfTypes.StackStatus))).Dec()
+	}
+	f.StacksByStatus.WithLabelValues(string(status)).Inc()
 }
 
 // Allow passing a current/recent fetch of the stack object to the method (optionally)
@@ -88,6 +147,7 @@ func (f *StackFollower) updateStackStatus(ctx context.Context, instance *v1alpha
 	var err error
 	var cfs *cfTypes.Stack
 	update := false
+	base := instance.Status.DeepCopy()
 	log := f.Log.WithValues("StackID", instance.Status.StackID, "UID", instance.UID, "Namespace",
 		instance.Namespace, "Name", instance.Name)
 
@@ -95,7 +155,7 @@ func (f *StackFollower) updateStackStatus(ctx context.Context, instance *v1alpha
 		cfs = stack[0]
 	}
 	if cfs == nil {
-		cfs, err = f.CloudFormationHelper.GetStack(ctx, instance)
+		cfs, err = f.CloudFormationHelper.GetStackNoCache(ctx, instance)
 		if err != nil {
 			log.Error(err, "Failed to get CloudFormation stack")
 			return err
@@ -110,17 +170,61 @@ func (f *StackFollower) updateStackStatus(ctx context.Context, instance *v1alpha
 	}
 
 	// Checking the status
+	var notification *StatusNotification
 	if string(cfs.StackStatus) != instance.Status.StackStatus {
 		update = true
+		notification = &StatusNotification{
+			Namespace: instance.Namespace,
+			Name:      instance.Name,
+			StackName: aws.ToString(cfs.StackName),
+			StackID:   aws.ToString(cfs.StackId),
+			OldStatus: instance.Status.StackStatus,
+			NewStatus: string(cfs.StackStatus),
+			Reason:    aws.ToString(cfs.StackStatusReason),
+		}
 		instance.Status.StackStatus = string(cfs.StackStatus)
+		instance.Status.History = appendHistory(instance.Status.History, v1alpha1.StackStatusTransition{
+			Time:   metav1.Now(),
+			Status: string(cfs.StackStatus),
+			Reason: aws.ToString(cfs.StackStatusReason),
+		})
+	}
 
-		createdTime := metav1.NewTime(*cfs.CreationTime)
-		instance.Status.CreatedTime = &createdTime
+	// Recording the creation and last update times as they change, an update can end in the status the stack was in
+	if createdTime := stackTime(cfs.CreationTime); !timeEqual(createdTime, instance.Status.CreatedTime) {
+		update = true
+		instance.Status.CreatedTime = createdTime
+	}
+	if updatedTime := stackTime(cfs.LastUpdatedTime); updatedTime != nil &&
+		!timeEqual(updatedTime, instance.Status.UpdatedTime) {
+		update = true
+		instance.Status.UpdatedTime = updatedTime
+	}
 
-		if cfs.LastUpdatedTime != nil {
-			updatedTime := metav1.NewTime(*cfs.LastUpdatedTime)
-			instance.Status.UpdatedTime = &updatedTime
+	// Deriving the Ready condition from the status
+	readyStatus, readyReason := f.CloudFormationHelper.ReadyCondition(cfs.StackStatus)
+	readyMessage := "Stack is " + string(cfs.StackStatus)
+	if cfs.StackStatusReason != nil && *cfs.StackStatusReason != "" {
+		readyMessage += ": " + *cfs.StackStatusReason
+	}
+
+	// Pinpointing the resource which failed the operation, looked up once as the stack transitions
+	if failedOperationStatus(cfs.StackStatus) {
+		if notification != nil {
+			failure, err := f.CloudFormationHelper.GetResourceFailure(ctx, *cfs.StackId)
+			if err != nil {
+				log.Error(err, "Failed to check the stack events for resource failures")
+			} else if failure != nil {
+				readyMessage = "Stack is " + string(cfs.StackStatus) + ": " + ResourceFailureMessage(failure)
+			}
+			instance.Status.FailureSummary = StackFailureSummary(cfs, failure)
+		} else if ready := meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionReady); ready != nil &&
+			ready.Reason == readyReason {
+			readyMessage = ready.Message
 		}
+	} else if readyStatus == metav1.ConditionTrue && instance.Status.FailureSummary != "" {
+		update = true
+		instance.Status.FailureSummary = ""
 	}
 
 	// Checking stack ID and outputs for changes.
@@ -133,6 +237,76 @@ func (f *StackFollower) updateStackStatus(ctx context.Context, instance *v1alpha
 		}
 	}
 
+	// Recording the values SSM-typed parameters resolved to, when permitted
+	var resolvedParameters map[string]string
+	if f.RecordResolvedParameters {
+		resolvedParameters = stackResolvedParameters(cfs)
+	}
+	if !reflect.DeepEqual(resolvedParameters, instance.Status.ResolvedParameters) {
+		update = true
+		instance.Status.ResolvedParameters = resolvedParameters
+	}
+
+	// Timing the operation once the stack settles
+	if notification != nil {
+		f.recordOperationDuration(instance, notification.OldStatus, cfs, time.Now())
+	}
+
+	// Surfacing a CloudFormation Hook which failed the operation once the stack settles
+	if notification != nil && f.CloudFormationHelper.StackInTerminalState(cfs.StackStatus) {
+		hookFailure, err := f.CloudFormationHelper.GetHookFailure(ctx, stackID)
+		if err != nil {
+			log.Error(err, "Failed to check the stack events for hook failures")
+		} else if hookFailure != nil {
+			if setCondition(instance, v1alpha1.ConditionHookBlocked, metav1.ConditionTrue, "HookFailed",
+				HookFailureMessage(hookFailure)) {
+				update = true
+			}
+		} else if removeCondition(instance, v1alpha1.ConditionHookBlocked) {
+			update = true
+		}
+	}
+
+	// Cancelling updates running for too long
+	if f.cancelTimedOutUpdate(ctx, log, instance, cfs) {
+		update = true
+	}
+
+	// Recording when the stack is due to be deleted
+	var scheduledDeletion *metav1.Time
+	if instance.Spec.TTL != nil && instance.Status.CreatedTime != nil {
+		deletionTime := metav1.NewTime(instance.Status.CreatedTime.Add(instance.Spec.TTL.Duration))
+		scheduledDeletion = &deletionTime
+	}
+	if !reflect.DeepEqual(scheduledDeletion, instance.Status.ScheduledDeletionTime) {
+		update = true
+		instance.Status.ScheduledDeletionTime = scheduledDeletion
+	}
+
+	// Recording the notification ARNs and rollback configuration live on the stack
+	var notificationARNs []string
+	if len(cfs.NotificationARNs) > 0 {
+		notificationARNs = cfs.NotificationARNs
+	}
+	if !reflect.DeepEqual(notificationARNs, instance.Status.NotificationARNs) {
+		update = true
+		instance.Status.NotificationARNs = notificationARNs
+	}
+	rollbackConfiguration := f.rollbackConfiguration(cfs)
+	if !reflect.DeepEqual(rollbackConfiguration, instance.Status.RollbackConfiguration) {
+		update = true
+		instance.Status.RollbackConfiguration = rollbackConfiguration
+	}
+
+	// Recording the region and account the stack lives in
+	if stackArn, err := arn.Parse(stackID); err == nil {
+		if stackArn.Region != instance.Status.Region || stackArn.AccountID != instance.Status.AccountID {
+			update = true
+			instance.Status.Region = stackArn.Region
+			instance.Status.AccountID = stackArn.AccountID
+		}
+	}
+
 	// Recording the Role ARN
 	roleArn := cfs.RoleARN
 	if roleArn != nil && *roleArn != "" {
@@ -151,9 +325,49 @@ func (f *StackFollower) updateStackStatus(ctx context.Context, instance *v1alpha
 		update = true
 		instance.Status.Resources = resources
 	}
+	// Progress is only reported while an operation runs, from the resources it touched
+	progress := ""
+	if strings.HasSuffix(string(cfs.StackStatus), "_IN_PROGRESS") {
+		events, err := f.CloudFormationHelper.GetOperationEvents(ctx, stackID)
+		if err != nil {
+			log.Error(err, "Failed to check the stack events for progress")
+			progress = instance.Status.Progress
+		} else {
+			progress = stackProgress(stackID, events)
+		}
+	}
+	if progress != instance.Status.Progress {
+		update = true
+		instance.Status.Progress = progress
+	}
+	nestedStacks := nestedStacks(resources)
+	if !reflect.DeepEqual(nestedStacks, instance.Status.NestedStacks) {
+		update = true
+		instance.Status.NestedStacks = nestedStacks
+	}
+	resourceCount := int32(len(resources))
+	if resourceCount != instance.Status.ResourceCount {
+		update = true
+		f.warnResourceLimit(instance, resourceCount)
+		instance.Status.ResourceCount = resourceCount
+	}
+
+	// A failed child stack degrades the parent otherwise healthy
+	if readyStatus == metav1.ConditionTrue {
+		if failed := failedNestedStack(nestedStacks); failed != nil {
+			readyStatus, readyReason = metav1.ConditionFalse, "NestedStackFailed"
+			readyMessage = fmt.Sprintf("Nested stack %s is %s", failed.LogicalId, failed.Status)
+			if failed.StatusReason != "" {
+				readyMessage += ": " + failed.StatusReason
+			}
+		}
+	}
+	if setCondition(instance, v1alpha1.ConditionReady, readyStatus, readyReason, readyMessage) {
+		update = true
+	}
 
 	if update {
-		err = f.Status().Update(ctx, instance)
+		err = patchStatus(ctx, f.Client, instance, base)
 		if err != nil {
 			log.Error(err, "Failed to update Stack Status")
 			if errors.IsNotFound(err) {
@@ -169,13 +383,141 @@ func (f *StackFollower) updateStackStatus(ctx context.Context, instance *v1alpha
 		}
 	}
 
+	if notification != nil && f.StatusNotifier != nil {
+		f.StatusNotifier.Notify(notification)
+	}
+	if notification != nil {
+		f.recordDeployment(instance, notification.OldStatus, cfs, time.Now())
+	}
+
+	return nil
+}
+
+// appendHistory records the status transition, keeping the most recent maxStatusHistory transitions.
+func appendHistory(history []v1alpha1.StackStatusTransition, transition v1alpha1.StackStatusTransition) []v1alpha1.StackStatusTransition {
+	history = append(history, transition)
+	if len(history) > maxStatusHistory {
+		history = history[len(history)-maxStatusHistory:]
+	}
+	return history
+}
+
+// stackProgress approximates the progress of the stack operation from its events, newest first, as the count of
+// resources settled out of those the operation touched so far. Resources the operation leaves alone are not counted.
+func stackProgress(stackId string, events []cfTypes.StackEvent) string {
+	settled := map[string]bool{}
+	for _, event := range events {
+		if event.ResourceStatus == "" || (aws.ToString(event.PhysicalResourceId) == stackId &&
+			aws.ToString(event.ResourceType) == "AWS::CloudFormation::Stack") {
+			// Hook events and those of the stack itself
+			continue
+		}
+		resource := aws.ToString(event.LogicalResourceId)
+		if _, seen := settled[resource]; seen {
+			// Only the latest event of each resource counts
+			continue
+		}
+		settled[resource] = !strings.HasSuffix(string(event.ResourceStatus), "_IN_PROGRESS")
+	}
+	if len(settled) == 0 {
+		return ""
+	}
+	completed := 0
+	for _, done := range settled {
+		if done {
+			completed++
+		}
+	}
+	return fmt.Sprintf("%d/%d", completed, len(settled))
+}
+
+// nestedStacks lists the child stacks among the resources of the stack.
+func nestedStacks(resources []v1alpha1.StackResource) []v1alpha1.NestedStack {
+	var toReturn []v1alpha1.NestedStack
+	for _, resource := range resources {
+		if resource.Type != "AWS::CloudFormation::Stack" {
+			continue
+		}
+		toReturn = append(toReturn, v1alpha1.NestedStack{
+			LogicalId:    resource.LogicalId,
+			StackID:      resource.PhysicalId,
+			Status:       resource.Status,
+			StatusReason: resource.StatusReason,
+		})
+	}
+	return toReturn
+}
+
+// failedNestedStack returns the first child stack whose latest operation failed, if any.
+func failedNestedStack(nestedStacks []v1alpha1.NestedStack) *v1alpha1.NestedStack {
+	for i := range nestedStacks {
+		if strings.HasSuffix(nestedStacks[i].Status, "_FAILED") {
+			return &nestedStacks[i]
+		}
+	}
 	return nil
 }
 
+// failedOperationStatus identifies the statuses of operations which failed or are rolled back.
+func failedOperationStatus(status cfTypes.StackStatus) bool {
+	return strings.Contains(string(status), "FAILED") || strings.Contains(string(status), "ROLLBACK")
+}
+
+// warnResourceLimit records a Warning event once the resource count of the stack reaches resourceLimitWarning,
+// ahead of CloudFormation failing the operation adding resources past maxStackResources.
+func (f *StackFollower) warnResourceLimit(instance *v1alpha1.Stack, resourceCount int32) {
+	if f.Recorder == nil || resourceCount < resourceLimitWarning || instance.Status.ResourceCount >= resourceLimitWarning {
+		return
+	}
+	f.Recorder.Eventf(instance, corev1.EventTypeWarning, "ResourceLimitApproaching",
+		"The stack manages %d resources, CloudFormation allows at most %d per stack", resourceCount, maxStackResources)
+}
+
+// rollbackConfiguration converts the rollback configuration of the CloudFormation stack, if any is set.
+func (f *StackFollower) rollbackConfiguration(cfs *cfTypes.Stack) *v1alpha1.RollbackConfiguration {
+	if cfs.RollbackConfiguration == nil ||
+		(cfs.RollbackConfiguration.MonitoringTimeInMinutes == nil && len(cfs.RollbackConfiguration.RollbackTriggers) == 0) {
+		return nil
+	}
+
+	toReturn := &v1alpha1.RollbackConfiguration{
+		MonitoringTimeInMinutes: cfs.RollbackConfiguration.MonitoringTimeInMinutes,
+	}
+	for _, trigger := range cfs.RollbackConfiguration.RollbackTriggers {
+		toReturn.RollbackTriggers = append(toReturn.RollbackTriggers, v1alpha1.RollbackTrigger{
+			Arn:  aws.ToString(trigger.Arn),
+			Type: aws.ToString(trigger.Type),
+		})
+	}
+	return toReturn
+}
+
+// stackTime converts a time reported by CloudFormation, nil when not reported.
+func stackTime(t *time.Time) *metav1.Time {
+	if t == nil {
+		return nil
+	}
+	converted := metav1.NewTime(*t)
+	return &converted
+}
+
+// timeEqual compares times as they are stored in the status, to the second.
+func timeEqual(a, b *metav1.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Rfc3339Copy().Time.Equal(b.Rfc3339Copy().Time)
+}
+
 func (f *StackFollower) processStack(key interface{}, value interface{}) bool {
 
 	stackId := key.(string)
-	namespacedName := value.(*types.NamespacedName)
+	followed := value.(*pollState)
+	now := time.Now()
+	if !followed.pollDue(now) {
+		return true
+	}
+	namespacedName := followed.namespacedName
 	stack := &v1alpha1.Stack{}
 	log := f.Log.WithValues("StackID", stackId, "Namespace",
 		namespacedName.Namespace, "Name", namespacedName.Name)
@@ -190,22 +532,47 @@ func (f *StackFollower) processStack(key interface{}, value interface{}) bool {
 		}
 		// Error reading the object - requeue the request.
 		f.Log.Error(err, "Failed to get Stack on this pass, requeuing")
+		f.observePollError(namespacedName)
 		return true
 	}
 	log = log.WithValues("UID", stack.UID)
 
-	cfs, err := f.CloudFormationHelper.GetStack(context.TODO(), stack)
+	// Paused stacks are neither polled nor their status written, until resumed
+	if paused(stack) {
+		log.V(1).Info("Stack paused, skipping the poll")
+		return true
+	}
+
+	// Querying by the followed stack ID whenever we have one. Once a deletion finishes, CloudFormation only
+	// reports the stack (as DELETE_COMPLETE) by ID, a lookup by name says it does not exist.
+	var cfs *cfTypes.Stack
+	if strings.HasPrefix(stackId, "arn:") {
+		cfs, err = f.CloudFormationHelper.DescribeStack(context.TODO(), stackId)
+	} else {
+		cfs, err = f.CloudFormationHelper.GetStackNoCache(context.TODO(), stack)
+	}
 	if err != nil {
 		if err == ErrStackNotFound {
 			log.Error(err, "Stack Not Found")
 			f.stopFollowing(stackId)
 		} else {
 			log.Error(err, "Error retrieving stack for processing")
+			f.observePollError(namespacedName)
+		}
+	} else if deletedOnFailure(stack, cfs) {
+		if err = f.clearFailedCreate(context.TODO(), log, stack, cfs); err != nil {
+			log.Error(err, "Failed to update stack status")
+			f.observePollError(namespacedName)
+		} else {
+			f.stopFollowing(stackId)
 		}
 	} else {
+		f.observeStatus(stackId, cfs.StackStatus)
+		f.schedulePoll(followed, stack, cfs.StackStatus, now)
 		err = f.updateStackStatus(context.TODO(), stack, cfs)
 		if err != nil {
 			log.Error(err, "Failed to update stack status")
+			f.observePollError(namespacedName)
 		} else if f.CloudFormationHelper.StackInTerminalState(cfs.StackStatus) {
 			f.stopFollowing(stackId)
 			f.ChannelHub.MappingChannel <- stack
@@ -215,9 +582,52 @@ func (f *StackFollower) processStack(key interface{}, value interface{}) bool {
 	return true
 }
 
+// pollFollowed processes each of the stacks being followed, timing the whole poll.
+func (f *StackFollower) pollFollowed() {
+	start := time.Now()
+	f.mapPollingList.Range(f.processStack)
+	if f.PollDuration != nil {
+		f.PollDuration.Observe(time.Since(start).Seconds())
+	}
+}
+
+// observePollError counts a poll of the stack which failed.
+func (f *StackFollower) observePollError(namespacedName *types.NamespacedName) {
+	if f.PollErrors != nil {
+		f.PollErrors.WithLabelValues(namespacedName.Namespace, namespacedName.Name).Inc()
+	}
+}
+
 func (f *StackFollower) Worker() {
+	ticker := time.NewTicker(f.pollInterval())
+	defer ticker.Stop()
+
 	for {
-		time.Sleep(time.Second)
-		f.mapPollingList.Range(f.processStack)
+		select {
+		case <-ticker.C:
+			f.pollFollowed()
+		case stackId := <-f.ChannelHub.EventChannel:
+			// Processing a followed stack as soon as an event arrives for it
+			if value, followed := f.mapPollingList.Load(stackId); followed {
+				value.(*pollState).nextPoll = time.Time{}
+				f.processStack(stackId, value)
+			}
+		}
+	}
+}
+
+// stackResolvedParameters provides the values CloudFormation resolved the SSM-typed parameters of the stack to, nil
+// when it has none.
+func stackResolvedParameters(cfs *cfTypes.Stack) map[string]string {
+	var resolved map[string]string
+	for _, parameter := range cfs.Parameters {
+		if parameter.ResolvedValue == nil {
+			continue
+		}
+		if resolved == nil {
+			resolved = map[string]string{}
+		}
+		resolved[aws.ToString(parameter.ParameterKey)] = *parameter.ResolvedValue
 	}
+	return resolved
 }
@@ -0,0 +1,656 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+var fakeCreationTime = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const testStackID = "arn:aws:cloudformation:us-east-1:123456789012:stack/my-bucket/327b7d3c"
+
+func TestFollowerObservesDeleteComplete(t *testing.T) {
+	// The CR never had the stack ID recorded, only the follower knows it
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackStatus: "DELETE_IN_PROGRESS"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	// Deleted stacks no longer resolve by name
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusDeleteComplete)
+
+	follower := newTestFollower(k8sClient, cfn)
+	follower.startFollowing(&v1alpha1.Stack{
+		ObjectMeta: instance.ObjectMeta,
+		Status:     v1alpha1.StackStatus{StackID: testStackID},
+	})
+	follower.mapPollingList.Range(follower.processStack)
+
+	if follower.beingFollowed(testStackID) {
+		t.Fatal("expected the stack to no longer be followed")
+	}
+
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: "my-bucket", Namespace: "default"}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.StackStatus != "DELETE_COMPLETE" {
+		t.Errorf("expected DELETE_COMPLETE, got %s", updated.Status.StackStatus)
+	}
+	if updated.Status.StackID != testStackID {
+		t.Errorf("expected stack ID %s, got %s", testStackID, updated.Status.StackID)
+	}
+	if updated.Status.Region != "us-east-1" || updated.Status.AccountID != "123456789012" {
+		t.Errorf("expected region and account from the stack ID, got %s/%s", updated.Status.Region,
+			updated.Status.AccountID)
+	}
+}
+
+func TestFollowerSurfacesHookFailure(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_IN_PROGRESS"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateRollbackComplete)
+	stackEvent := func(status cfTypes.ResourceStatus) cfTypes.StackEvent {
+		return cfTypes.StackEvent{
+			LogicalResourceId:  aws.String("my-bucket"),
+			PhysicalResourceId: aws.String(testStackID),
+			ResourceType:       aws.String("AWS::CloudFormation::Stack"),
+			ResourceStatus:     status,
+		}
+	}
+	cfn.events[testStackID] = []cfTypes.StackEvent{
+		stackEvent(cfTypes.ResourceStatusUpdateRollbackComplete),
+		stackEvent(cfTypes.ResourceStatusUpdateRollbackInProgress),
+		{
+			LogicalResourceId: aws.String("Bucket"),
+			ResourceType:      aws.String("AWS::S3::Bucket"),
+			HookType:          aws.String("MyCompany::Governance::BucketPolicy"),
+			HookStatus:        cfTypes.HookStatusHookCompleteFailed,
+			HookStatusReason:  aws.String("Buckets must enable versioning"),
+		},
+		stackEvent(cfTypes.ResourceStatusUpdateInProgress),
+	}
+
+	follower := newTestFollower(k8sClient, cfn)
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+
+	condition := meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionHookBlocked)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Fatalf("expected the HookBlocked condition, got %v", instance.Status.Conditions)
+	}
+	if condition.Message != "Hook MyCompany::Governance::BucketPolicy failed on Bucket: Buckets must enable versioning" {
+		t.Errorf("unexpected message %s", condition.Message)
+	}
+
+	// The next operation succeeding clears the condition
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete)
+	cfn.events[testStackID] = append([]cfTypes.StackEvent{
+		stackEvent(cfTypes.ResourceStatusUpdateComplete),
+		stackEvent(cfTypes.ResourceStatusUpdateInProgress),
+	}, cfn.events[testStackID]...)
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionHookBlocked) != nil {
+		t.Errorf("expected the HookBlocked condition to be removed")
+	}
+}
+
+func TestFollowerReadyMessageFromResourceFailure(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_IN_PROGRESS"},
+	}
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateRollbackComplete).StackStatusReason =
+		aws.String("The following resource(s) failed to update: [Bucket]")
+	resourceEvent := func(logicalId string, status cfTypes.ResourceStatus, reason string) cfTypes.StackEvent {
+		return cfTypes.StackEvent{
+			LogicalResourceId:    aws.String(logicalId),
+			PhysicalResourceId:   aws.String(logicalId + "-1a2b"),
+			ResourceType:         aws.String("AWS::S3::Bucket"),
+			ResourceStatus:       status,
+			ResourceStatusReason: aws.String(reason),
+		}
+	}
+	stackEvent := func(status cfTypes.ResourceStatus) cfTypes.StackEvent {
+		return cfTypes.StackEvent{
+			LogicalResourceId:  aws.String("my-bucket"),
+			PhysicalResourceId: aws.String(testStackID),
+			ResourceType:       aws.String("AWS::CloudFormation::Stack"),
+			ResourceStatus:     status,
+		}
+	}
+	cfn.events[testStackID] = []cfTypes.StackEvent{
+		stackEvent(cfTypes.ResourceStatusUpdateRollbackComplete),
+		stackEvent(cfTypes.ResourceStatusUpdateRollbackInProgress),
+		resourceEvent("Logs", cfTypes.ResourceStatusUpdateFailed, "Resource update cancelled"),
+		resourceEvent("Bucket", cfTypes.ResourceStatusUpdateFailed, "Bucket policy is invalid"),
+		resourceEvent("Archive", cfTypes.ResourceStatusUpdateComplete, ""),
+		stackEvent(cfTypes.ResourceStatusUpdateInProgress),
+		// Failures of earlier operations are left out
+		resourceEvent("Archive", cfTypes.ResourceStatusCreateFailed, "Archive already exists"),
+	}
+
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Stack is UPDATE_ROLLBACK_COMPLETE: Bucket (AWS::S3::Bucket) UPDATE_FAILED: Bucket policy is invalid"
+	condition := meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionReady)
+	if condition == nil || condition.Message != expected {
+		t.Fatalf("expected the failed resource in the Ready condition, got %v", instance.Status.Conditions)
+	}
+
+	// The failed resource is kept while the stack stays in the status
+	cfn.events[testStackID] = nil
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if condition = meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionReady); condition.Message != expected {
+		t.Errorf("expected the failed resource kept, got %s", condition.Message)
+	}
+}
+
+func TestFollowerRecordsBoundedHistory(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	follower := newTestFollower(k8sClient, cfn)
+
+	statuses := []cfTypes.StackStatus{cfTypes.StackStatusUpdateInProgress, cfTypes.StackStatusUpdateComplete}
+	for i := 0; i < maxStatusHistory; i++ {
+		for _, status := range statuses {
+			cfn.addStack("my-bucket", testStackID, status).StackStatusReason = aws.String("pass " + string(status))
+			if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// Observing the same status again is not a transition
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+
+	history := instance.Status.History
+	if len(history) != maxStatusHistory {
+		t.Fatalf("expected the history capped at %d, got %d", maxStatusHistory, len(history))
+	}
+	last := history[len(history)-1]
+	if last.Status != "UPDATE_COMPLETE" || last.Reason != "pass UPDATE_COMPLETE" || last.Time.IsZero() {
+		t.Errorf("unexpected latest transition %v", last)
+	}
+	if history[len(history)-2].Status != "UPDATE_IN_PROGRESS" {
+		t.Errorf("expected transitions oldest first, got %v", history)
+	}
+}
+
+func TestFollowerGaugesStacksByStatus(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_IN_PROGRESS"},
+	}
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateInProgress)
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	follower.StacksByStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_by_status"}, []string{"status"})
+	following := func(status cfTypes.StackStatus) float64 {
+		return testutil.ToFloat64(follower.StacksByStatus.WithLabelValues(string(status)))
+	}
+
+	follower.startFollowing(instance)
+	follower.mapPollingList.Range(follower.processStack)
+	follower.mapPollingList.Range(follower.processStack)
+	if following(cfTypes.StackStatusUpdateInProgress) != 1 {
+		t.Fatalf("expected one stack in UPDATE_IN_PROGRESS, got %v", following(cfTypes.StackStatusUpdateInProgress))
+	}
+
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateRollbackInProgress)
+	follower.mapPollingList.Range(follower.processStack)
+	if following(cfTypes.StackStatusUpdateInProgress) != 0 || following(cfTypes.StackStatusUpdateRollbackInProgress) != 1 {
+		t.Errorf("expected the stack moved to UPDATE_ROLLBACK_IN_PROGRESS")
+	}
+
+	// Stacks no longer followed once they settle
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateRollbackComplete)
+	follower.mapPollingList.Range(follower.processStack)
+	for _, status := range []cfTypes.StackStatus{cfTypes.StackStatusUpdateRollbackInProgress,
+		cfTypes.StackStatusUpdateRollbackComplete} {
+		if following(status) != 0 {
+			t.Errorf("expected no stack followed in %s, got %v", status, following(status))
+		}
+	}
+}
+
+func TestFollowerCancelsTimedOutUpdate(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket",
+			UpdateTimeout: &metav1.Duration{Duration: 30 * time.Minute}},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_IN_PROGRESS"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateInProgress)
+	stack.LastUpdatedTime = aws.Time(time.Now().Add(-10 * time.Minute))
+	follower := newTestFollower(k8sClient, cfn)
+
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.cancelInputs) != 0 {
+		t.Fatalf("expected the update within its timeout to run on, got %d cancels", len(cfn.cancelInputs))
+	}
+
+	stack.LastUpdatedTime = aws.Time(time.Now().Add(-45 * time.Minute))
+	for i := 0; i < 2; i++ {
+		if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(cfn.cancelInputs) != 1 || aws.ToString(cfn.cancelInputs[0].StackName) != testStackID {
+		t.Fatalf("expected the update cancelled once, got %v", cfn.cancelInputs)
+	}
+	if !meta.IsStatusConditionTrue(instance.Status.Conditions, v1alpha1.ConditionUpdateTimedOut) {
+		t.Errorf("expected the UpdateTimedOut condition, got %v", instance.Status.Conditions)
+	}
+
+	// Cleared once an update completes
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete)
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionUpdateTimedOut) != nil {
+		t.Errorf("expected the UpdateTimedOut condition removed")
+	}
+}
+
+func TestFollowerWarnsOfResourceLimit(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_IN_PROGRESS"},
+	}
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete)
+	for i := 0; i < resourceLimitWarning; i++ {
+		cfn.resources[testStackID] = append(cfn.resources[testStackID], cfTypes.StackResourceSummary{
+			LogicalResourceId: aws.String(fmt.Sprintf("Bucket%d", i)),
+			ResourceType:      aws.String("AWS::S3::Bucket"),
+			ResourceStatus:    cfTypes.ResourceStatusCreateComplete,
+		})
+	}
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	events := follower.Recorder.(*record.FakeRecorder).Events
+
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.ResourceCount != resourceLimitWarning {
+		t.Errorf("expected %d resources counted, got %d", resourceLimitWarning, instance.Status.ResourceCount)
+	}
+	select {
+	case event := <-events:
+		if !strings.Contains(event, "ResourceLimitApproaching") {
+			t.Errorf("unexpected event %q", event)
+		}
+	default:
+		t.Fatal("expected a warning of the resource limit")
+	}
+
+	// Warned only when crossing the threshold
+	cfn.resources[testStackID] = append(cfn.resources[testStackID], cfTypes.StackResourceSummary{
+		LogicalResourceId: aws.String("Topic"),
+		ResourceType:      aws.String("AWS::SNS::Topic"),
+		ResourceStatus:    cfTypes.ResourceStatusCreateComplete,
+	})
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("expected a single warning, got %q", <-events)
+	}
+}
+
+func TestFollowerSurfacesNestedStacks(t *testing.T) {
+	const childStackID = "arn:aws:cloudformation:us-east-1:123456789012:stack/my-bucket-Network-1A2B3C/9f3e1d2c"
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_IN_PROGRESS"},
+	}
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete)
+	cfn.resources[testStackID] = []cfTypes.StackResourceSummary{
+		{
+			LogicalResourceId:  aws.String("Bucket"),
+			PhysicalResourceId: aws.String("my-bucket"),
+			ResourceType:       aws.String("AWS::S3::Bucket"),
+			ResourceStatus:     cfTypes.ResourceStatusCreateComplete,
+		},
+		{
+			LogicalResourceId:  aws.String("Network"),
+			PhysicalResourceId: aws.String(childStackID),
+			ResourceType:       aws.String("AWS::CloudFormation::Stack"),
+			ResourceStatus:     cfTypes.ResourceStatusUpdateComplete,
+		},
+	}
+	follower := newTestFollower(newFakeClient(instance), cfn)
+
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	nested := instance.Status.NestedStacks
+	if len(nested) != 1 || nested[0].LogicalId != "Network" || nested[0].StackID != childStackID ||
+		nested[0].Status != "UPDATE_COMPLETE" {
+		t.Fatalf("expected the Network child stack, got %v", nested)
+	}
+	if !meta.IsStatusConditionTrue(instance.Status.Conditions, v1alpha1.ConditionReady) {
+		t.Errorf("expected the stack Ready, got %v", instance.Status.Conditions)
+	}
+
+	// A failed child stack degrades the parent
+	cfn.resources[testStackID][1].ResourceStatus = cfTypes.ResourceStatusDeleteFailed
+	cfn.resources[testStackID][1].ResourceStatusReason = aws.String("Subnet in use")
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionReady)
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != "NestedStackFailed" ||
+		!strings.Contains(condition.Message, "Subnet in use") {
+		t.Errorf("expected the stack degraded by its child, got %v", condition)
+	}
+}
+
+func TestFollowerPollMetrics(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_IN_PROGRESS"},
+	}
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateInProgress)
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	follower.PollDuration = prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_poll_duration"})
+	follower.PollErrors = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_poll_errors"},
+		[]string{"namespace", "name"})
+	follower.startFollowing(instance)
+
+	follower.pollFollowed()
+	cfn.describeErr = fmt.Errorf("operation error CloudFormation: DescribeStacks, Throttling: Rate exceeded")
+	follower.pollFollowed()
+
+	polls := &dto.Metric{}
+	if err := follower.PollDuration.Write(polls); err != nil {
+		t.Fatal(err)
+	}
+	if polls.Histogram.GetSampleCount() != 2 {
+		t.Errorf("expected two polls timed, got %d", polls.Histogram.GetSampleCount())
+	}
+	if errors := testutil.ToFloat64(follower.PollErrors.WithLabelValues("default", "my-bucket")); errors != 1 {
+		t.Errorf("expected one poll error for the stack, got %v", errors)
+	}
+}
+
+func TestFollowerRecordsUpdatedTimeWithinStatus(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID},
+	}
+	cfn := newFakeCloudFormation()
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	firstUpdate := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete).LastUpdatedTime = aws.Time(firstUpdate)
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if !instance.Status.CreatedTime.Time.Equal(fakeCreationTime) || !instance.Status.UpdatedTime.Time.Equal(firstUpdate) {
+		t.Fatalf("expected the creation and update times, got %v, %v", instance.Status.CreatedTime,
+			instance.Status.UpdatedTime)
+	}
+
+	// Updated again, the stack is back to UPDATE_COMPLETE by the next poll
+	secondUpdate := firstUpdate.Add(time.Hour)
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete).LastUpdatedTime = aws.Time(secondUpdate)
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if !instance.Status.UpdatedTime.Time.Equal(secondUpdate) {
+		t.Errorf("expected the update time of the second update, got %v", instance.Status.UpdatedTime)
+	}
+	if len(instance.Status.History) != 1 {
+		t.Errorf("expected no status transition recorded, got %v", instance.Status.History)
+	}
+}
+
+func TestFollowerRecordsResolvedParameters(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-instance", Namespace: "default"},
+		Spec: v1alpha1.StackSpec{StackName: "my-instance",
+			Parameters: map[string]string{"ImageId": "/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64"}},
+		Status: v1alpha1.StackStatus{StackID: testStackID},
+	}
+	cfn := newFakeCloudFormation()
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	cfn.addStack("my-instance", testStackID, cfTypes.StackStatusCreateComplete).Parameters = []cfTypes.Parameter{
+		{ParameterKey: aws.String("ImageId"), ParameterValue: aws.String(instance.Spec.Parameters["ImageId"]),
+			ResolvedValue: aws.String("ami-0123456789abcdef0")},
+		{ParameterKey: aws.String("InstanceType"), ParameterValue: aws.String("t3.micro")},
+	}
+
+	// Not permitted, the resolved values are left out
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.ResolvedParameters != nil {
+		t.Fatalf("expected no resolved parameters recorded, got %v", instance.Status.ResolvedParameters)
+	}
+
+	follower.RecordResolvedParameters = true
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"ImageId": "ami-0123456789abcdef0"}
+	if !reflect.DeepEqual(instance.Status.ResolvedParameters, expected) {
+		t.Errorf("expected %v, got %v", expected, instance.Status.ResolvedParameters)
+	}
+}
+
+func TestFollowerRecordsNotificationsAndRollbackConfiguration(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID},
+	}
+	cfn := newFakeCloudFormation()
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	stack.NotificationARNs = []string{"arn:aws:sns:us-east-1:123456789012:stack-events"}
+	stack.RollbackConfiguration = &cfTypes.RollbackConfiguration{
+		MonitoringTimeInMinutes: aws.Int32(10),
+		RollbackTriggers: []cfTypes.RollbackTrigger{
+			{Arn: aws.String("arn:aws:cloudwatch:us-east-1:123456789012:alarm:errors"),
+				Type: aws.String("AWS::CloudWatch::Alarm")},
+		},
+	}
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(instance.Status.NotificationARNs, stack.NotificationARNs) {
+		t.Errorf("expected the notification ARNs %v, got %v", stack.NotificationARNs, instance.Status.NotificationARNs)
+	}
+	expected := &v1alpha1.RollbackConfiguration{
+		MonitoringTimeInMinutes: aws.Int32(10),
+		RollbackTriggers: []v1alpha1.RollbackTrigger{
+			{Arn: "arn:aws:cloudwatch:us-east-1:123456789012:alarm:errors", Type: "AWS::CloudWatch::Alarm"},
+		},
+	}
+	if !reflect.DeepEqual(instance.Status.RollbackConfiguration, expected) {
+		t.Errorf("expected the rollback configuration %v, got %v", expected, instance.Status.RollbackConfiguration)
+	}
+
+	// Both removed from the stack, neither is left in the status
+	stack.NotificationARNs = nil
+	stack.RollbackConfiguration = &cfTypes.RollbackConfiguration{}
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.NotificationARNs != nil || instance.Status.RollbackConfiguration != nil {
+		t.Errorf("expected the notification ARNs and rollback configuration cleared, got %v, %v",
+			instance.Status.NotificationARNs, instance.Status.RollbackConfiguration)
+	}
+}
+
+func TestStackProgress(t *testing.T) {
+	stackEvent := func(status cfTypes.ResourceStatus) cfTypes.StackEvent {
+		return cfTypes.StackEvent{LogicalResourceId: aws.String("my-bucket"), PhysicalResourceId: aws.String(testStackID),
+			ResourceType: aws.String("AWS::CloudFormation::Stack"), ResourceStatus: status}
+	}
+	resourceEvent := func(logicalId string, status cfTypes.ResourceStatus) cfTypes.StackEvent {
+		return cfTypes.StackEvent{LogicalResourceId: aws.String(logicalId), ResourceType: aws.String("AWS::S3::Bucket"),
+			ResourceStatus: status}
+	}
+	tests := []struct {
+		name     string
+		events   []cfTypes.StackEvent
+		expected string
+	}{
+		{
+			name:     "operation just started",
+			events:   []cfTypes.StackEvent{stackEvent(cfTypes.ResourceStatusUpdateInProgress)},
+			expected: "",
+		},
+		{
+			name: "resources in progress and completed",
+			events: []cfTypes.StackEvent{
+				resourceEvent("Queue", cfTypes.ResourceStatusCreateInProgress),
+				resourceEvent("Bucket", cfTypes.ResourceStatusUpdateComplete),
+				resourceEvent("Bucket", cfTypes.ResourceStatusUpdateInProgress),
+				stackEvent(cfTypes.ResourceStatusUpdateInProgress),
+			},
+			expected: "1/2",
+		},
+		{
+			name: "failed resources are settled",
+			events: []cfTypes.StackEvent{
+				resourceEvent("Queue", cfTypes.ResourceStatusCreateFailed),
+				resourceEvent("Bucket", cfTypes.ResourceStatusCreateComplete),
+				resourceEvent("Queue", cfTypes.ResourceStatusCreateInProgress),
+				resourceEvent("Bucket", cfTypes.ResourceStatusCreateInProgress),
+				stackEvent(cfTypes.ResourceStatusCreateInProgress),
+			},
+			expected: "2/2",
+		},
+		{
+			name: "hook events are not resources",
+			events: []cfTypes.StackEvent{
+				{LogicalResourceId: aws.String("Bucket"), HookType: aws.String("MyCompany::Governance::BucketPolicy"),
+					HookStatus: cfTypes.HookStatusHookCompleteSucceeded},
+				resourceEvent("Bucket", cfTypes.ResourceStatusUpdateInProgress),
+				stackEvent(cfTypes.ResourceStatusUpdateInProgress),
+			},
+			expected: "0/1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if progress := stackProgress(testStackID, tt.events); progress != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, progress)
+			}
+		})
+	}
+}
+
+func TestFollowerProgressOnlyDuringOperations(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_COMPLETE"},
+	}
+	cfn := newFakeCloudFormation()
+	stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateInProgress)
+	// Of the two resources of the stack, the update only touches the queue
+	cfn.resources[testStackID] = []cfTypes.StackResourceSummary{
+		{LogicalResourceId: aws.String("Bucket"), ResourceType: aws.String("AWS::S3::Bucket"),
+			ResourceStatus: cfTypes.ResourceStatusCreateComplete},
+		{LogicalResourceId: aws.String("Queue"), ResourceType: aws.String("AWS::SQS::Queue"),
+			ResourceStatus: cfTypes.ResourceStatusUpdateInProgress},
+	}
+	cfn.events[testStackID] = []cfTypes.StackEvent{
+		{LogicalResourceId: aws.String("Queue"), ResourceType: aws.String("AWS::SQS::Queue"),
+			ResourceStatus: cfTypes.ResourceStatusUpdateInProgress},
+		{LogicalResourceId: aws.String("my-bucket"), PhysicalResourceId: aws.String(testStackID),
+			ResourceType: aws.String("AWS::CloudFormation::Stack"), ResourceStatus: cfTypes.ResourceStatusUpdateInProgress},
+	}
+	follower := newTestFollower(newFakeClient(instance), cfn)
+
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.Progress != "0/1" {
+		t.Errorf("expected the untouched bucket left out of the progress, got %q", instance.Status.Progress)
+	}
+
+	stack.StackStatus = cfTypes.StackStatusUpdateComplete
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.Progress != "" {
+		t.Errorf("expected no progress once the operation is done, got %q", instance.Status.Progress)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	coreerrors "errors"
+	"strings"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// Annotation allowing a stack stuck in DELETE_FAILED to be deleted retaining the failing resources or, at last
+	// resort, to be abandoned
+	forceDeleteAnnotation = "cloudformation.services.k8s.aws.cuppett.dev/force-delete"
+
+	// Default number of failed deletions before a stack is force deleted
+	DefaultForceDeleteAttempts = 3
+)
+
+var ErrStackAbandoned = coreerrors.New("stack could not be deleted and was abandoned")
+
+// failingResources lists the logical IDs of the resources which failed to delete.
+func failingResources(loop *StackLoop) []string {
+	var failing []string
+	for _, resource := range loop.instance.Status.Resources {
+		if resource.Status == string(cfTypes.ResourceStatusDeleteFailed) {
+			failing = append(failing, resource.LogicalId)
+		}
+	}
+	return failing
+}
+
+// forceDelete counts the deletions of a stack ending in DELETE_FAILED. Once the force-delete annotation is set and
+// ForceDeleteAttempts deletions failed, the deletion is retried retaining the failing resources. Should that fail as
+// well, the finalizer is removed leaving the stack behind. Returns true when the deletion was handled here, otherwise
+// the deletion is to be retried as usual.
+func (r *StackReconciler) forceDelete(loop *StackLoop) (bool, error) {
+	loop.instance.Status.DeleteAttempts++
+	attempts := int(loop.instance.Status.DeleteAttempts)
+	if loop.instance.Annotations[forceDeleteAnnotation] != "true" || r.ForceDeleteAttempts <= 0 ||
+		attempts < r.ForceDeleteAttempts {
+		return false, nil
+	}
+
+	if retain := failingResources(loop); attempts == r.ForceDeleteAttempts && len(retain) > 0 {
+		loop.Log.Info("Retrying deletion retaining the failing resources", "resources", retain)
+		r.Recorder.Eventf(loop.instance, v1.EventTypeWarning, "RetainingResources",
+			"Deleting the stack retaining the resources failing to delete: %s", strings.Join(retain, ", "))
+		return true, r.deleteStack(loop, retain)
+	}
+
+	loop.Log.Error(ErrStackAbandoned, "FORCE DELETE: removing the finalizer, the stack is left behind in CloudFormation",
+		"stackID", loop.instance.Status.StackID, "attempts", attempts)
+	r.Recorder.Eventf(loop.instance, v1.EventTypeWarning, "StackAbandoned",
+		"Stack %s could not be deleted after %d attempts and is left behind in CloudFormation",
+		loop.instance.Status.StackID, attempts)
+	controllerutil.RemoveFinalizer(loop.instance, stacksFinalizer)
+	return true, r.Update(loop.ctx, loop.instance)
+}
@@ -0,0 +1,138 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestForceDeleteRetainsThenAbandons(t *testing.T) {
+	now := metav1.Now()
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-queue", Namespace: "default", DeletionTimestamp: &now,
+			Finalizers: []string{stacksFinalizer}, Annotations: map[string]string{forceDeleteAnnotation: "true"}},
+		Spec: v1alpha1.StackSpec{StackName: "my-queue", Template: testTemplate},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "DELETE_FAILED",
+			Resources: []v1alpha1.StackResource{
+				{LogicalId: "Queue", Type: "AWS::SQS::Queue", Status: "DELETE_FAILED"},
+				{LogicalId: "Topic", Type: "AWS::SNS::Topic", Status: "DELETE_COMPLETE"},
+			}},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-queue", testStackID, cfTypes.StackStatusDeleteFailed).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	r := newTestReconciler(k8sClient, cfn)
+	r.ForceDeleteAttempts = 2
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-queue", Namespace: "default"}}
+
+	// Each deletion fails again as observed by the follower
+	failAgain := func() {
+		stack := &v1alpha1.Stack{}
+		if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+			t.Fatal(err)
+		}
+		stack.Status.StackStatus = "DELETE_FAILED"
+		if err := k8sClient.Status().Update(context.TODO(), stack); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	// Reconciling again before the deletion failed again is not another attempt
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	failAgain()
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.deleteInputs) != 3 {
+		t.Fatalf("expected 3 deletions, got %d", len(cfn.deleteInputs))
+	}
+	if retain := cfn.deleteInputs[1].RetainResources; len(retain) != 0 {
+		t.Errorf("expected nothing retained before the attempts ran out, got %v", retain)
+	}
+	if retain := cfn.deleteInputs[2].RetainResources; len(retain) != 1 || retain[0] != "Queue" {
+		t.Errorf("expected the failing resource to be retained, got %v", retain)
+	}
+
+	failAgain()
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.deleteInputs) != 3 {
+		t.Errorf("expected no further deletion once abandoned, got %d", len(cfn.deleteInputs))
+	}
+	err := k8sClient.Get(context.TODO(), req.NamespacedName, &v1alpha1.Stack{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected the finalizer to be removed and the resource gone, got %v", err)
+	}
+}
+
+func TestForceDeleteRequiresAnnotation(t *testing.T) {
+	now := metav1.Now()
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-queue", Namespace: "default", DeletionTimestamp: &now,
+			Finalizers: []string{stacksFinalizer}},
+		Spec:   v1alpha1.StackSpec{StackName: "my-queue", Template: testTemplate},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "DELETE_FAILED", DeleteAttempts: 5},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-queue", testStackID, cfTypes.StackStatusDeleteFailed).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	r := newTestReconciler(k8sClient, cfn)
+	r.ForceDeleteAttempts = 2
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-queue", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if !controllerutil.ContainsFinalizer(stack, stacksFinalizer) || len(cfn.deleteInputs) != 1 || cfn.deleteInputs[0].RetainResources != nil {
+		t.Errorf("expected a plain deletion keeping the finalizer, got %v", cfn.deleteInputs)
+	}
+	if stack.Status.DeleteAttempts != 6 {
+		t.Errorf("expected the failed deletion to be counted, got %d", stack.Status.DeleteAttempts)
+	}
+}
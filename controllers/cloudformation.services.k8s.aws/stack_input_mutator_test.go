@@ -0,0 +1,82 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// roleEnforcer is a mutator forcing every stack to use the same service role
+type roleEnforcer struct{}
+
+func (roleEnforcer) MutateCreateStackInput(_ context.Context, _ *v1alpha1.Stack, input *cloudformation.CreateStackInput) error {
+	input.RoleARN = aws.String("arn:aws:iam::123456789012:role/enforced")
+	return nil
+}
+
+func (roleEnforcer) MutateUpdateStackInput(_ context.Context, _ *v1alpha1.Stack, input *cloudformation.UpdateStackInput) error {
+	input.RoleARN = aws.String("arn:aws:iam::123456789012:role/enforced")
+	return nil
+}
+
+func TestInputMutatorAppliedBeforeSubmission(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec: v1alpha1.StackSpec{
+			StackName: "my-bucket",
+			Template:  "Resources: {}",
+			RoleARN:   "arn:aws:iam::123456789012:role/requested",
+		},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	r.InputMutator = roleEnforcer{}
+
+	loop := &StackLoop{ctx: context.TODO(), req: ctrl.Request{}, instance: instance, Log: logr.Discard()}
+	if err := r.createStack(loop); err != nil {
+		t.Fatal(err)
+	}
+	cfn.stacks["my-bucket"].StackStatus = cfTypes.StackStatusCreateComplete
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfn.createInputs) != 1 || aws.ToString(cfn.createInputs[0].RoleARN) != "arn:aws:iam::123456789012:role/enforced" {
+		t.Errorf("expected the create input to be mutated, got %v", cfn.createInputs)
+	}
+	if len(cfn.updateInputs) != 1 || aws.ToString(cfn.updateInputs[0].RoleARN) != "arn:aws:iam::123456789012:role/enforced" {
+		t.Errorf("expected the update input to be mutated, got %v", cfn.updateInputs)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"time"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Delay before rechecking a stack whose changes are deferred until further notice
+const maintenanceRecheckInterval = time.Minute
+
+// deferredByMaintenance holds the creation, update or deletion of the stack while the controller Config defers
+// changes, recording the Deferred condition. Returns how long to wait before checking again, zero when not deferred.
+func (r *StackReconciler) deferredByMaintenance(loop *StackLoop) (time.Duration, error) {
+	deferred, until := r.CloudFormationHelper.ConfigReconciler.ChangesDeferred(loop.ctx, time.Now())
+
+	var changed bool
+	var after time.Duration
+	if !deferred {
+		changed = removeCondition(loop.instance, v1alpha1.ConditionDeferred)
+	} else if until.IsZero() {
+		loop.Log.Info("Stack changes deferred until further notice")
+		changed = setCondition(loop.instance, v1alpha1.ConditionDeferred, metav1.ConditionTrue, "ChangesDeferred",
+			"Stack changes are deferred until further notice")
+		after = maintenanceRecheckInterval
+	} else {
+		loop.Log.Info("Stack changes deferred by a maintenance window", "until", until)
+		changed = setCondition(loop.instance, v1alpha1.ConditionDeferred, metav1.ConditionTrue, "MaintenanceWindow",
+			"Stack changes are deferred until the maintenance window closes at "+until.Format(time.RFC3339))
+		// Checking back just after the window closes
+		after = time.Until(until) + time.Second
+	}
+
+	if changed {
+		if err := r.updateStatus(loop); err != nil {
+			return after, err
+		}
+	}
+	return after, nil
+}
@@ -0,0 +1,91 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	servicesv1alpha1 "github.com/cuppett/aws-cloudformation-operator/apis/services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestChangesDeferredByConfig(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+	}
+	config := &servicesv1alpha1.Config{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec:       servicesv1alpha1.ConfigSpec{DeferStackChanges: true},
+	}
+	k8sClient := newFakeClient(instance, config)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	result, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 0 || result.RequeueAfter != maintenanceRecheckInterval {
+		t.Fatalf("expected the update deferred and rechecked, got %d updates and %v", len(cfn.updateInputs), result)
+	}
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(stack.Status.Conditions, v1alpha1.ConditionDeferred) {
+		t.Fatalf("expected the Deferred condition, got %v", stack.Status.Conditions)
+	}
+
+	// Resuming once changes are no longer deferred
+	config.Spec.DeferStackChanges = false
+	if err := k8sClient.Update(context.TODO(), config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Errorf("expected the update once resumed, got %d updates", len(cfn.updateInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionDeferred) != nil {
+		t.Errorf("expected the Deferred condition removed, got %v", stack.Status.Conditions)
+	}
+}
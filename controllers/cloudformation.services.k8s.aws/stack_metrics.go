@@ -0,0 +1,109 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Buckets of the template sizes, around the largest template body (51,200 bytes) and template URL (1 MiB) accepted
+var templateSizeBuckets = []float64{1024, 4096, 16384, 32768, 40960, 51200, 131072, 262144, 524288, 1048576}
+
+// StackMetrics counts the CloudFormation operations submitted for Stack resources. Labeling by namespace and name is
+// optional, keeping the cardinality down on clusters with many stacks.
+type StackMetrics struct {
+	Operations *prometheus.CounterVec
+	// Sizes of the inline templates submitted, and how many were uploaded to S3 as too large to submit directly
+	TemplateSizes   prometheus.Histogram
+	TemplateUploads prometheus.Counter
+	labelNamespace  bool
+	labelName       bool
+}
+
+// NewStackMetrics creates the operation counters, labeled by namespace and/or stack name as requested.
+func NewStackMetrics(labelNamespace bool, labelName bool) *StackMetrics {
+	labels := []string{"operation", "result"}
+	if labelNamespace {
+		labels = append(labels, "namespace")
+	}
+	if labelName {
+		labels = append(labels, "name")
+	}
+	return &StackMetrics{
+		Operations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cloudformation_stack_operations_total",
+				Help: "Total number of CloudFormation stack operations submitted",
+			},
+			labels,
+		),
+		TemplateSizes: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "cloudformation_template_size_bytes",
+				Help:    "Size of the inline templates submitted to CloudFormation",
+				Buckets: templateSizeBuckets,
+			},
+		),
+		TemplateUploads: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cloudformation_template_uploads_total",
+				Help: "Total number of inline templates uploaded to S3 as too large to submit directly",
+			},
+		),
+		labelNamespace: labelNamespace,
+		labelName:      labelName,
+	}
+}
+
+// ObserveOperation counts an operation (create, update or delete) submitted for the Stack resource.
+func (m *StackMetrics) ObserveOperation(instance *v1alpha1.Stack, operation string, err error) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	labels := prometheus.Labels{"operation": operation, "result": result}
+	if m.labelNamespace {
+		labels["namespace"] = instance.Namespace
+	}
+	if m.labelName {
+		labels["name"] = instance.Name
+	}
+	m.Operations.With(labels).Inc()
+}
+
+// ObserveTemplate records the size of an inline template submitted and whether it was uploaded to S3.
+func (m *StackMetrics) ObserveTemplate(template string, uploaded bool) {
+	if m == nil {
+		return
+	}
+	m.TemplateSizes.Observe(float64(len(template)))
+	if uploaded {
+		m.TemplateUploads.Inc()
+	}
+}
@@ -0,0 +1,99 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStackMetricsLabeledByNamespace(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "team-a"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+	}
+	r := newTestReconciler(newFakeClient(instance), newFakeCloudFormation())
+	r.Metrics = NewStackMetrics(true, false)
+	loop := &StackLoop{ctx: context.TODO(), instance: instance, Log: logr.Discard()}
+	if err := r.createStack(loop); err != nil {
+		t.Fatal(err)
+	}
+
+	count := testutil.ToFloat64(r.Metrics.Operations.WithLabelValues("create", "success", "team-a"))
+	if count != 1 {
+		t.Errorf("expected a create counted for the namespace, got %v", count)
+	}
+	if series := testutil.CollectAndCount(r.Metrics.Operations); series != 1 {
+		t.Errorf("expected a single series, got %d", series)
+	}
+}
+
+func TestStackMetricsUnlabeled(t *testing.T) {
+	metrics := NewStackMetrics(false, false)
+	for _, namespace := range []string{"team-a", "team-b"} {
+		instance := &v1alpha1.Stack{ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: namespace}}
+		metrics.ObserveOperation(instance, "delete", nil)
+	}
+	if count := testutil.ToFloat64(metrics.Operations.WithLabelValues("delete", "success")); count != 2 {
+		t.Errorf("expected both deletes in a single series, got %v", count)
+	}
+}
+
+func TestStackMetricsTemplateSizes(t *testing.T) {
+	metrics := NewStackMetrics(false, false)
+	metrics.ObserveTemplate(strings.Repeat("x", 2000), false)
+	metrics.ObserveTemplate(strings.Repeat("x", 50000), false)
+	metrics.ObserveTemplate(strings.Repeat("x", 60000), true)
+
+	expected := `
+# HELP cloudformation_template_size_bytes Size of the inline templates submitted to CloudFormation
+# TYPE cloudformation_template_size_bytes histogram
+cloudformation_template_size_bytes_bucket{le="1024"} 0
+cloudformation_template_size_bytes_bucket{le="4096"} 1
+cloudformation_template_size_bytes_bucket{le="16384"} 1
+cloudformation_template_size_bytes_bucket{le="32768"} 1
+cloudformation_template_size_bytes_bucket{le="40960"} 1
+cloudformation_template_size_bytes_bucket{le="51200"} 2
+cloudformation_template_size_bytes_bucket{le="131072"} 3
+cloudformation_template_size_bytes_bucket{le="262144"} 3
+cloudformation_template_size_bytes_bucket{le="524288"} 3
+cloudformation_template_size_bytes_bucket{le="1.048576e+06"} 3
+cloudformation_template_size_bytes_bucket{le="+Inf"} 3
+cloudformation_template_size_bytes_sum 112000
+cloudformation_template_size_bytes_count 3
+`
+	if err := testutil.CollectAndCompare(metrics.TemplateSizes, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+	if uploads := testutil.ToFloat64(metrics.TemplateUploads); uploads != 1 {
+		t.Errorf("expected one upload counted, got %v", uploads)
+	}
+}
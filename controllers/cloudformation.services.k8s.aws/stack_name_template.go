@@ -0,0 +1,112 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	coreerrors "errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+)
+
+// Placeholders of stack name templates and the longest value each can take
+var stackNamePlaceholders = map[string]int{
+	"{namespace}": 63,
+	"{name}":      maxGeneratedNameLength,
+	"{uid-short}": 8,
+	"{hash}":      8,
+}
+
+var (
+	ErrInvalidStackNameTemplate = coreerrors.New("stack name template may only contain letters, numbers, hyphens " +
+		"and the placeholders {namespace}, {name}, {uid-short} and {hash}, starting with a letter, {namespace} or {name}")
+	ErrStackNameTemplateNotUnique = coreerrors.New("stack name template must contain {uid-short} or {hash} to " +
+		"generate unique names")
+	ErrStackNameTemplateTooLong = coreerrors.New("stack name template with the prefix and suffix may generate names " +
+		"longer than 128 characters")
+	stackNameTemplatePart = regexp.MustCompile(`\{[^}]*\}|[^{]+`)
+	stackNameLiteral      = regexp.MustCompile(`^[-a-zA-Z0-9]+$`)
+)
+
+// ValidateStackNameTemplate ensures the stack name template generates valid, unique names within the length allowed
+// by CloudFormation.
+func (cf *CloudFormationHelper) ValidateStackNameTemplate() error {
+	if cf.StackNameTemplate == "" {
+		return nil
+	}
+
+	length := len(cf.StackNamePrefix) + len(cf.StackNameSuffix)
+	unique := false
+	parts := stackNameTemplatePart.FindAllString(cf.StackNameTemplate, -1)
+	if strings.Join(parts, "") != cf.StackNameTemplate {
+		return ErrInvalidStackNameTemplate
+	}
+	for i, part := range parts {
+		if maxLength, placeholder := stackNamePlaceholders[part]; placeholder {
+			length += maxLength
+			unique = unique || part == "{uid-short}" || part == "{hash}"
+			if i == 0 && cf.StackNamePrefix == "" && (part == "{uid-short}" || part == "{hash}") {
+				return ErrInvalidStackNameTemplate
+			}
+			continue
+		}
+		if !stackNameLiteral.MatchString(part) {
+			return ErrInvalidStackNameTemplate
+		}
+		if i == 0 && cf.StackNamePrefix == "" && !stackNamePrefixExpression.MatchString(part) {
+			return ErrInvalidStackNameTemplate
+		}
+		length += len(part)
+	}
+	if !unique {
+		return ErrStackNameTemplateNotUnique
+	}
+	if length > maxStackNameLength {
+		return ErrStackNameTemplateTooLong
+	}
+	return nil
+}
+
+// stackNameFromTemplate generates the stack name of the Stack resource from the stack name template.
+func (cf *CloudFormationHelper) stackNameFromTemplate(instance *v1alpha1.Stack) string {
+	name := instance.Name
+	if len(name) > maxGeneratedNameLength {
+		name = name[:maxGeneratedNameLength]
+	}
+	uid := strings.ReplaceAll(string(instance.UID), "-", "")
+	if len(uid) > 8 {
+		uid = uid[:8]
+	}
+	// Resource names may contain dots, stack names may not
+	replacer := strings.NewReplacer(
+		"{namespace}", instance.Namespace,
+		"{name}", strings.ReplaceAll(name, ".", "-"),
+		"{uid-short}", uid,
+		"{hash}", fmt.Sprintf("%08x", stackNameChecksum(instance)),
+	)
+	return cf.StackNamePrefix + replacer.Replace(cf.StackNameTemplate) + cf.StackNameSuffix
+}
@@ -0,0 +1,87 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStackNameTemplate(t *testing.T) {
+	instance := &v1alpha1.Stack{ObjectMeta: metav1.ObjectMeta{Name: "my.bucket", Namespace: "team-a",
+		UID: "3c7ae8f0-1b2c-4d5e-8f90-a1b2c3d4e5f6"}}
+
+	helper := &CloudFormationHelper{StackNameTemplate: "{namespace}-{name}-{uid-short}"}
+	if err := helper.ValidateStackNameTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	if stackName := helper.GetStackName(context.TODO(), instance, false); stackName != "team-a-my-bucket-3c7ae8f0" {
+		t.Errorf("unexpected stack name %s", stackName)
+	}
+
+	// The hash is the differentiator of the default names
+	helper = &CloudFormationHelper{StackNamePrefix: "prod-", StackNameTemplate: "{hash}-{name}"}
+	if err := helper.ValidateStackNameTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	defaultName := (&CloudFormationHelper{}).GetStackName(context.TODO(), instance, false)
+	hash := defaultName[strings.LastIndex(defaultName, "-")+1:]
+	if stackName := helper.GetStackName(context.TODO(), instance, false); stackName != "prod-"+hash+"-my-bucket" {
+		t.Errorf("unexpected stack name %s", stackName)
+	}
+
+	// Existing stacks are still named by stack ID
+	instance.Status.StackID = testStackID
+	if stackName := helper.GetStackName(context.TODO(), instance, true); stackName != testStackID {
+		t.Errorf("expected the stack ID, got %s", stackName)
+	}
+}
+
+func TestStackNameTemplateValidation(t *testing.T) {
+	cases := []struct {
+		helper   *CloudFormationHelper
+		expected error
+	}{
+		{&CloudFormationHelper{StackNameTemplate: "{name}-{hash}"}, nil},
+		{&CloudFormationHelper{StackNameTemplate: "k8s-{name}-{hash}-{uid-short}"}, nil},
+		{&CloudFormationHelper{StackNameTemplate: "{namespace}-{name}"}, ErrStackNameTemplateNotUnique},
+		{&CloudFormationHelper{StackNameTemplate: "{name}_{hash}"}, ErrInvalidStackNameTemplate},
+		{&CloudFormationHelper{StackNameTemplate: "{name}-{cluster}-{hash}"}, ErrInvalidStackNameTemplate},
+		{&CloudFormationHelper{StackNameTemplate: "{name}-{hash"}, ErrInvalidStackNameTemplate},
+		{&CloudFormationHelper{StackNameTemplate: "{hash}-{name}"}, ErrInvalidStackNameTemplate},
+		{&CloudFormationHelper{StackNameTemplate: "1-{name}-{hash}"}, ErrInvalidStackNameTemplate},
+		{&CloudFormationHelper{StackNamePrefix: strings.Repeat("x", 20),
+			StackNameTemplate: "{namespace}-{name}-{hash}"}, ErrStackNameTemplateTooLong},
+	}
+	for _, c := range cases {
+		if err := c.helper.ValidateStackNameTemplate(); err != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.helper.StackNameTemplate, c.expected, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"strings"
+	"time"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Weight of the latest operation in the average operation duration
+	operationDurationWeight = 0.3
+	// Operations taking this many times the average are reported as slow
+	slowOperationFactor = 2
+)
+
+// operationStart identifies when the operation the stack settled from started.
+func operationStart(cfs *cfTypes.Stack) *time.Time {
+	switch {
+	case strings.HasPrefix(string(cfs.StackStatus), "DELETE_"):
+		return cfs.DeletionTime
+	case cfs.LastUpdatedTime != nil:
+		return cfs.LastUpdatedTime
+	default:
+		return cfs.CreationTime
+	}
+}
+
+// recordOperationDuration records how long the operation took once the stack settles from it, folding it in the
+// rolling average. Operations much slower than usual are reported with a Warning event.
+func (f *StackFollower) recordOperationDuration(instance *v1alpha1.Stack, previousStatus string, cfs *cfTypes.Stack,
+	now time.Time) {
+	if previousStatus == "" || f.CloudFormationHelper.StackInTerminalState(cfTypes.StackStatus(previousStatus)) ||
+		!f.CloudFormationHelper.StackInTerminalState(cfs.StackStatus) {
+		return
+	}
+	started := operationStart(cfs)
+	if started == nil || started.After(now) {
+		return
+	}
+
+	duration := now.Sub(*started).Round(time.Second)
+	instance.Status.LastOperationDuration = &metav1.Duration{Duration: duration}
+	if instance.Status.AverageOperationDuration == nil {
+		instance.Status.AverageOperationDuration = &metav1.Duration{Duration: duration}
+		return
+	}
+
+	average := instance.Status.AverageOperationDuration.Duration
+	if f.Recorder != nil && duration > slowOperationFactor*average {
+		f.Recorder.Eventf(instance, corev1.EventTypeWarning, "SlowOperation",
+			"The stack took %s to reach %s, %s on average", duration, cfs.StackStatus, average)
+	}
+	average = time.Duration(operationDurationWeight*float64(duration) + (1-operationDurationWeight)*float64(average))
+	instance.Status.AverageOperationDuration = &metav1.Duration{Duration: average.Round(time.Second)}
+}
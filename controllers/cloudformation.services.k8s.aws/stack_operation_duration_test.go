@@ -0,0 +1,101 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestFollowerRecordsOperationDuration(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_IN_PROGRESS"},
+	}
+	cfn := newFakeCloudFormation()
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	recorder := follower.Recorder.(*record.FakeRecorder)
+
+	settle := func(took time.Duration) {
+		t.Helper()
+		cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateInProgress).LastUpdatedTime =
+			aws.Time(time.Now().Add(-took))
+		if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+			t.Fatal(err)
+		}
+		cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete).LastUpdatedTime =
+			aws.Time(time.Now().Add(-took))
+		if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settle(10 * time.Minute)
+	if instance.Status.LastOperationDuration == nil || instance.Status.LastOperationDuration.Duration != 10*time.Minute {
+		t.Fatalf("expected the update to take 10m, got %v", instance.Status.LastOperationDuration)
+	}
+	if instance.Status.AverageOperationDuration.Duration != 10*time.Minute {
+		t.Errorf("expected the first operation as average, got %v", instance.Status.AverageOperationDuration)
+	}
+
+	// A much slower operation moves the average and is reported
+	settle(30 * time.Minute)
+	if instance.Status.LastOperationDuration.Duration != 30*time.Minute {
+		t.Errorf("expected the update to take 30m, got %v", instance.Status.LastOperationDuration)
+	}
+	if instance.Status.AverageOperationDuration.Duration != 16*time.Minute {
+		t.Errorf("expected a 16m average, got %v", instance.Status.AverageOperationDuration)
+	}
+	select {
+	case event := <-recorder.Events:
+		if event != "Warning SlowOperation The stack took 30m0s to reach UPDATE_COMPLETE, 10m0s on average" {
+			t.Errorf("unexpected event %q", event)
+		}
+	default:
+		t.Error("expected the slow operation reported")
+	}
+
+	// Observing a settled stack for the first time is not timed
+	instance = &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID},
+	}
+	follower = newTestFollower(newFakeClient(instance), cfn)
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.LastOperationDuration != nil {
+		t.Errorf("expected no duration recorded, got %v", instance.Status.LastOperationDuration)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	coreerrors "errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Delay before retrying an operation failing on an account limit, which doesn't clear quickly
+	quotaRetryInterval = 15 * time.Minute
+)
+
+// permanentErrorCodes are the error codes of CloudFormation rejecting the request itself, submitting it again as is
+// fails the same way.
+var permanentErrorCodes = map[string]bool{
+	"ValidationError":                   true,
+	"InsufficientCapabilitiesException": true,
+	"AlreadyExistsException":            true,
+	"TokenAlreadyExistsException":       true,
+}
+
+// stackStatePattern matches CloudFormation refusing an operation for the state the stack is in at the time, e.g.
+// "Stack:arn:... is in UPDATE_IN_PROGRESS state and can not be updated."
+var stackStatePattern = regexp.MustCompile(`is in [A-Z_]+_(IN_PROGRESS|FAILED) state and can not be`)
+
+// IsStackStateError identifies a ValidationError refusing the operation for the stack being in progress or failed,
+// as when the stack was read from a stale cache or changed outside the controller. Submitted again once the stack
+// settles, the operation may well succeed.
+func IsStackStateError(err error) bool {
+	var apiErr smithy.APIError
+	return coreerrors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationError" &&
+		stackStatePattern.MatchString(apiErr.ErrorMessage())
+}
+
+// IsTransientError identifies errors from AWS worth retrying as is: throttling, faults on the AWS side, calls
+// running past CallTimeout and operations refused for the state the stack is in.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsCallTimeout(err) || IsStackStateError(err) ||
+		awsretry.IsErrorThrottles(awsretry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary ||
+		awsretry.IsErrorRetryables(awsretry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary {
+		return true
+	}
+	var apiErr smithy.APIError
+	return coreerrors.As(err, &apiErr) && apiErr.ErrorFault() == smithy.FaultServer
+}
+
+// IsPermanentError identifies errors from AWS rejecting the request outright, e.g. a ValidationError for a broken
+// template, which only a change to the spec resolves.
+func IsPermanentError(err error) bool {
+	var apiErr smithy.APIError
+	return !IsTransientError(err) && coreerrors.As(err, &apiErr) && permanentErrorCodes[apiErr.ErrorCode()]
+}
+
+// IsQuotaExceeded identifies errors from CloudFormation failing the operation on an account limit, e.g. the number of
+// stacks in the region.
+func IsQuotaExceeded(err error) bool {
+	return errorCode(err) == "LimitExceededException"
+}
+
+// quotaExceeded records the QuotaExceeded condition when the operation failed on an account limit, the operation
+// retried after quotaRetryInterval. Returns true when the error was one.
+func (r *StackReconciler) quotaExceeded(loop *StackLoop, err error) bool {
+	if !IsQuotaExceeded(err) {
+		return false
+	}
+	loop.Log.Info("Stack operation exceeds an account limit", "reason", err.Error(), "retryAfter", quotaRetryInterval)
+	loop.retryAfter = quotaRetryInterval
+	if setCondition(loop.instance, v1alpha1.ConditionQuotaExceeded, metav1.ConditionTrue, "LimitExceeded",
+		err.Error()) {
+		if err := r.updateStatus(loop); err != nil {
+			loop.Log.Error(err, "Failed to record the operation failure", "condition", v1alpha1.ConditionQuotaExceeded)
+		}
+		r.Recorder.Event(loop.instance, v1.EventTypeWarning, v1alpha1.ConditionQuotaExceeded, err.Error())
+	}
+	return true
+}
+
+// IsTemplateTooLarge identifies CloudFormation refusing an inline template over the TemplateBody size limit.
+func IsTemplateTooLarge(err error) bool {
+	var apiErr smithy.APIError
+	return coreerrors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationError" &&
+		strings.Contains(apiErr.ErrorMessage(), "templateBody") &&
+		strings.Contains(apiErr.ErrorMessage(), "length less than or equal to")
+}
+
+// templateTooLargeMessage guides the user to the ways of submitting a template over the TemplateBody size limit.
+func (r *StackReconciler) templateTooLargeMessage(loop *StackLoop) string {
+	return fmt.Sprintf("The inline template is %d bytes, over the %d bytes CloudFormation accepts inline: give it "+
+		"by templateUrl instead, or have the operator upload large templates to S3 (--template-upload-bucket)",
+		len(loop.instance.Spec.Template), maxTemplateBodySize)
+}
+
+// IsRegionUnavailable identifies errors from the CloudFormation endpoint of the region not being reachable at all
+// (DNS resolution or connection failures), as in a regional outage, rather than from the request being refused.
+func IsRegionUnavailable(err error) bool {
+	if err == nil || IsCallTimeout(err) {
+		return false
+	}
+	var sendErr *smithyhttp.RequestSendError
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	return coreerrors.As(err, &sendErr) || coreerrors.As(err, &dnsErr) ||
+		(coreerrors.As(err, &opErr) && opErr.Op == "dial")
+}
+
+// regionUnavailable records the RegionUnavailable condition when the operation failed on the CloudFormation endpoint
+// of the region being unreachable, naming the region to fail over to when FailoverRegions is set. The error is still
+// retried with backoff.
+func (r *StackReconciler) regionUnavailable(loop *StackLoop, err error) {
+	if !IsRegionUnavailable(err) {
+		return
+	}
+	region := r.CloudFormationHelper.GetRegion()
+	message := fmt.Sprintf("CloudFormation in %s is unreachable: %s", region, err.Error())
+	if next := r.nextFailoverRegion(region); next != "" {
+		message += fmt.Sprintf(" (failover region: %s)", next)
+	}
+	loop.Log.Info("Region unavailable", "region", region, "reason", err.Error())
+	if setCondition(loop.instance, v1alpha1.ConditionRegionUnavailable, metav1.ConditionTrue, "EndpointUnreachable",
+		message) {
+		if err := r.updateStatus(loop); err != nil {
+			loop.Log.Error(err, "Failed to record the operation failure", "condition",
+				v1alpha1.ConditionRegionUnavailable)
+		}
+		r.Recorder.Event(loop.instance, v1.EventTypeWarning, v1alpha1.ConditionRegionUnavailable, message)
+	}
+}
+
+// nextFailoverRegion provides the region following the given one in FailoverRegions, wrapping around, or the first
+// of the list when the region isn't in it. Empty when no failover regions are configured.
+func (r *StackReconciler) nextFailoverRegion(region string) string {
+	for i, candidate := range r.FailoverRegions {
+		if candidate == region {
+			if next := r.FailoverRegions[(i+1)%len(r.FailoverRegions)]; next != region {
+				return next
+			}
+			return ""
+		}
+	}
+	if len(r.FailoverRegions) > 0 {
+		return r.FailoverRegions[0]
+	}
+	return ""
+}
+
+// errorCode provides the AWS error code of the error, empty when it isn't one.
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if coreerrors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+// rejected identifies a Stack the latest operation of which CloudFormation rejected, with no change to the spec since.
+func rejected(instance *v1alpha1.Stack) bool {
+	return currentCondition(instance, v1alpha1.ConditionRejected)
+}
+
+// templateTooLarge identifies a Stack the inline template of which CloudFormation refused as too large, with no change
+// to the spec since.
+func templateTooLarge(instance *v1alpha1.Stack) bool {
+	return currentCondition(instance, v1alpha1.ConditionTemplateTooLarge)
+}
+
+// currentCondition identifies a condition holding for the current generation of the Stack.
+func currentCondition(instance *v1alpha1.Stack, conditionType string) bool {
+	condition := meta.FindStatusCondition(instance.Status.Conditions, conditionType)
+	return condition != nil && condition.Status == metav1.ConditionTrue && condition.ObservedGeneration == instance.Generation
+}
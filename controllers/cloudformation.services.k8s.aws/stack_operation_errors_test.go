@@ -0,0 +1,391 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestOperationErrorClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+		permanent bool
+	}{
+		{"throttled", &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}, true, false},
+		{"server fault", &smithy.GenericAPIError{Code: "InternalFailure", Fault: smithy.FaultServer}, true, false},
+		{"timed out", fmt.Errorf("%w: deadline exceeded", ErrCallTimeout), true, false},
+		{"validation", &smithy.GenericAPIError{Code: "ValidationError", Message: "Template format error"}, false, true},
+		{"wrapped validation", fmt.Errorf("operation error CloudFormation: CreateStack, %w",
+			&smithy.GenericAPIError{Code: "InsufficientCapabilitiesException"}), false, true},
+		{"stack updating", &smithy.GenericAPIError{Code: "ValidationError",
+			Message: "Stack:" + testStackID + " is in UPDATE_IN_PROGRESS state and can not be updated."}, true, false},
+		{"rollback failed", &smithy.GenericAPIError{Code: "ValidationError",
+			Message: "Stack:" + testStackID + " is in UPDATE_ROLLBACK_FAILED state and can not be updated."}, true,
+			false},
+		{"access denied", &smithy.GenericAPIError{Code: "AccessDenied"}, false, false},
+		{"untyped", fmt.Errorf("ValidationError: Template format error"), false, false},
+		{"none", nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransientError(tt.err); got != tt.transient {
+				t.Errorf("IsTransientError() = %v, want %v", got, tt.transient)
+			}
+			if got := IsPermanentError(tt.err); got != tt.permanent {
+				t.Errorf("IsPermanentError() = %v, want %v", got, tt.permanent)
+			}
+		})
+	}
+}
+
+func TestUpdateRefusedWhileInProgressRetried(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Generation: 2,
+			Finalizers: []string{stacksFinalizer}},
+		Spec:   v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	// Updated outside the controller since the stack was cached
+	cfn.updateErr = &smithy.GenericAPIError{Code: "ValidationError",
+		Message: "Stack:" + testStackID + " is in UPDATE_IN_PROGRESS state and can not be updated."}
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); !IsStackStateError(err) {
+		t.Fatalf("expected the update retried with backoff, got %v", err)
+	}
+	if r.ChannelHub.FollowQueue.Len() != 1 {
+		t.Error("expected the stack followed until it settles")
+	}
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionRejected) != nil {
+		t.Fatalf("expected no Rejected condition, got %v", stack.Status.Conditions)
+	}
+
+	// Settled, the update goes through
+	cfn.updateErr = nil
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 2 {
+		t.Errorf("expected the update submitted again, got %d updates", len(cfn.updateInputs))
+	}
+}
+
+func TestCreateRejectedNotRetried(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Generation: 1,
+			Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.createErr = &smithy.GenericAPIError{Code: "ValidationError", Message: "Template format error: unsupported structure."}
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	result, err := r.Reconcile(context.TODO(), req)
+	if err != nil || result.Requeue || result.RequeueAfter > 0 {
+		t.Fatalf("expected the rejected create not requeued, got %v, %v", result, err)
+	}
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionRejected)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != "ValidationError" {
+		t.Fatalf("expected the Rejected condition, got %v", stack.Status.Conditions)
+	}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the rejected create not submitted again, got %d creates", len(cfn.createInputs))
+	}
+
+	// Fixing the spec submits it again
+	cfn.createErr = nil
+	stack.Spec.Template = testTemplate + "  Queue:\n    Type: AWS::SQS::Queue\n"
+	stack.Generation = 2
+	if err := k8sClient.Update(context.TODO(), stack); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 2 {
+		t.Fatalf("expected the fixed spec submitted, got %d creates", len(cfn.createInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionRejected) != nil {
+		t.Errorf("expected the Rejected condition cleared, got %v", stack.Status.Conditions)
+	}
+}
+
+func TestUpdateThrottledRequeued(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	cfn.updateErr = &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err == nil {
+		t.Fatal("expected the throttled update returned for a retry with backoff")
+	}
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionRejected) != nil {
+		t.Errorf("expected no Rejected condition for a throttled update, got %v", stack.Status.Conditions)
+	}
+}
+
+func TestCreateQuotaExceededBacksOff(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Generation: 1,
+			Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.createErr = &smithy.GenericAPIError{Code: "LimitExceededException",
+		Message: "Limit on the number of stacks has been exceeded"}
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	result, err := r.Reconcile(context.TODO(), req)
+	if err != nil || result.RequeueAfter != quotaRetryInterval {
+		t.Fatalf("expected the create retried after %v, got %v, %v", quotaRetryInterval, result, err)
+	}
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionQuotaExceeded)
+	if condition == nil || condition.Status != metav1.ConditionTrue ||
+		condition.Message != cfn.createErr.Error() {
+		t.Fatalf("expected the QuotaExceeded condition, got %v", stack.Status.Conditions)
+	}
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionRejected) != nil {
+		t.Errorf("expected the quota error not rejecting the spec, got %v", stack.Status.Conditions)
+	}
+
+	// The limit raised, the retry creates the stack
+	cfn.createErr = nil
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 2 {
+		t.Fatalf("expected the create retried, got %d creates", len(cfn.createInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionQuotaExceeded) != nil {
+		t.Errorf("expected the QuotaExceeded condition cleared, got %v", stack.Status.Conditions)
+	}
+}
+
+func TestRegionUnavailable(t *testing.T) {
+	unreachable := &smithyhttp.RequestSendError{Err: &net.OpError{Op: "dial", Net: "tcp",
+		Err: errors.New("connection refused")}}
+	tests := []struct {
+		name        string
+		err         error
+		unavailable bool
+	}{
+		{"connection refused", unreachable, true},
+		{"no such host", &net.DNSError{Err: "no such host", Name: "cloudformation.us-east-1.amazonaws.com"}, true},
+		{"call timeout", fmt.Errorf("%w: %v", ErrCallTimeout, unreachable), false},
+		{"refused request", &smithy.GenericAPIError{Code: "ValidationError", Message: "Template format error"}, false},
+		{"throttled", &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRegionUnavailable(tt.err); got != tt.unavailable {
+				t.Errorf("IsRegionUnavailable() = %v, expected %v", got, tt.unavailable)
+			}
+		})
+	}
+}
+
+func TestNextFailoverRegion(t *testing.T) {
+	r := &StackReconciler{FailoverRegions: []string{"us-east-1", "us-west-2", "us-east-2"}}
+	for region, expected := range map[string]string{
+		"us-east-1": "us-west-2",
+		"us-east-2": "us-east-1",
+		"eu-west-1": "us-east-1",
+	} {
+		if got := r.nextFailoverRegion(region); got != expected {
+			t.Errorf("nextFailoverRegion(%s) = %s, expected %s", region, got, expected)
+		}
+	}
+	if got := (&StackReconciler{FailoverRegions: []string{"us-east-1"}}).nextFailoverRegion("us-east-1"); got != "" {
+		t.Errorf("expected no failover from the only region, got %s", got)
+	}
+	if got := (&StackReconciler{}).nextFailoverRegion("us-east-1"); got != "" {
+		t.Errorf("expected no failover region without the option, got %s", got)
+	}
+}
+
+func TestCreateRegionUnavailable(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Generation: 1,
+			Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.createErr = &smithyhttp.RequestSendError{Err: &net.OpError{Op: "dial", Net: "tcp",
+		Err: errors.New("connection refused")}}
+	r := newTestReconciler(k8sClient, cfn)
+	r.CloudFormationHelper.Region = "us-east-1"
+	r.FailoverRegions = []string{"us-east-1", "us-west-2"}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err == nil {
+		t.Fatal("expected the create retried with backoff")
+	}
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionRegionUnavailable)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != "EndpointUnreachable" ||
+		!strings.Contains(condition.Message, "us-east-1 is unreachable") ||
+		!strings.Contains(condition.Message, "failover region: us-west-2") {
+		t.Fatalf("expected the RegionUnavailable condition naming the failover region, got %v",
+			stack.Status.Conditions)
+	}
+
+	// The region back, the retry creates the stack
+	cfn.createErr = nil
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionRegionUnavailable) != nil {
+		t.Errorf("expected the RegionUnavailable condition cleared, got %v", stack.Status.Conditions)
+	}
+}
+
+func TestCreateTemplateTooLarge(t *testing.T) {
+	template := testTemplate + "# " + strings.Repeat("padding ", 8000) + "\n"
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Generation: 1,
+			Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: template},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.createErr = &smithy.GenericAPIError{Code: "ValidationError", Message: "1 validation error detected: " +
+		"Value '...' at 'templateBody' failed to satisfy constraint: Member must have length less than or equal to 51200"}
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionTemplateTooLarge)
+	expected := fmt.Sprintf("The inline template is %d bytes, over the 51200 bytes", len(template))
+	if condition == nil || condition.Status != metav1.ConditionTrue || !strings.HasPrefix(condition.Message, expected) ||
+		!strings.Contains(condition.Message, "templateUrl") {
+		t.Fatalf("expected the TemplateTooLarge condition with the sizes, got %v", stack.Status.Conditions)
+	}
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionRejected) != nil {
+		t.Errorf("expected the size error not reported as Rejected, got %v", stack.Status.Conditions)
+	}
+
+	// Not submitted again until the spec changes
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the create not retried, got %d creates", len(cfn.createInputs))
+	}
+
+	// The template trimmed, the stack is created and the condition cleared
+	cfn.createErr = nil
+	stack.Spec.Template = testTemplate
+	stack.Generation = 2
+	if err := k8sClient.Update(context.TODO(), stack); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 2 {
+		t.Fatalf("expected the create retried, got %d creates", len(cfn.createInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionTemplateTooLarge) != nil {
+		t.Errorf("expected the TemplateTooLarge condition cleared, got %v", stack.Status.Conditions)
+	}
+}
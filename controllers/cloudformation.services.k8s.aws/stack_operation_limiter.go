@@ -0,0 +1,103 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Delay before a stack waiting on the limit of concurrent operations tries again
+const operationLimitRecheckInterval = 15 * time.Second
+
+// OperationLimiter bounds the stack operations (create, update or delete) running in CloudFormation at once, keeping
+// the controller under the account limit of concurrent operations. A slot is held from the submission of the operation
+// until the follower observes the stack settle.
+type OperationLimiter struct {
+	// Maximum number of operations running at once, unlimited when zero
+	Limit   int
+	mu      sync.Mutex
+	running map[types.NamespacedName]struct{}
+}
+
+// Acquire reserves a slot for an operation on the Stack, returning false when all slots are taken. A Stack already
+// holding a slot keeps it.
+func (l *OperationLimiter) Acquire(name types.NamespacedName) bool {
+	if l == nil || l.Limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, held := l.running[name]; held {
+		return true
+	}
+	if len(l.running) >= l.Limit {
+		return false
+	}
+	if l.running == nil {
+		l.running = make(map[types.NamespacedName]struct{})
+	}
+	l.running[name] = struct{}{}
+	return true
+}
+
+// Release frees the slot held by the Stack, if any.
+func (l *OperationLimiter) Release(name types.NamespacedName) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.running, name)
+}
+
+// Running returns the number of slots held.
+func (l *OperationLimiter) Running() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.running)
+}
+
+// acquireOperation reserves a slot for the operation about to be submitted for the Stack, returning false when it has
+// to wait for running operations to complete.
+func (r *StackReconciler) acquireOperation(loop *StackLoop) bool {
+	if r.OperationLimiter.Acquire(loop.req.NamespacedName) {
+		return true
+	}
+	loop.Log.Info("Concurrent stack operations at the limit, waiting", "limit", r.OperationLimiter.Limit)
+	return false
+}
+
+// releaseOperation frees the slot of an operation which was not submitted, leaving those submitted to the follower.
+func (r *StackReconciler) releaseOperation(loop *StackLoop) {
+	if !loop.submitted {
+		r.OperationLimiter.Release(loop.req.NamespacedName)
+	}
+}
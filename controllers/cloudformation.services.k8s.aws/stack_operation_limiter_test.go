@@ -0,0 +1,103 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestOperationLimiterDefersExcessOperations(t *testing.T) {
+	const topicStackID = "arn:aws:cloudformation:us-east-1:123456789012:stack/my-topic/8d1c4e5a"
+	newStack := func(name string, stackID string) *v1alpha1.Stack {
+		return &v1alpha1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Finalizers: []string{stacksFinalizer}},
+			Spec:       v1alpha1.StackSpec{StackName: name, Template: testTemplate},
+			Status:     v1alpha1.StackStatus{StackID: stackID, StackStatus: "CREATE_COMPLETE"},
+		}
+	}
+	bucket, topic := newStack("my-bucket", testStackID), newStack("my-topic", topicStackID)
+	k8sClient := newFakeClient(bucket, topic)
+	cfn := newFakeCloudFormation()
+	for name, stackID := range map[string]string{"my-bucket": testStackID, "my-topic": topicStackID} {
+		cfn.addStack(name, stackID, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+			{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+		}
+	}
+	limiter := &OperationLimiter{Limit: 1}
+	r := newTestReconciler(k8sClient, cfn)
+	r.OperationLimiter = limiter
+	follower := newTestFollower(k8sClient, cfn)
+	follower.OperationLimiter = limiter
+	bucketReq := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+	topicReq := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-topic", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), bucketReq); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 || limiter.Running() != 1 {
+		t.Fatalf("expected the bucket updated holding the slot, got %d updates and %d running",
+			len(cfn.updateInputs), limiter.Running())
+	}
+
+	result, err := r.Reconcile(context.TODO(), topicReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 || result.RequeueAfter != operationLimitRecheckInterval {
+		t.Fatalf("expected the topic update deferred, got %d updates and %v", len(cfn.updateInputs), result)
+	}
+
+	// The slot is released once the follower sees the bucket settle
+	follower.startFollowing(bucket)
+	follower.stopFollowing(testStackID)
+	if _, err := r.Reconcile(context.TODO(), topicReq); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 2 {
+		t.Errorf("expected the topic updated once the slot is free, got %d updates", len(cfn.updateInputs))
+	}
+}
+
+func TestOperationLimiterReleasesUnsubmitted(t *testing.T) {
+	r, _, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate})
+	r.OperationLimiter = &OperationLimiter{Limit: 1}
+	loop.req = ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if !r.acquireOperation(loop) || !r.acquireOperation(loop) {
+		t.Fatal("expected the Stack holding the slot to acquire it again")
+	}
+	r.releaseOperation(loop)
+	if running := r.OperationLimiter.Running(); running != 0 {
+		t.Errorf("expected the slot of the unsubmitted operation released, got %d running", running)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// operationToken provides the ClientRequestToken submitted with an operation on the stack, naming the operation and
+// the generation of the Stack it applies so the stack events carrying it trace back to the reconcile submitting it.
+// Distinct for each submission, an operation retried after a failure isn't refused as a duplicate. The token of a
+// submission which timed out is submitted again instead, for CloudFormation to recognize the retry.
+func operationToken(loop *StackLoop, operation string) string {
+	pending := loop.instance.Status.PendingOperationToken
+	if strings.HasPrefix(pending, fmt.Sprintf("%s-%d-", operation, loop.instance.Generation)) {
+		return pending
+	}
+	return fmt.Sprintf("%s-%d-%s", operation, loop.instance.Generation, strconv.FormatInt(time.Now().UnixNano(), 36))
+}
+
+// submissionTimedOut handles a create or update whose call timed out, CloudFormation possibly running it already. The
+// token is kept for the retries to submit again, and an existing stack is followed with its status read afresh rather
+// than from the cache, the follower freeing the operation slot once it settles.
+func (r *StackReconciler) submissionTimedOut(loop *StackLoop, token string) {
+	loop.instance.Status.PendingOperationToken = token
+	if err := r.updateStatus(loop); err != nil {
+		loop.Log.Error(err, "Failed to record the pending operation token", "token", token)
+	}
+	if loop.instance.Status.StackID == "" {
+		return
+	}
+	r.CloudFormationHelper.InvalidateStack(loop.instance.Status.StackID)
+	loop.submitted = true
+	r.ChannelHub.FollowQueue.Add(loop.instance)
+}
@@ -0,0 +1,193 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	coreerrors "errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Default interval between passes looking for orphaned stacks
+const DefaultOrphanReaperInterval = time.Hour
+
+// ErrNoInstanceID is returned by an OrphanReaper not knowing the installation whose stacks it may reap
+var ErrNoInstanceID = coreerrors.New("the orphan reaper requires the instance ID of the controller")
+
+// OrphanReaper looks for stacks created by this installation of the controller whose Stack resource no longer exists
+// (e.g. its finalizer was removed by hand), reporting them and, when enabled, deleting them. A stack is only deleted
+// once found orphaned on two consecutive passes.
+type OrphanReaper struct {
+	client.Client
+	Log                  logr.Logger
+	CloudFormationHelper *CloudFormationHelper
+	// Identity of the installation, only the stacks tagged with it are considered (other clusters sharing the account
+	// keep their Stack resources elsewhere)
+	InstanceID string
+	// Interval between passes, DefaultOrphanReaperInterval when zero
+	Interval time.Duration
+	// Deletes the orphaned stacks rather than only reporting them
+	Delete bool
+	DryRun bool
+	// Optional gauge of the orphaned stacks found on the latest pass
+	Orphans  prometheus.Gauge
+	suspects map[string]bool // StackID -> found orphaned on the previous pass
+}
+
+// Start looks for orphaned stacks every Interval until the context is done.
+func (o *OrphanReaper) Start(ctx context.Context) error {
+	if o.InstanceID == "" {
+		return ErrNoInstanceID
+	}
+	interval := o.Interval
+	if interval <= 0 {
+		interval = DefaultOrphanReaperInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := o.reap(ctx); err != nil {
+			o.Log.Error(err, "Failed to look for orphaned stacks")
+		}
+	}
+}
+
+// NeedLeaderElection has only the leader look for orphaned stacks, replicas deleting the same stacks at once.
+func (o *OrphanReaper) NeedLeaderElection() bool {
+	return true
+}
+
+// ClusterInstanceID provides the UID of the kube-system namespace, identifying the cluster for as long as it exists.
+func ClusterInstanceID(ctx context.Context, reader client.Reader) (string, error) {
+	namespace := &v1.Namespace{}
+	if err := reader.Get(ctx, types.NamespacedName{Name: "kube-system"}, namespace); err != nil {
+		return "", err
+	}
+	return string(namespace.UID), nil
+}
+
+// reap runs a pass over the stacks controlled by the controller, reporting or deleting those orphaned.
+func (o *OrphanReaper) reap(ctx context.Context) error {
+	if o.InstanceID == "" {
+		return ErrNoInstanceID
+	}
+	orphans, err := o.findOrphans(ctx)
+	if err != nil {
+		return err
+	}
+	if o.Orphans != nil {
+		o.Orphans.Set(float64(len(orphans)))
+	}
+
+	suspects := map[string]bool{}
+	for _, stack := range orphans {
+		stackId := aws.ToString(stack.StackId)
+		log := o.Log.WithValues("StackID", stackId, "owner", stackTag(stack, ownerKey))
+		suspects[stackId] = true
+		if !o.Delete || !o.suspects[stackId] {
+			log.Info("Found a stack without a Stack resource", "status", stack.StackStatus)
+			continue
+		}
+
+		log.Info("Deleting a stack without a Stack resource", "status", stack.StackStatus)
+		if o.DryRun {
+			log.Info("Skipping orphaned stack deletion")
+			continue
+		}
+		callCtx, cancel := o.CloudFormationHelper.callContext(ctx)
+		_, err = o.CloudFormationHelper.GetCloudFormation().DeleteStack(callCtx, &cloudformation.DeleteStackInput{
+			StackName: stack.StackId,
+		})
+		err = callError(ctx, callCtx, err)
+		cancel()
+		if err != nil {
+			log.Error(err, "Failed to delete orphaned stack")
+		}
+	}
+	o.suspects = suspects
+	return nil
+}
+
+// findOrphans lists the stacks created by this installation which are not tracked by any Stack resource, neither by
+// owner UID nor by stack ID. Stacks without the instance tag (created elsewhere or before it was tagged) are left be.
+func (o *OrphanReaper) findOrphans(ctx context.Context) ([]cfTypes.Stack, error) {
+	stackList := &v1alpha1.StackList{}
+	if err := o.List(ctx, stackList); err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, item := range stackList.Items {
+		known[string(item.UID)] = true
+		if item.Status.StackID != "" {
+			known[item.Status.StackID] = true
+		}
+	}
+
+	var orphans []cfTypes.Stack
+	paginator := cloudformation.NewDescribeStacksPaginator(o.CloudFormationHelper.GetCloudFormation(),
+		&cloudformation.DescribeStacksInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, stack := range page.Stacks {
+			owner := stackTag(stack, ownerKey)
+			if stackTag(stack, controllerKey) != controllerValue || stackTag(stack, instanceKey) != o.InstanceID ||
+				owner == "" || known[owner] ||
+				known[aws.ToString(stack.StackId)] || stack.StackStatus == cfTypes.StackStatusDeleteInProgress {
+				continue
+			}
+			orphans = append(orphans, stack)
+		}
+	}
+	return orphans, nil
+}
+
+// stackTag provides the value of a tag on the stack, empty when not tagged.
+func stackTag(stack cfTypes.Stack, key string) string {
+	for _, tag := range stack.Tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOrphanReaperDeletesStacksWithoutResource(t *testing.T) {
+	// The Stack resource restored under a new UID still tracks its stack by ID
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "restored", Namespace: "default", UID: "restored-uid"},
+		Status:     v1alpha1.StackStatus{StackID: "arn:aws:cloudformation:us-east-1:123456789012:stack/restored/1"},
+	}
+	cfn := newFakeCloudFormation()
+	controlled := func(name string, id string, owner string, instanceID string) {
+		cfn.addStack(name, id, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+			{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+			{Key: aws.String(ownerKey), Value: aws.String(owner)},
+			{Key: aws.String(instanceKey), Value: aws.String(instanceID)},
+		}
+	}
+	controlled("restored", instance.Status.StackID, "original-uid", "this-cluster")
+	controlled("orphan", "arn:aws:cloudformation:us-east-1:123456789012:stack/orphan/2", "deleted-uid",
+		"this-cluster")
+	// Stacks not created by the controller are never considered
+	cfn.addStack("manual", "arn:aws:cloudformation:us-east-1:123456789012:stack/manual/3",
+		cfTypes.StackStatusCreateComplete)
+	// Nor are those of the controller in another cluster, or created before stacks were tagged with the instance
+	controlled("elsewhere", "arn:aws:cloudformation:us-east-1:123456789012:stack/elsewhere/4", "remote-uid",
+		"other-cluster")
+	cfn.addStack("untagged", "arn:aws:cloudformation:us-east-1:123456789012:stack/untagged/5",
+		cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+		{Key: aws.String(ownerKey), Value: aws.String("old-uid")},
+	}
+
+	reaper := &OrphanReaper{
+		Client:               newFakeClient(instance),
+		Log:                  logr.Discard(),
+		CloudFormationHelper: &CloudFormationHelper{CloudFormation: cfn},
+		InstanceID:           "this-cluster",
+	}
+	if err := reaper.reap(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if len(reaper.suspects) != 1 || !reaper.suspects["arn:aws:cloudformation:us-east-1:123456789012:stack/orphan/2"] {
+		t.Errorf("expected only the orphan to be found, got %v", reaper.suspects)
+	}
+	if len(cfn.deleteInputs) != 0 {
+		t.Fatalf("expected orphans only reported unless deletion is enabled, got %d deletes", len(cfn.deleteInputs))
+	}
+
+	reaper.Delete = true
+	reaper.suspects = nil
+	if err := reaper.reap(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.deleteInputs) != 0 {
+		t.Fatalf("expected no deletion on the first pass finding the orphan, got %d deletes", len(cfn.deleteInputs))
+	}
+	if err := reaper.reap(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.deleteInputs) != 1 ||
+		aws.ToString(cfn.deleteInputs[0].StackName) != "arn:aws:cloudformation:us-east-1:123456789012:stack/orphan/2" {
+		t.Errorf("expected the orphan to be deleted, got %v", cfn.deleteInputs)
+	}
+}
+
+func TestOrphanReaperDeletionCallTimeout(t *testing.T) {
+	cfn := newFakeCloudFormation()
+	orphans := map[string]string{
+		"orphan-a": "arn:aws:cloudformation:us-east-1:123456789012:stack/orphan-a/1",
+		"orphan-b": "arn:aws:cloudformation:us-east-1:123456789012:stack/orphan-b/2",
+	}
+	suspects := map[string]bool{}
+	for name, id := range orphans {
+		suspects[id] = true
+		cfn.addStack(name, id, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+			{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+			{Key: aws.String(ownerKey), Value: aws.String("deleted-uid")},
+			{Key: aws.String(instanceKey), Value: aws.String("this-cluster")},
+		}
+	}
+	deletes := &hangingDeletes{fakeCloudFormation: cfn}
+	reaper := &OrphanReaper{
+		Client:               newFakeClient(),
+		Log:                  logr.Discard(),
+		CloudFormationHelper: &CloudFormationHelper{CloudFormation: deletes, CallTimeout: 10 * time.Millisecond},
+		InstanceID:           "this-cluster",
+		Delete:               true,
+		suspects:             suspects,
+	}
+
+	// A hung deletion is abandoned, the pass going on with the next orphan
+	if err := reaper.reap(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if len(deletes.attempted) != 2 {
+		t.Errorf("expected each orphan deletion attempted, got %v", deletes.attempted)
+	}
+}
+
+func TestOrphanReaperRequiresInstanceID(t *testing.T) {
+	reaper := &OrphanReaper{
+		Client:               newFakeClient(),
+		Log:                  logr.Discard(),
+		CloudFormationHelper: &CloudFormationHelper{CloudFormation: newFakeCloudFormation()},
+	}
+	if err := reaper.Start(context.TODO()); err != ErrNoInstanceID {
+		t.Errorf("expected %v, got %v", ErrNoInstanceID, err)
+	}
+	if !reaper.NeedLeaderElection() {
+		t.Error("expected the orphan reaper to run on the leader only")
+	}
+}
+
+func TestClusterInstanceID(t *testing.T) {
+	kubeSystem := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "cluster-uid"}}
+	instanceID, err := ClusterInstanceID(context.TODO(), newFakeClient(kubeSystem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instanceID != "cluster-uid" {
+		t.Errorf("expected the kube-system namespace UID, got %q", instanceID)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/yaml"
+)
+
+// parametersViolateSchema validates the parameters against the JSON schema in parametersSchemaRef, recording the
+// SchemaViolation condition. Returns true while the parameters, or the schema itself, hold the stack back. The values
+// of sensitive parameters are never validated.
+func (r *StackReconciler) parametersViolateSchema(loop *StackLoop) (bool, error) {
+	ref := loop.instance.Spec.ParametersSchemaRef
+	if ref == nil {
+		if removeCondition(loop.instance, v1alpha1.ConditionSchemaViolation) {
+			return false, r.updateStatus(loop)
+		}
+		return false, nil
+	}
+
+	document, found, err := r.configMapValue(loop, ref)
+	if err != nil {
+		loop.Log.Error(err, "Failed to get the parameters schema", "configMap", ref.Name)
+		return false, err
+	}
+	var reason, message string
+	if !found {
+		reason, message = "SchemaNotFound", fmt.Sprintf("Key %s of ConfigMap %s not found", ref.Key, ref.Name)
+	} else if schema, err := parseParametersSchema(document); err != nil {
+		reason, message = "InvalidSchema", fmt.Sprintf("Schema in ConfigMap %s is invalid: %v", ref.Name, err)
+	} else if violations := schemaViolations(schema, loop.parameters); len(violations) > 0 {
+		reason, message = "ParametersViolateSchema", strings.Join(violations, "; ")
+	}
+
+	if reason == "" {
+		if removeCondition(loop.instance, v1alpha1.ConditionSchemaViolation) {
+			return false, r.updateStatus(loop)
+		}
+		return false, nil
+	}
+	loop.Log.Info("Parameters not validated by their schema", "reason", reason, "message", message)
+	if setCondition(loop.instance, v1alpha1.ConditionSchemaViolation, metav1.ConditionTrue, reason, message) {
+		r.Recorder.Event(loop.instance, v1.EventTypeWarning, v1alpha1.ConditionSchemaViolation, message)
+		return true, r.updateStatus(loop)
+	}
+	return true, nil
+}
+
+// parseParametersSchema reads a JSON schema written in JSON or YAML.
+func parseParametersSchema(document string) (*spec.Schema, error) {
+	converted, err := yaml.YAMLToJSON([]byte(document))
+	if err != nil {
+		return nil, err
+	}
+	schema := &spec.Schema{}
+	if err := json.Unmarshal(converted, schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// schemaViolations lists how the parameters, as the object of their names to their values, violate the schema.
+func schemaViolations(schema *spec.Schema, parameters map[string]string) []string {
+	object := map[string]interface{}{}
+	for name, value := range parameters {
+		object[name] = value
+	}
+	result := validate.NewSchemaValidator(schema, nil, "", strfmt.Default).Validate(object)
+	var violations []string
+	for _, err := range result.Errors {
+		// Reading as "InstanceType should be one of [...]" rather than ".InstanceType in body should be one of [...]"
+		violations = append(violations, strings.Replace(strings.TrimPrefix(err.Error(), "."), " in body ", " ", 1))
+	}
+	sort.Strings(violations)
+	return violations
+}
@@ -0,0 +1,132 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const testParametersSchema = `
+type: object
+required: [InstanceType]
+properties:
+  InstanceType:
+    enum: [t3.micro, t3.small]
+  VpcCidr:
+    pattern: '^10\.'
+`
+
+func TestSchemaViolations(t *testing.T) {
+	schema, err := parseParametersSchema(testParametersSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		expected   []string
+	}{
+		{"valid", map[string]string{"InstanceType": "t3.micro", "VpcCidr": "10.0.0.0/16"}, nil},
+		{"not allowed", map[string]string{"InstanceType": "m5.24xlarge"},
+			[]string{`InstanceType should be one of [t3.micro t3.small]`}},
+		{"pattern", map[string]string{"InstanceType": "t3.small", "VpcCidr": "192.168.0.0/16"},
+			[]string{`VpcCidr should match '^10\.'`}},
+		{"required", map[string]string{}, []string{"InstanceType is required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if violations := schemaViolations(schema, tt.parameters); !reflect.DeepEqual(violations, tt.expected) {
+				t.Errorf("schemaViolations() = %q, want %q", violations, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParametersSchemaHoldsStack(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate,
+			Parameters: map[string]string{"InstanceType": "m5.24xlarge"},
+			ParametersSchemaRef: &v1.ConfigMapKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "stack-policy"}, Key: "schema.yaml"}},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+	stack := &v1alpha1.Stack{}
+	reconcile := func() *metav1.Condition {
+		t.Helper()
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatal(err)
+		}
+		if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+			t.Fatal(err)
+		}
+		return meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionSchemaViolation)
+	}
+
+	// Waiting on the schema
+	if condition := reconcile(); condition == nil || condition.Reason != "SchemaNotFound" {
+		t.Fatalf("expected the stack held until the schema exists, got %v", stack.Status.Conditions)
+	}
+
+	// Violating the schema
+	if err := k8sClient.Create(context.TODO(), &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack-policy", Namespace: "default"},
+		Data:       map[string]string{"schema.yaml": testParametersSchema},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	condition := reconcile()
+	if condition == nil || condition.Reason != "ParametersViolateSchema" ||
+		condition.Message != "InstanceType should be one of [t3.micro t3.small]" {
+		t.Fatalf("expected the schema violation reported, got %v", stack.Status.Conditions)
+	}
+	if len(cfn.createInputs) != 0 {
+		t.Fatalf("expected no stack created while violating the schema, got %d creates", len(cfn.createInputs))
+	}
+
+	// Satisfying the schema
+	stack.Spec.Parameters["InstanceType"] = "t3.micro"
+	if err := k8sClient.Update(context.TODO(), stack); err != nil {
+		t.Fatal(err)
+	}
+	if condition := reconcile(); condition != nil {
+		t.Errorf("expected the condition cleared, got %v", condition)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Errorf("expected the stack created, got %d creates", len(cfn.createInputs))
+	}
+}
@@ -0,0 +1,62 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Annotation pausing the reconciling and following of the stack while "true"
+	pausedAnnotation = "cloudformation.services.k8s.aws.cuppett.dev/paused"
+)
+
+// paused identifies a Stack paused by the paused annotation.
+func paused(instance *v1alpha1.Stack) bool {
+	return instance.Annotations[pausedAnnotation] == "true"
+}
+
+// pausedStack records the Paused condition on a paused Stack, which is then neither created nor updated, clearing it
+// once resumed. Returns true while paused.
+func (r *StackReconciler) pausedStack(loop *StackLoop) (bool, error) {
+	if !paused(loop.instance) {
+		if removeCondition(loop.instance, v1alpha1.ConditionPaused) {
+			loop.Log.Info("Stack resumed")
+			r.Recorder.Event(loop.instance, v1.EventTypeNormal, "Resumed", "Stack reconciling resumed")
+			return false, r.updateStatus(loop)
+		}
+		return false, nil
+	}
+
+	loop.Log.V(1).Info("Stack paused, leaving it alone")
+	if setCondition(loop.instance, v1alpha1.ConditionPaused, metav1.ConditionTrue, "PausedByAnnotation",
+		"Reconciling and following the stack are paused by the "+pausedAnnotation+" annotation") {
+		r.Recorder.Event(loop.instance, v1.EventTypeNormal, v1alpha1.ConditionPaused, "Stack reconciling paused")
+		return true, r.updateStatus(loop)
+	}
+	return true, nil
+}
@@ -0,0 +1,120 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestPausedStackNotReconciled(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer},
+			Annotations: map[string]string{pausedAnnotation: "true"}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 0 {
+		t.Fatalf("expected no stack created while paused, got %d creates", len(cfn.createInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionPaused)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != "PausedByAnnotation" {
+		t.Fatalf("expected the Paused condition, got %v", instance.Status.Conditions)
+	}
+
+	// Resumed, the stack is created and the condition cleared
+	delete(instance.Annotations, pausedAnnotation)
+	if err := k8sClient.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the stack created once resumed, got %d creates", len(cfn.createInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionPaused) != nil {
+		t.Errorf("expected the Paused condition cleared, got %v", instance.Status.Conditions)
+	}
+}
+
+func TestPausedStackNotFollowed(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default",
+			Annotations: map[string]string{pausedAnnotation: "true"}},
+		Spec:   v1alpha1.StackSpec{StackName: "my-bucket"},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_IN_PROGRESS"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete)
+	follower := newTestFollower(k8sClient, cfn)
+
+	follower.startFollowing(instance)
+	follower.mapPollingList.Range(follower.processStack)
+	if cfn.describes != 0 {
+		t.Fatalf("expected the paused stack not polled, got %d describes", cfn.describes)
+	}
+	if !follower.beingFollowed(testStackID) {
+		t.Fatal("expected the paused stack still followed")
+	}
+
+	// Resumed, the next poll catches up with the stack
+	delete(instance.Annotations, pausedAnnotation)
+	if err := k8sClient.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	follower.mapPollingList.Range(follower.processStack)
+	if cfn.describes == 0 {
+		t.Fatal("expected the stack polled once resumed")
+	}
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: "my-bucket", Namespace: "default"},
+		instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.StackStatus != "UPDATE_COMPLETE" {
+		t.Errorf("expected the status caught up, got %s", instance.Status.StackStatus)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// stackPolicyHash identifies the stack policy in the spec, empty without one.
+func stackPolicyHash(instance *v1alpha1.Stack) string {
+	var policy string
+	switch {
+	case instance.Spec.StackPolicy != "":
+		policy = "body:" + instance.Spec.StackPolicy
+	case instance.Spec.StackPolicyUrl != "":
+		policy = "url:" + instance.Spec.StackPolicyUrl
+	default:
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(policy)))
+}
+
+// applyStackPolicy sets the stack policy of the spec on the stack whenever it differs from the policy last set,
+// independently of template updates. CloudFormation can't remove a stack policy, one dropped from the spec stays on
+// the stack and is only forgotten.
+func (r *StackReconciler) applyStackPolicy(loop *StackLoop) error {
+	hash := stackPolicyHash(loop.instance)
+	if hash == loop.instance.Status.AppliedStackPolicyHash {
+		return nil
+	}
+	if hash == "" {
+		loop.Log.Info("Stack policy removed from the spec, the stack keeps its last policy")
+		loop.instance.Status.AppliedStackPolicyHash = ""
+		return r.updateStatus(loop)
+	}
+
+	loop.Log.Info("Setting stack policy")
+	if r.DryRun {
+		loop.Log.Info("Skipping setting the stack policy")
+		return nil
+	}
+
+	input := &cloudformation.SetStackPolicyInput{StackName: aws.String(loop.instance.Status.StackID)}
+	if loop.instance.Spec.StackPolicy != "" {
+		input.StackPolicyBody = aws.String(loop.instance.Spec.StackPolicy)
+	} else {
+		input.StackPolicyURL = aws.String(loop.instance.Spec.StackPolicyUrl)
+	}
+	callCtx, cancel := r.CloudFormationHelper.callContextFor(loop.ctx, loop.instance)
+	_, err := r.CloudFormationHelper.CloudFormationFor(loop.instance).SetStackPolicy(callCtx, input)
+	err = callError(loop.ctx, callCtx, err)
+	cancel()
+	if err != nil {
+		loop.Log.Error(err, "Failed to set the stack policy")
+		r.recordFailureSummary(loop, OperationFailureSummary("SetStackPolicy", err))
+		return err
+	}
+
+	r.Recorder.Event(loop.instance, v1.EventTypeNormal, "StackPolicyApplied", "The stack policy was set on the stack")
+	loop.instance.Status.AppliedStackPolicyHash = hash
+	return r.updateStatus(loop)
+}
@@ -0,0 +1,123 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const testStackPolicy = `{"Statement":[{"Effect":"Deny","Action":"Update:Replace","Principal":"*","Resource":"*"}]}`
+
+func TestStackPolicySetWithoutUpdate(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete)
+	stack.Tags = []cfTypes.Tag{{Key: aws.String(controllerKey), Value: aws.String(controllerValue)}}
+	cfn.templates[testStackID] = testTemplate
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	// Bringing the stack up to date with the spec
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 || len(cfn.policyInputs) != 0 {
+		t.Fatalf("expected a single update and no policy, got %d updates and %d policies", len(cfn.updateInputs),
+			len(cfn.policyInputs))
+	}
+
+	// Only the policy changes, it is set on the stack without updating it
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	instance.Spec.StackPolicy = testStackPolicy
+	if err := k8sClient.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Errorf("expected no further update, got %d updates", len(cfn.updateInputs))
+	}
+	if len(cfn.policyInputs) != 1 {
+		t.Fatalf("expected the policy set once, got %d", len(cfn.policyInputs))
+	}
+	if got := aws.ToString(cfn.policyInputs[0].StackPolicyBody); got != testStackPolicy {
+		t.Errorf("expected the policy of the spec, got %q", got)
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.AppliedStackPolicyHash != stackPolicyHash(instance) {
+		t.Errorf("expected the applied policy hash recorded, got %q", instance.Status.AppliedStackPolicyHash)
+	}
+}
+
+func TestStackPolicySetOnCreate(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate,
+			StackPolicyUrl: "https://my-bucket.s3.amazonaws.com/policy.json"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the stack created, got %d creates", len(cfn.createInputs))
+	}
+	if got := aws.ToString(cfn.createInputs[0].StackPolicyURL); got != instance.Spec.StackPolicyUrl {
+		t.Errorf("expected the policy URL submitted with the stack, got %q", got)
+	}
+	if len(cfn.policyInputs) != 0 {
+		t.Errorf("expected no separate policy call, got %d", len(cfn.policyInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.AppliedStackPolicyHash == "" {
+		t.Error("expected the applied policy hash recorded")
+	}
+}
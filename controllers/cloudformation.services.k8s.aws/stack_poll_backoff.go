@@ -0,0 +1,85 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"time"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// pollState is the polling state of a stack being followed.
+type pollState struct {
+	namespacedName *types.NamespacedName
+	// Latest status polled and since when
+	status      cfTypes.StackStatus
+	statusSince time.Time
+	// Interval before the next poll, doubling while the status holds
+	interval time.Duration
+	nextPoll time.Time
+}
+
+// pollDue identifies if the stack is due to be polled.
+func (s *pollState) pollDue(now time.Time) bool {
+	return !now.Before(s.nextPoll)
+}
+
+// schedulePoll sets when the stack is next polled. The longer it holds the status polled, the less often it is polled,
+// backing off up to MaxPollInterval, except close to when its operation is expected to settle (by its average
+// operation duration), polled at PollInterval again.
+func (f *StackFollower) schedulePoll(followed *pollState, instance *v1alpha1.Stack, status cfTypes.StackStatus,
+	now time.Time) {
+	base := f.pollInterval()
+	if followed.status != status || followed.interval < base {
+		followed.status, followed.statusSince, followed.interval = status, now, base
+	} else if f.MaxPollInterval > base {
+		followed.interval = min(2*followed.interval, f.MaxPollInterval)
+	}
+
+	if instance.Status.AverageOperationDuration != nil {
+		expected := instance.Status.AverageOperationDuration.Duration
+		inStatus := now.Sub(followed.statusSince)
+		if inStatus+followed.interval >= expected*8/10 && inStatus <= expected*3/2 {
+			followed.interval = base
+		}
+	}
+	if followed.interval <= base {
+		// Polled with every tick of the worker
+		followed.nextPoll = time.Time{}
+		return
+	}
+	// Half a tick early, so the tick around the time it's due polls it
+	followed.nextPoll = now.Add(followed.interval - base/2)
+}
+
+// pollInterval provides the PollInterval, defaulting to every second.
+func (f *StackFollower) pollInterval() time.Duration {
+	if f.PollInterval <= 0 {
+		return time.Second
+	}
+	return f.PollInterval
+}
@@ -0,0 +1,124 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"testing"
+	"time"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSchedulePollBacksOff(t *testing.T) {
+	follower := &StackFollower{PollInterval: time.Second, MaxPollInterval: 8 * time.Second}
+	instance := &v1alpha1.Stack{}
+	followed := &pollState{}
+	now := time.Now()
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second}
+	for i, interval := range expected {
+		follower.schedulePoll(followed, instance, cfTypes.StackStatusCreateInProgress, now)
+		if followed.interval != interval {
+			t.Fatalf("poll %d: expected an interval of %s, got %s", i, interval, followed.interval)
+		}
+		now = now.Add(followed.interval)
+	}
+	if followed.pollDue(now.Add(-followed.interval)) {
+		t.Error("expected the stack not due right after a poll")
+	}
+	if !followed.pollDue(now) {
+		t.Error("expected the stack due once the interval elapsed")
+	}
+
+	// A new status is polled fast again
+	follower.schedulePoll(followed, instance, cfTypes.StackStatusCreateComplete, now)
+	if followed.interval != time.Second || !followed.pollDue(now) {
+		t.Errorf("expected the new status polled every tick, got %s", followed.interval)
+	}
+}
+
+func TestSchedulePollFastNearExpectedSettle(t *testing.T) {
+	follower := &StackFollower{PollInterval: time.Second, MaxPollInterval: 30 * time.Second}
+	instance := &v1alpha1.Stack{Status: v1alpha1.StackStatus{
+		AverageOperationDuration: &metav1.Duration{Duration: 20 * time.Minute}}}
+	start := time.Now()
+	followed := &pollState{status: cfTypes.StackStatusCreateInProgress, statusSince: start, interval: 30 * time.Second}
+
+	follower.schedulePoll(followed, instance, cfTypes.StackStatusCreateInProgress, start.Add(5*time.Minute))
+	if followed.interval != 30*time.Second {
+		t.Errorf("expected the backed off interval early in the operation, got %s", followed.interval)
+	}
+	follower.schedulePoll(followed, instance, cfTypes.StackStatusCreateInProgress, start.Add(17*time.Minute))
+	if followed.interval != time.Second {
+		t.Errorf("expected fast polls close to the expected settle, got %s", followed.interval)
+	}
+	follower.schedulePoll(followed, instance, cfTypes.StackStatusCreateInProgress, start.Add(40*time.Minute))
+	if followed.interval != 2*time.Second {
+		t.Errorf("expected backing off again well past the expected settle, got %s", followed.interval)
+	}
+}
+
+func TestSchedulePollWithoutBackoff(t *testing.T) {
+	follower := &StackFollower{}
+	followed := &pollState{}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		follower.schedulePoll(followed, &v1alpha1.Stack{}, cfTypes.StackStatusUpdateInProgress, now)
+		if !followed.pollDue(now) {
+			t.Fatal("expected the stack polled with every tick without MaxPollInterval")
+		}
+	}
+}
+
+func TestFollowerSkipsStacksNotDue(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-db", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-db"},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_IN_PROGRESS"},
+	}
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-db", testStackID, cfTypes.StackStatusCreateInProgress)
+	follower := newTestFollower(newFakeClient(instance), cfn)
+	follower.MaxPollInterval = time.Minute
+
+	// Polled with every tick on entering the status, backing off from the next poll
+	follower.startFollowing(instance)
+	follower.pollFollowed()
+	follower.pollFollowed()
+	follower.pollFollowed()
+	if cfn.describes != 2 {
+		t.Fatalf("expected the third poll to wait on the backed off interval, got %d describes", cfn.describes)
+	}
+
+	// Polled again once due
+	value, _ := follower.mapPollingList.Load(testStackID)
+	value.(*pollState).nextPoll = time.Now()
+	follower.pollFollowed()
+	if cfn.describes != 3 {
+		t.Errorf("expected the stack polled once due, got %d describes", cfn.describes)
+	}
+}
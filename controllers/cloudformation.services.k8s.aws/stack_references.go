@@ -0,0 +1,305 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+)
+
+const (
+	// Index of Stacks by the Stacks they reference via parametersFrom
+	stackRefIndex = "spec.parametersFrom.stackRef"
+	// Index of Stacks by the ConfigMaps they reference via parametersFrom and parametersSchemaRef
+	configMapRefIndex = "spec.parametersFrom.configMapKeyRef"
+	// Index of Stacks by the Secrets they reference via parametersFrom
+	secretRefIndex = "spec.parametersFrom.secretKeyRef"
+)
+
+// stackRefIndexer lists the names of the Stacks referenced by a Stack in parametersFrom.
+func stackRefIndexer(obj client.Object) []string {
+	stack := obj.(*v1alpha1.Stack)
+	var refs []string
+	for _, source := range stack.Spec.ParametersFrom {
+		if source.StackRef != nil {
+			refs = append(refs, source.StackRef.Name)
+		}
+	}
+	return refs
+}
+
+// configMapRefIndexer lists the names of the ConfigMaps referenced by a Stack in parametersFrom and
+// parametersSchemaRef.
+func configMapRefIndexer(obj client.Object) []string {
+	stack := obj.(*v1alpha1.Stack)
+	var refs []string
+	for _, source := range stack.Spec.ParametersFrom {
+		if source.ConfigMapKeyRef != nil {
+			refs = append(refs, source.ConfigMapKeyRef.Name)
+		}
+	}
+	if stack.Spec.ParametersSchemaRef != nil {
+		refs = append(refs, stack.Spec.ParametersSchemaRef.Name)
+	}
+	return refs
+}
+
+// secretRefIndexer lists the names of the Secrets referenced by a Stack in parametersFrom.
+func secretRefIndexer(obj client.Object) []string {
+	stack := obj.(*v1alpha1.Stack)
+	var refs []string
+	for _, source := range stack.Spec.ParametersFrom {
+		if source.SecretKeyRef != nil {
+			refs = append(refs, source.SecretKeyRef.Name)
+		}
+	}
+	return refs
+}
+
+// stacksReferencing maps an object (Stack, ConfigMap, Secret or Template) to the Stacks in the same namespace referencing it,
+// as recorded in the given index.
+func (r *StackReconciler) stacksReferencing(index string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		list := &v1alpha1.StackList{}
+		err := r.List(ctx, list, client.InNamespace(obj.GetNamespace()), client.MatchingFields{index: obj.GetName()})
+		if err != nil {
+			r.Log.Error(err, "Failed to list referencing Stacks", "Namespace", obj.GetNamespace(), "Name",
+				obj.GetName(), "index", index)
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(list.Items))
+		for _, stack := range list.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: stack.Namespace, Name: stack.Name},
+			})
+		}
+		return requests
+	}
+}
+
+// stacksOfConfigMap maps a ConfigMap to the Stacks to reconcile, each once: the Stack owning it (as written with its
+// outputs), the Stacks referencing it and, for the namespaceTagsConfigMap, all the Stacks of its namespace.
+func (r *StackReconciler) stacksOfConfigMap(ctx context.Context, obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	if owner := metav1.GetControllerOf(obj); owner != nil && owner.Kind == "Stack" &&
+		owner.APIVersion == v1alpha1.GroupVersion.String() {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: owner.Name},
+		})
+	}
+	requests = append(requests, r.stacksReferencing(configMapRefIndex)(ctx, obj)...)
+	requests = append(requests, r.stacksTaggedBy(ctx, obj)...)
+
+	seen := map[reconcile.Request]bool{}
+	unique := requests[:0]
+	for _, request := range requests {
+		if !seen[request] {
+			seen[request] = true
+			unique = append(unique, request)
+		}
+	}
+	return unique
+}
+
+// resolveParameters compiles the parameters of the Stack, recording the WaitingOnStackOutput condition while any
+// referenced Stack output is not yet available. Stacks whose references lead back to themselves are refused with the
+// DependencyCycle condition.
+func (r *StackReconciler) resolveParameters(loop *StackLoop) (bool, error) {
+	cycle, err := r.dependencyCycle(loop)
+	if err != nil {
+		loop.Log.Error(err, "Failed to check for dependency cycles")
+		return false, err
+	}
+	if cycle != nil {
+		loop.Log.Info("Dependency cycle between stacks", "cycle", cycle)
+		if setCondition(loop.instance, v1alpha1.ConditionDependencyCycle, metav1.ConditionTrue, "CycleDetected",
+			"Stacks reference each other's outputs: "+strings.Join(cycle, " -> ")) {
+			return false, r.updateStatus(loop)
+		}
+		return false, nil
+	}
+	changed := removeCondition(loop.instance, v1alpha1.ConditionDependencyCycle)
+
+	parameters := map[string]string{}
+	for k, v := range loop.instance.Spec.Parameters {
+		parameters[k] = v
+	}
+	for k, v := range loop.instance.Spec.ListParameters {
+		parameters[k] = strings.Join(v, ",")
+	}
+
+	var waiting []string
+	sensitiveVersions := map[string]string{}
+	for _, source := range loop.instance.Spec.ParametersFrom {
+		var value, reference string
+		var found, optional bool
+		switch {
+		case source.StackRef != nil:
+			reference = source.StackRef.Name + "/" + source.StackRef.Output
+			value, found, err = r.stackOutput(loop, source.StackRef)
+		case source.ConfigMapKeyRef != nil:
+			reference = "configmap/" + source.ConfigMapKeyRef.Name + "/" + source.ConfigMapKeyRef.Key
+			optional = source.ConfigMapKeyRef.Optional != nil && *source.ConfigMapKeyRef.Optional
+			value, found, err = r.configMapValue(loop, source.ConfigMapKeyRef)
+		case source.SecretKeyRef != nil && source.Sensitive:
+			reference = "secret/" + source.SecretKeyRef.Name + "/" + source.SecretKeyRef.Key
+			optional = source.SecretKeyRef.Optional != nil && *source.SecretKeyRef.Optional
+			value, found, err = r.sensitiveSecretVersion(loop, source.SecretKeyRef)
+		case source.SecretKeyRef != nil:
+			reference = "secret/" + source.SecretKeyRef.Name + "/" + source.SecretKeyRef.Key
+			optional = source.SecretKeyRef.Optional != nil && *source.SecretKeyRef.Optional
+			value, found, err = r.secretValue(loop, source.SecretKeyRef)
+		default:
+			continue
+		}
+		if err != nil {
+			loop.Log.Error(err, "Failed to get referenced value", "reference", reference)
+			return false, err
+		}
+		if found && source.Sensitive {
+			sensitiveVersions[source.Name] = reference + "@" + value
+		} else if found {
+			parameters[source.Name] = value
+		} else if !optional {
+			waiting = append(waiting, reference)
+		}
+	}
+	loop.parameters = parameters
+	loop.sensitiveVersions = sensitiveVersions
+
+	if len(waiting) > 0 {
+		loop.Log.Info("Waiting on referenced stack outputs", "outputs", waiting)
+		changed = setCondition(loop.instance, v1alpha1.ConditionWaitingOnStackOutput, metav1.ConditionTrue,
+			"OutputNotReady", "Waiting on referenced values: "+strings.Join(waiting, ", "))
+	} else {
+		changed = removeCondition(loop.instance, v1alpha1.ConditionWaitingOnStackOutput) || changed
+	}
+	if changed {
+		if err := r.updateStatus(loop); err != nil {
+			return false, err
+		}
+	}
+
+	return len(waiting) == 0, nil
+}
+
+// dependencyCycle follows the Stacks referenced via parametersFrom, returning the path of names leading back to the
+// Stack being reconciled, if any.
+func (r *StackReconciler) dependencyCycle(loop *StackLoop) ([]string, error) {
+	path := []string{loop.instance.Name}
+	visited := map[string]bool{}
+
+	var visit func(stack *v1alpha1.Stack) ([]string, error)
+	visit = func(stack *v1alpha1.Stack) ([]string, error) {
+		for _, source := range stack.Spec.ParametersFrom {
+			if source.StackRef == nil {
+				continue
+			}
+			name := source.StackRef.Name
+			if name == loop.instance.Name {
+				return append(path, name), nil
+			}
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+
+			referenced := &v1alpha1.Stack{}
+			err := r.Get(loop.ctx, types.NamespacedName{Namespace: loop.instance.Namespace, Name: name}, referenced)
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+			path = append(path, name)
+			if cycle, err := visit(referenced); cycle != nil || err != nil {
+				return cycle, err
+			}
+			path = path[:len(path)-1]
+		}
+		return nil, nil
+	}
+	return visit(loop.instance)
+}
+
+// configMapValue retrieves a key of a referenced ConfigMap.
+func (r *StackReconciler) configMapValue(loop *StackLoop, ref *v1.ConfigMapKeySelector) (string, bool, error) {
+	configMap := &v1.ConfigMap{}
+	err := r.Get(loop.ctx, types.NamespacedName{Namespace: loop.instance.Namespace, Name: ref.Name}, configMap)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	value, found := configMap.Data[ref.Key]
+	return value, found, nil
+}
+
+// secretValue retrieves a key of a referenced Secret.
+func (r *StackReconciler) secretValue(loop *StackLoop, ref *v1.SecretKeySelector) (string, bool, error) {
+	secret := &v1.Secret{}
+	err := r.Get(loop.ctx, types.NamespacedName{Namespace: loop.instance.Namespace, Name: ref.Name}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	value, found := secret.Data[ref.Key]
+	return string(value), found, nil
+}
+
+// stackOutput retrieves an output of a referenced Stack, provided the Stack is ready.
+func (r *StackReconciler) stackOutput(loop *StackLoop, ref *v1alpha1.StackOutputReference) (string, bool, error) {
+	referenced := &v1alpha1.Stack{}
+	err := r.Get(loop.ctx, types.NamespacedName{Namespace: loop.instance.Namespace, Name: ref.Name}, referenced)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	if !r.CloudFormationHelper.StackInReadyState(cfTypes.StackStatus(referenced.Status.StackStatus)) {
+		return "", false, nil
+	}
+
+	value, found := referenced.Status.Outputs[ref.Output]
+	return value, found, nil
+}
@@ -0,0 +1,296 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// newReferencingStack creates a Stack taking a parameter from the output of another
+func newReferencingStack(name string, referenced string) *v1alpha1.Stack {
+	return &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{
+			StackName: name,
+			Template:  testTemplate,
+			ParametersFrom: []v1alpha1.ParameterSource{{
+				Name:     "Input",
+				StackRef: &v1alpha1.StackOutputReference{Name: referenced, Output: "Output"},
+			}},
+		},
+	}
+}
+
+func TestDependencyCycleRefused(t *testing.T) {
+	k8sClient := newFakeClient(newReferencingStack("stack-a", "stack-b"), newReferencingStack("stack-b", "stack-a"))
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+
+	name := types.NamespacedName{Name: "stack-a", Namespace: "default"}
+	result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsZero() || len(cfn.createInputs) != 0 {
+		t.Fatalf("expected the stack to be refused, got %v and %d creates", result, len(cfn.createInputs))
+	}
+
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionDependencyCycle)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Fatalf("expected the DependencyCycle condition, got %v", updated.Status.Conditions)
+	}
+	if condition.Message != "Stacks reference each other's outputs: stack-a -> stack-b -> stack-a" {
+		t.Errorf("unexpected message %q", condition.Message)
+	}
+}
+
+func TestStackOutputReferenceWaitsOnReferencedStack(t *testing.T) {
+	network := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "network", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "network", Template: testTemplate},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_IN_PROGRESS"},
+	}
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(network, newReferencingStack("app", "network")).
+		WithStatusSubresource(&v1alpha1.Stack{}).
+		WithIndex(&v1alpha1.Stack{}, stackRefIndex, stackRefIndexer).
+		Build()
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	name := types.NamespacedName{Name: "app", Namespace: "default"}
+	waitingMessage := func() string {
+		t.Helper()
+		updated := &v1alpha1.Stack{}
+		if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+			t.Fatal(err)
+		}
+		condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionWaitingOnStackOutput)
+		if condition == nil {
+			return ""
+		}
+		return condition.Message
+	}
+
+	// Waiting while the referenced stack is still being created
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 0 {
+		t.Fatal("expected no stack created before the referenced stack is ready")
+	}
+	if message := waitingMessage(); message != "Waiting on referenced values: network/Output" {
+		t.Fatalf("expected to wait on the referenced output, got %q", message)
+	}
+
+	// Ready without the output, still waiting
+	network.Status.StackStatus = "CREATE_COMPLETE"
+	network.Status.Outputs = map[string]string{"Other": "value"}
+	if err := k8sClient.Status().Update(context.TODO(), network); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 0 || waitingMessage() == "" {
+		t.Fatal("expected to keep waiting on the missing output")
+	}
+
+	// The output appearing enqueues the referencing stack, created with the value
+	network.Status.Outputs["Output"] = "vpc-0123456789abcdef0"
+	if err := k8sClient.Status().Update(context.TODO(), network); err != nil {
+		t.Fatal(err)
+	}
+	requests := r.stacksReferencing(stackRefIndex)(context.TODO(), network)
+	if len(requests) != 1 || requests[0].NamespacedName != name {
+		t.Fatalf("expected the referencing stack to be enqueued, got %v", requests)
+	}
+	if _, err := r.Reconcile(context.TODO(), requests[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the stack to be created, got %d creates", len(cfn.createInputs))
+	}
+	parameters := cfn.createInputs[0].Parameters
+	if len(parameters) != 1 || aws.ToString(parameters[0].ParameterValue) != "vpc-0123456789abcdef0" {
+		t.Errorf("expected the referenced output as parameter, got %v", parameters)
+	}
+	if message := waitingMessage(); message != "" {
+		t.Errorf("expected the WaitingOnStackOutput condition cleared, got %q", message)
+	}
+}
+
+func TestConfigMapChangeReconcilesReferencingStacks(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{
+			StackName: "my-bucket",
+			Template:  testTemplate,
+			ParametersFrom: []v1alpha1.ParameterSource{{
+				Name: "BucketName",
+				ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "bucket-settings"},
+					Key:                  "name",
+				},
+			}},
+		},
+	}
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(instance).
+		WithStatusSubresource(&v1alpha1.Stack{}).
+		WithIndex(&v1alpha1.Stack{}, configMapRefIndex, configMapRefIndexer).
+		Build()
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	name := types.NamespacedName{Name: "my-bucket", Namespace: "default"}
+
+	// Waiting while the ConfigMap does not exist
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 0 {
+		t.Fatal("expected no stack created before the ConfigMap exists")
+	}
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionWaitingOnStackOutput)
+	if condition == nil || condition.Message != "Waiting on referenced values: configmap/bucket-settings/name" {
+		t.Fatalf("expected to wait on the ConfigMap, got %v", updated.Status.Conditions)
+	}
+
+	// Creating the ConfigMap enqueues the Stack referencing it
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket-settings", Namespace: "default"},
+		Data:       map[string]string{"name": "my-team-bucket"},
+	}
+	if err := k8sClient.Create(context.TODO(), configMap); err != nil {
+		t.Fatal(err)
+	}
+	requests := r.stacksOfConfigMap(context.TODO(), configMap)
+	if len(requests) != 1 || requests[0].NamespacedName != name {
+		t.Fatalf("expected the referencing stack to be enqueued, got %v", requests)
+	}
+	if _, err := r.Reconcile(context.TODO(), requests[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the stack to be created, got %d creates", len(cfn.createInputs))
+	}
+	parameters := cfn.createInputs[0].Parameters
+	if len(parameters) != 1 || aws.ToString(parameters[0].ParameterValue) != "my-team-bucket" {
+		t.Errorf("expected the ConfigMap value as parameter, got %v", parameters)
+	}
+}
+
+func TestConfigMapMappedToEachStackOnce(t *testing.T) {
+	referencing := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", UID: "5a1c7e0f"},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate,
+			ParametersFrom: []v1alpha1.ParameterSource{{
+				Name: "Team",
+				ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: namespaceTagsConfigMap},
+					Key:                  "team",
+				},
+			}},
+		},
+	}
+	other := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-queue", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-queue", Template: testTemplate},
+	}
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(referencing, other).
+		WithIndex(&v1alpha1.Stack{}, configMapRefIndex, configMapRefIndexer).
+		Build()
+	r := newTestReconciler(k8sClient, newFakeCloudFormation())
+	names := func(obj *v1.ConfigMap) []string {
+		var found []string
+		for _, request := range r.stacksOfConfigMap(context.TODO(), obj) {
+			found = append(found, request.Name)
+		}
+		sort.Strings(found)
+		return found
+	}
+
+	// Both referenced and the default tags, each Stack of the namespace once
+	tags := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: namespaceTagsConfigMap, Namespace: "default"}}
+	if found := names(tags); !reflect.DeepEqual(found, []string{"my-bucket", "my-queue"}) {
+		t.Errorf("expected each Stack of the namespace once, got %v", found)
+	}
+
+	// Written with the outputs, the owning Stack
+	outputs := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "my-bucket-outputs", Namespace: "default"}}
+	if err := controllerutil.SetControllerReference(referencing, outputs, newTestScheme()); err != nil {
+		t.Fatal(err)
+	}
+	if found := names(outputs); !reflect.DeepEqual(found, []string{"my-bucket"}) {
+		t.Errorf("expected the owning Stack, got %v", found)
+	}
+
+	unrelated := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"}}
+	if found := names(unrelated); len(found) != 0 {
+		t.Errorf("expected no Stack for an unrelated ConfigMap, got %v", found)
+	}
+}
+
+func TestListParametersJoined(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "default"},
+		Spec: v1alpha1.StackSpec{
+			ListParameters: map[string][]string{"Subnets": {"subnet-1", "subnet-2", "subnet-3"}},
+		},
+	}
+	r := newTestReconciler(newFakeClient(instance), newFakeCloudFormation())
+	loop := &StackLoop{ctx: context.TODO(), instance: instance, Log: logr.Discard()}
+	if resolved, err := r.resolveParameters(loop); err != nil || !resolved {
+		t.Fatalf("expected the parameters resolved, got %v (%v)", resolved, err)
+	}
+	if loop.parameters["Subnets"] != "subnet-1,subnet-2,subnet-3" {
+		t.Errorf("expected the values joined with commas, got %q", loop.parameters["Subnets"])
+	}
+}
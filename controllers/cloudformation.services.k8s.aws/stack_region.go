@@ -0,0 +1,77 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"fmt"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// regionMismatch identifies Stacks whose stack lives in another region than the one the operator is configured for
+// (e.g. restarted with a different region), recording the RegionMismatch condition. Such stacks are left alone
+// rather than looked up, duplicated or deleted in the wrong region.
+func (r *StackReconciler) regionMismatch(loop *StackLoop) (bool, error) {
+	region := r.CloudFormationHelper.GetRegion()
+	stackRegion := loop.instance.Status.Region
+	if !r.stackInOtherRegion(loop) {
+		if removeCondition(loop.instance, v1alpha1.ConditionRegionMismatch) {
+			return false, r.updateStatus(loop)
+		}
+		return false, nil
+	}
+
+	message := fmt.Sprintf("The stack lives in %s while the operator is configured for %s", stackRegion, region)
+	loop.Log.Info("Stack in another region, leaving it alone", "stackRegion", stackRegion, "region", region)
+	if setCondition(loop.instance, v1alpha1.ConditionRegionMismatch, metav1.ConditionTrue, "RegionChanged", message) {
+		r.Recorder.Event(loop.instance, v1.EventTypeWarning, v1alpha1.ConditionRegionMismatch, message)
+		return true, r.updateStatus(loop)
+	}
+	return true, nil
+}
+
+// stackInOtherRegion identifies a stack living in another region than the one the operator is configured for.
+func (r *StackReconciler) stackInOtherRegion(loop *StackLoop) bool {
+	region := r.CloudFormationHelper.GetRegion()
+	stackRegion := loop.instance.Status.Region
+	return region != "" && stackRegion != "" && stackRegion != region && loop.instance.Status.StackID != "" &&
+		loop.instance.Status.StackStatus != string(cfTypes.StackStatusDeleteComplete)
+}
+
+// leaveStackInOtherRegion identifies a Stack being deleted whose stack lives in another region, which the operator
+// can't reach to delete it. The stack is left in place, with a Warning event, so the resource isn't stuck deleting.
+func (r *StackReconciler) leaveStackInOtherRegion(loop *StackLoop) bool {
+	if !r.stackInOtherRegion(loop) {
+		return false
+	}
+	message := fmt.Sprintf("The stack %s lives in %s while the operator is configured for %s, it is left in place",
+		loop.instance.Status.StackID, loop.instance.Status.Region, r.CloudFormationHelper.GetRegion())
+	loop.Log.Info("Stack in another region, finalizing without deleting it", "stackRegion", loop.instance.Status.Region)
+	r.Recorder.Event(loop.instance, v1.EventTypeWarning, "StackLeftInRegion", message)
+	return true
+}
@@ -0,0 +1,121 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestRegionMismatchLeavesStackAlone(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE", Region: "us-east-1",
+			AccountID: "123456789012"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	r := newTestReconciler(k8sClient, cfn)
+	r.CloudFormationHelper.Region = "eu-west-1"
+	name := types.NamespacedName{Name: "my-bucket", Namespace: "default"}
+
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if cfn.describes != 0 || len(cfn.createInputs) != 0 || len(cfn.updateInputs) != 0 {
+		t.Fatalf("expected the stack left alone, got %d describes, %d creates and %d updates", cfn.describes,
+			len(cfn.createInputs), len(cfn.updateInputs))
+	}
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionRegionMismatch)
+	if condition == nil || condition.Message != "The stack lives in us-east-1 while the operator is configured for eu-west-1" {
+		t.Fatalf("expected the RegionMismatch condition, got %v", updated.Status.Conditions)
+	}
+
+	// Configured back for the region of the stack
+	r.CloudFormationHelper.Region = "us-east-1"
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionRegionMismatch) != nil {
+		t.Errorf("expected the RegionMismatch condition removed, got %v", updated.Status.Conditions)
+	}
+	if cfn.describes == 0 {
+		t.Error("expected the stack reconciled again")
+	}
+}
+
+func TestRegionMismatchDeletionLeavesStackInPlace(t *testing.T) {
+	now := metav1.Now()
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", DeletionTimestamp: &now,
+			Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE", Region: "us-east-1",
+			AccountID: "123456789012"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete)
+	r := newTestReconciler(k8sClient, cfn)
+	r.CloudFormationHelper.Region = "eu-west-1"
+	name := types.NamespacedName{Name: "my-bucket", Namespace: "default"}
+
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.deleteInputs) != 0 {
+		t.Fatalf("expected no deletion in the configured region, got %d deletes", len(cfn.deleteInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), name, &v1alpha1.Stack{}); !errors.IsNotFound(err) {
+		t.Fatalf("expected the finalizer dropped and the Stack gone, got %v", err)
+	}
+	select {
+	case event := <-r.Recorder.(*record.FakeRecorder).Events:
+		if !strings.Contains(event, "StackLeftInRegion") || !strings.Contains(event, "us-east-1") {
+			t.Errorf("unexpected event %q", event)
+		}
+	default:
+		t.Error("expected a warning of the stack left in its region")
+	}
+}
@@ -0,0 +1,105 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// removedRequiredParameters lists the parameters of the stack left out of the update which the template still
+// declares without a default value. CloudFormation refuses such updates, the parameters must be given again or the
+// template changed. Parameters left out which the template declares with a default value fall back to it.
+func (r *StackReconciler) removedRequiredParameters(loop *StackLoop, input *cloudformation.UpdateStackInput) ([]string,
+	error) {
+	cfs, err := r.getStack(loop, false)
+	if err != nil {
+		return nil, err
+	}
+
+	given := map[string]bool{}
+	for _, parameter := range input.Parameters {
+		given[aws.ToString(parameter.ParameterKey)] = true
+	}
+	for name := range loop.sensitiveVersions {
+		given[name] = true
+	}
+	removed := map[string]bool{}
+	for _, parameter := range cfs.Parameters {
+		if !given[aws.ToString(parameter.ParameterKey)] {
+			removed[aws.ToString(parameter.ParameterKey)] = true
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	summaryInput := &cloudformation.GetTemplateSummaryInput{TemplateBody: input.TemplateBody,
+		TemplateURL: input.TemplateURL}
+	if aws.ToBool(input.UsePreviousTemplate) {
+		summaryInput.StackName = input.StackName
+	}
+	callCtx, cancel := r.CloudFormationHelper.callContextFor(loop.ctx, loop.instance)
+	summary, err := r.CloudFormationHelper.CloudFormationFor(loop.instance).GetTemplateSummary(callCtx, summaryInput)
+	err = callError(loop.ctx, callCtx, err)
+	cancel()
+	if err != nil {
+		loop.Log.Error(err, "Failed to read the parameters of the template")
+		return nil, err
+	}
+
+	var required []string
+	for _, declaration := range summary.Parameters {
+		if removed[aws.ToString(declaration.ParameterKey)] && declaration.DefaultValue == nil {
+			required = append(required, aws.ToString(declaration.ParameterKey))
+		}
+	}
+	return required, nil
+}
+
+// refuseRemovedParameters records the InvalidParameters condition when parameters removed from the spec are still
+// required by the template, returning whether the update is refused.
+func (r *StackReconciler) refuseRemovedParameters(loop *StackLoop, input *cloudformation.UpdateStackInput) (bool,
+	error) {
+	required, err := r.removedRequiredParameters(loop, input)
+	if err != nil || len(required) == 0 {
+		return false, err
+	}
+
+	message := "Parameters removed from the spec are still required by the template (no default value): " +
+		strings.Join(required, ", ")
+	loop.Log.Info("Stack update refused", "requiredParameters", required)
+	if setCondition(loop.instance, v1alpha1.ConditionInvalidParameters, metav1.ConditionTrue,
+		"RemovedParameterRequired", message) {
+		r.Recorder.Event(loop.instance, v1.EventTypeWarning, v1alpha1.ConditionInvalidParameters, message)
+		return true, r.updateStatus(loop)
+	}
+	return true, nil
+}
@@ -0,0 +1,89 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+func TestRemovedRequiredParameterRefused(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate})
+	loop.parameters = map[string]string{"BucketName": "my-team-bucket"}
+	cfn.stacks[testStackID].Parameters = []cfTypes.Parameter{
+		{ParameterKey: aws.String("BucketName"), ParameterValue: aws.String("my-team-bucket")},
+		{ParameterKey: aws.String("Versioning"), ParameterValue: aws.String("Enabled")},
+		{ParameterKey: aws.String("Tier"), ParameterValue: aws.String("STANDARD_IA")},
+	}
+	cfn.templateParameters = []cfTypes.ParameterDeclaration{
+		{ParameterKey: aws.String("BucketName")},
+		{ParameterKey: aws.String("Versioning")},
+		{ParameterKey: aws.String("Tier"), DefaultValue: aws.String("STANDARD")},
+	}
+
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 0 {
+		t.Fatal("expected the update refused while a removed parameter is required")
+	}
+	condition := meta.FindStatusCondition(loop.instance.Status.Conditions, v1alpha1.ConditionInvalidParameters)
+	if condition == nil || condition.Message !=
+		"Parameters removed from the spec are still required by the template (no default value): Versioning" {
+		t.Fatalf("expected the InvalidParameters condition, got %v", loop.instance.Status.Conditions)
+	}
+
+	// Parameters with a default value can be removed
+	loop.parameters["Versioning"] = "Suspended"
+	if err := r.updateStack(loop); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 || !loop.submitted {
+		t.Fatalf("expected the update submitted, got %d updates", len(cfn.updateInputs))
+	}
+}
+
+func TestRemovedParametersCallTimeout(t *testing.T) {
+	r, cfn, loop := newUpdateLoop(t, v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate})
+	loop.parameters = map[string]string{"BucketName": "my-team-bucket"}
+	cfn.stacks[testStackID].Parameters = []cfTypes.Parameter{
+		{ParameterKey: aws.String("BucketName"), ParameterValue: aws.String("my-team-bucket")},
+		{ParameterKey: aws.String("Versioning"), ParameterValue: aws.String("Enabled")},
+	}
+	r.CloudFormationHelper.CloudFormation = &hangingTemplateReads{cfn}
+	r.CloudFormationHelper.CallTimeout = 10 * time.Millisecond
+
+	if err := r.updateStack(loop); !IsCallTimeout(err) {
+		t.Fatalf("expected reading the template parameters bound by the call timeout, got %v", err)
+	}
+	if len(cfn.updateInputs) != 0 {
+		t.Errorf("expected no update without checking the removed parameters, got %d updates", len(cfn.updateInputs))
+	}
+}
@@ -0,0 +1,51 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// invalidSpec validates the spec as the webhook does, recording the InvalidSpec condition and leaving the stack alone
+// until the spec is fixed. Retrying won't help, the change to the spec triggers the next reconcile.
+func (r *StackReconciler) invalidSpec(loop *StackLoop) (bool, error) {
+	errs := loop.instance.Validate()
+	if len(errs) == 0 {
+		if removeCondition(loop.instance, v1alpha1.ConditionInvalidSpec) {
+			return false, r.updateStatus(loop)
+		}
+		return false, nil
+	}
+
+	message := errs.ToAggregate().Error()
+	loop.Log.Info("Invalid stack spec", "errors", message)
+	if setCondition(loop.instance, v1alpha1.ConditionInvalidSpec, metav1.ConditionTrue, "ValidationFailed", message) {
+		r.Recorder.Event(loop.instance, v1.EventTypeWarning, v1alpha1.ConditionInvalidSpec, message)
+		return true, r.updateStatus(loop)
+	}
+	return true, nil
+}
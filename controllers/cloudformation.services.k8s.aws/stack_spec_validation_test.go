@@ -0,0 +1,152 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestInvalidSpecLeftAlone(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate,
+			TemplateUrl: "https://my-bucket.s3.amazonaws.com/stack.yaml"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	name := types.NamespacedName{Name: "my-bucket", Namespace: "default"}
+
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 0 {
+		t.Fatalf("expected no stack created, got %d creates", len(cfn.createInputs))
+	}
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionInvalidSpec)
+	if condition == nil || condition.Message != "spec.templateUrl: Forbidden: "+v1alpha1.ErrBothTemplateAndUrl.Error() {
+		t.Fatalf("expected the InvalidSpec condition, got %v", updated.Status.Conditions)
+	}
+
+	// Fixing the spec lets the stack through
+	updated.Spec.TemplateUrl = ""
+	if err := k8sClient.Update(context.TODO(), updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the stack created, got %d creates", len(cfn.createInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionInvalidSpec) != nil {
+		t.Errorf("expected the InvalidSpec condition removed, got %v", updated.Status.Conditions)
+	}
+}
+
+func TestPseudoParameterNotSubmitted(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate,
+			Parameters: map[string]string{"AWS::Region": "eu-west-1"}},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	name := types.NamespacedName{Name: "my-bucket", Namespace: "default"}
+
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 0 {
+		t.Fatalf("expected no stack created, got %d creates", len(cfn.createInputs))
+	}
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionInvalidSpec)
+	if condition == nil || condition.Message !=
+		`spec.parameters[AWS::Region]: Invalid value: "AWS::Region": `+v1alpha1.ErrPseudoParameter.Error() {
+		t.Fatalf("expected the pseudo parameter reported, got %v", updated.Status.Conditions)
+	}
+}
+
+func TestSSMParameterPassedThrough(t *testing.T) {
+	template := `Parameters:
+  ImageId:
+    Type: AWS::SSM::Parameter::Value<AWS::EC2::Image::Id>
+  SubnetIds:
+    Type: AWS::SSM::Parameter::Value<List<String>>
+Resources:
+  Instance:
+    Type: AWS::EC2::Instance
+    Properties:
+      ImageId: !Ref ImageId
+      SubnetId: !Select [0, !Ref SubnetIds]
+`
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-instance", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-instance", Template: template,
+			Parameters: map[string]string{
+				"ImageId":   "/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64",
+				"SubnetIds": "/my-app/subnet-ids",
+			}},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	name := types.NamespacedName{Name: "my-instance", Namespace: "default"}
+
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 {
+		t.Fatalf("expected the stack created, got %d creates", len(cfn.createInputs))
+	}
+	submitted := map[string]string{}
+	for _, parameter := range cfn.createInputs[0].Parameters {
+		submitted[aws.ToString(parameter.ParameterKey)] = aws.ToString(parameter.ParameterValue)
+	}
+	if !reflect.DeepEqual(submitted, instance.Spec.Parameters) {
+		t.Errorf("expected the SSM parameter names submitted as is, got %v", submitted)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// patchStatus writes the changes made to the status of the stack since base as a merge patch. Unlike an update, it
+// neither conflicts with nor undoes the fields the reconciler and the follower write concurrently, each only sending
+// the fields it changed. The lists are each owned by the follower (history, resources, nested stacks, notification
+// ARNs) but the conditions, shared and written one condition at a time on top of the latest ones.
+func patchStatus(ctx context.Context, c client.Client, instance *v1alpha1.Stack, base *v1alpha1.StackStatus) error {
+	if equality.Semantic.DeepEqual(base.Conditions, instance.Status.Conditions) {
+		original := instance.DeepCopy()
+		original.Status = *base.DeepCopy()
+		return c.Status().Patch(ctx, instance, client.MergeFrom(original))
+	}
+
+	// The conditions changed are applied to the latest ones, the patch failing should they change in the meantime
+	changed := instance.Status.DeepCopy()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha1.Stack{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(instance), latest); err != nil {
+			return err
+		}
+		original := instance.DeepCopy()
+		original.ResourceVersion = latest.ResourceVersion
+		original.Status = *base.DeepCopy()
+		original.Status.Conditions = latest.Status.Conditions
+		instance.ResourceVersion = latest.ResourceVersion
+		instance.Status = *changed.DeepCopy()
+		instance.Status.Conditions = mergeConditions(latest.Status.Conditions, base.Conditions, changed.Conditions)
+		return c.Status().Patch(ctx, instance, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+	})
+}
+
+// mergeConditions applies the conditions set or removed going from base to changed onto the latest conditions.
+func mergeConditions(latest, base, changed []metav1.Condition) []metav1.Condition {
+	merged := make([]metav1.Condition, len(latest))
+	copy(merged, latest)
+	for _, condition := range changed {
+		if previous := meta.FindStatusCondition(base, condition.Type); previous == nil ||
+			!equality.Semantic.DeepEqual(*previous, condition) {
+			meta.SetStatusCondition(&merged, condition)
+		}
+	}
+	for _, condition := range base {
+		if meta.FindStatusCondition(changed, condition.Type) == nil {
+			meta.RemoveStatusCondition(&merged, condition.Type)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,239 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newAtomicStatusClient provides a fake client whose status patches are applied atomically, as by the API server,
+// the fake client otherwise losing concurrent writes.
+func newAtomicStatusClient(objects ...client.Object) client.Client {
+	var lock sync.Mutex
+	return fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(objects...).
+		WithStatusSubresource(&v1alpha1.Stack{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object,
+				patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				lock.Lock()
+				defer lock.Unlock()
+				return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+}
+
+func TestStatusWritesDoNotClobber(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_IN_PROGRESS"},
+	}
+	k8sClient := newFakeClient(instance)
+	r := newTestReconciler(k8sClient, newFakeCloudFormation())
+	key := types.NamespacedName{Name: "my-bucket", Namespace: "default"}
+
+	// The reconciler reads the stack
+	loop := &StackLoop{ctx: context.TODO(), instance: &v1alpha1.Stack{}, Log: logr.Discard()}
+	if err := k8sClient.Get(context.TODO(), key, loop.instance); err != nil {
+		t.Fatal(err)
+	}
+	loop.status = *loop.instance.Status.DeepCopy()
+
+	// The follower records the stack settling meanwhile
+	followed := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), key, followed); err != nil {
+		t.Fatal(err)
+	}
+	base := followed.Status.DeepCopy()
+	followed.Status.StackStatus = "UPDATE_COMPLETE"
+	followed.Status.Outputs = map[string]string{"BucketName": "my-bucket-1a2b3c"}
+	if err := patchStatus(context.TODO(), k8sClient, followed, base); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reconciler writes its own status fields from the stale copy
+	setCondition(loop.instance, v1alpha1.ConditionBlockedByAlarm, metav1.ConditionTrue, "AlarmActive", "Alarm is firing")
+	if err := r.updateStatus(loop); err != nil {
+		t.Fatalf("expected the status written despite the concurrent write, got %v", err)
+	}
+
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(instance), stack); err != nil {
+		t.Fatal(err)
+	}
+	if stack.Status.StackStatus != "UPDATE_COMPLETE" ||
+		!reflect.DeepEqual(stack.Status.Outputs, map[string]string{"BucketName": "my-bucket-1a2b3c"}) {
+		t.Errorf("expected the follower's fields kept, got %v, %v", stack.Status.StackStatus, stack.Status.Outputs)
+	}
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionBlockedByAlarm) == nil {
+		t.Errorf("expected the reconciler's condition written, got %v", stack.Status.Conditions)
+	}
+	if !reflect.DeepEqual(loop.status, stack.Status) {
+		t.Errorf("expected the loop caught up with the status written, got %v", loop.status)
+	}
+}
+
+func TestConcurrentStatusWritersKeepTheirFields(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_COMPLETE"},
+	}
+	k8sClient := newAtomicStatusClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete)
+	r := newTestReconciler(k8sClient, cfn)
+	follower := newTestFollower(k8sClient, cfn)
+	follower.Recorder = record.NewFakeRecorder(100)
+	key := types.NamespacedName{Name: "my-bucket", Namespace: "default"}
+	const writes = 20
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		// The follower records the outputs of the stack
+		defer wg.Done()
+		for i := 0; i < writes; i++ {
+			stack := &v1alpha1.Stack{}
+			if err := k8sClient.Get(context.TODO(), key, stack); err != nil {
+				t.Error(err)
+				return
+			}
+			cfs := &cfTypes.Stack{StackName: aws.String("my-bucket"), StackId: aws.String(testStackID),
+				StackStatus: cfTypes.StackStatusUpdateComplete, Outputs: []cfTypes.Output{
+					{OutputKey: aws.String("Version"), OutputValue: aws.String(fmt.Sprint(i))},
+				}}
+			if err := follower.updateStackStatus(context.TODO(), stack, cfs); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		// The reconciler records its own condition
+		defer wg.Done()
+		for i := 0; i < writes; i++ {
+			loop := &StackLoop{ctx: context.TODO(), instance: &v1alpha1.Stack{}, Log: logr.Discard()}
+			if err := k8sClient.Get(context.TODO(), key, loop.instance); err != nil {
+				t.Error(err)
+				return
+			}
+			loop.status = *loop.instance.Status.DeepCopy()
+			setCondition(loop.instance, v1alpha1.ConditionBlockedByAlarm, metav1.ConditionTrue, "AlarmActive",
+				fmt.Sprintf("Alarm %d is firing", i))
+			if err := r.updateStatus(loop); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), key, stack); err != nil {
+		t.Fatal(err)
+	}
+	if stack.Status.Outputs["Version"] != fmt.Sprint(writes-1) {
+		t.Errorf("expected the follower's last outputs, got %v", stack.Status.Outputs)
+	}
+	if ready := meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionReady); ready == nil ||
+		ready.Status != metav1.ConditionTrue {
+		t.Errorf("expected the follower's Ready condition kept, got %v", stack.Status.Conditions)
+	}
+	blocked := meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionBlockedByAlarm)
+	if blocked == nil || blocked.Message != fmt.Sprintf("Alarm %d is firing", writes-1) {
+		t.Errorf("expected the reconciler's last condition, got %v", stack.Status.Conditions)
+	}
+}
+
+func TestMergeConditions(t *testing.T) {
+	condition := func(conditionType string, status metav1.ConditionStatus) metav1.Condition {
+		return metav1.Condition{Type: conditionType, Status: status, Reason: "Test"}
+	}
+	tests := []struct {
+		name     string
+		latest   []metav1.Condition
+		base     []metav1.Condition
+		changed  []metav1.Condition
+		expected []metav1.Condition
+	}{
+		{
+			name:     "set kept alongside a condition written meanwhile",
+			latest:   []metav1.Condition{condition("Ready", metav1.ConditionTrue)},
+			changed:  []metav1.Condition{condition("BlockedByAlarm", metav1.ConditionTrue)},
+			expected: []metav1.Condition{condition("Ready", metav1.ConditionTrue), condition("BlockedByAlarm", metav1.ConditionTrue)},
+		},
+		{
+			name:     "unchanged condition not reverted",
+			latest:   []metav1.Condition{condition("Ready", metav1.ConditionTrue)},
+			base:     []metav1.Condition{condition("Ready", metav1.ConditionFalse)},
+			changed:  []metav1.Condition{condition("Ready", metav1.ConditionFalse), condition("Rejected", metav1.ConditionTrue)},
+			expected: []metav1.Condition{condition("Ready", metav1.ConditionTrue), condition("Rejected", metav1.ConditionTrue)},
+		},
+		{
+			name:     "removed",
+			latest:   []metav1.Condition{condition("Ready", metav1.ConditionTrue), condition("Rejected", metav1.ConditionTrue)},
+			base:     []metav1.Condition{condition("Rejected", metav1.ConditionTrue)},
+			expected: []metav1.Condition{condition("Ready", metav1.ConditionTrue)},
+		},
+		{
+			name:     "updated",
+			latest:   []metav1.Condition{condition("Rejected", metav1.ConditionTrue)},
+			base:     []metav1.Condition{condition("Rejected", metav1.ConditionTrue)},
+			changed:  []metav1.Condition{condition("Rejected", metav1.ConditionFalse)},
+			expected: []metav1.Condition{condition("Rejected", metav1.ConditionFalse)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeConditions(tt.latest, tt.base, tt.changed)
+			for i := range merged {
+				merged[i].LastTransitionTime = metav1.Time{}
+			}
+			if !reflect.DeepEqual(merged, tt.expected) {
+				t.Errorf("mergeConditions() = %v, want %v", merged, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	coreerrors "errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestSubmitRequeueAfterCreate(t *testing.T) {
+	for _, delay := range []time.Duration{0, 30 * time.Second} {
+		instance := &v1alpha1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+			Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		}
+		cfn := newFakeCloudFormation()
+		r := newTestReconciler(newFakeClient(instance), cfn)
+		r.SubmitRequeueAfter = delay
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+		result, err := r.Reconcile(context.TODO(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfn.createInputs) != 1 {
+			t.Fatalf("expected the stack created, got %d creates", len(cfn.createInputs))
+		}
+		if result.RequeueAfter != delay {
+			t.Errorf("expected a recheck after %v, got %v", delay, result.RequeueAfter)
+		}
+	}
+}
+
+func TestSubmitRequeueAfterUpdateOnly(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	r := newTestReconciler(k8sClient, cfn)
+	r.SubmitRequeueAfter = 30 * time.Second
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	result, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 || result.RequeueAfter != r.SubmitRequeueAfter {
+		t.Fatalf("expected the update submitted and rechecked after %v, got %d updates and %v",
+			r.SubmitRequeueAfter, len(cfn.updateInputs), result.RequeueAfter)
+	}
+
+	// Nothing submitted, nothing to recheck
+	result, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 || result.RequeueAfter != 0 {
+		t.Errorf("expected no update and no recheck while applied, got %d updates and %v",
+			len(cfn.updateInputs), result.RequeueAfter)
+	}
+
+	// Found up to date by CloudFormation, neither
+	stack := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+		t.Fatal(err)
+	}
+	stack.Spec.Tags = map[string]string{"team": "storage"}
+	if err := k8sClient.Update(context.TODO(), stack); err != nil {
+		t.Fatal(err)
+	}
+	cfn.updateErr = coreerrors.New("operation error CloudFormation: UpdateStack, api error ValidationError: " +
+		"No updates are to be performed.")
+	result, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 2 || result.RequeueAfter != 0 {
+		t.Errorf("expected no recheck without an operation, got %d updates and %v",
+			len(cfn.updateInputs), result.RequeueAfter)
+	}
+}
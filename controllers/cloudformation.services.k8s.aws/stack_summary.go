@@ -0,0 +1,192 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=cloudformation.services.k8s.aws.cuppett.dev,resources=stacksummaries,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=cloudformation.services.k8s.aws.cuppett.dev,resources=stacksummaries/status,verbs=get;update;patch
+
+const (
+	// Name of the StackSummary maintained by the controller
+	stackSummaryName = "stacks"
+	// Stacks listed as failing or drifted in the summary, beyond which they are only counted
+	maxSummaryItems = 100
+	// Stack status counted for the Stacks whose stack is not created yet
+	pendingStackStatus = "PENDING"
+)
+
+// StackSummarizer periodically aggregates the statuses of the Stacks, as kept by the StackFollower, into the
+// cluster-scoped StackSummary, along with the stacks found drifted by their latest drift detection.
+type StackSummarizer struct {
+	client.Client
+	Log                  logr.Logger
+	CloudFormationHelper *CloudFormationHelper
+	// Interval between updates of the summary
+	Interval time.Duration
+}
+
+// Start updates the summary every Interval until the context is done.
+func (s *StackSummarizer) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.summarize(ctx); err != nil {
+			s.Log.Error(err, "Failed to update the stack summary")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection has only the leader update the summary.
+func (s *StackSummarizer) NeedLeaderElection() bool {
+	return true
+}
+
+// summarize computes the summary and records it in the status of the StackSummary, creating it when missing.
+func (s *StackSummarizer) summarize(ctx context.Context) error {
+	stackList := &v1alpha1.StackList{}
+	if err := s.List(ctx, stackList); err != nil {
+		return err
+	}
+	drifted, err := s.driftedStacks(ctx)
+	if err != nil {
+		return err
+	}
+	status := summarizeStacks(stackList.Items, drifted, s.CloudFormationHelper)
+
+	summary := &v1alpha1.StackSummary{}
+	err = s.Get(ctx, types.NamespacedName{Name: stackSummaryName}, summary)
+	if errors.IsNotFound(err) {
+		summary.Name = stackSummaryName
+		if err = s.Create(ctx, summary); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	now := metav1.Now()
+	status.LastUpdated = &now
+	summary.Status = status
+	return s.Status().Update(ctx, summary)
+}
+
+// driftedStacks lists the IDs of the stacks found drifted by their latest drift detection.
+func (s *StackSummarizer) driftedStacks(ctx context.Context) (map[string]bool, error) {
+	drifted := map[string]bool{}
+	paginator := cloudformation.NewDescribeStacksPaginator(s.CloudFormationHelper.GetCloudFormation(),
+		&cloudformation.DescribeStacksInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, stack := range page.Stacks {
+			if stack.DriftInformation != nil &&
+				stack.DriftInformation.StackDriftStatus == cfTypes.StackDriftStatusDrifted {
+				drifted[aws.ToString(stack.StackId)] = true
+			}
+		}
+	}
+	return drifted, nil
+}
+
+// summarizeStacks aggregates the statuses of the Stacks, failing being those settled in a status not considered
+// healthy.
+func summarizeStacks(stacks []v1alpha1.Stack, drifted map[string]bool,
+	helper *CloudFormationHelper) v1alpha1.StackSummaryStatus {
+	sort.Slice(stacks, func(i, j int) bool {
+		if stacks[i].Namespace != stacks[j].Namespace {
+			return stacks[i].Namespace < stacks[j].Namespace
+		}
+		return stacks[i].Name < stacks[j].Name
+	})
+
+	status := v1alpha1.StackSummaryStatus{Total: int32(len(stacks)), ByStatus: map[string]int32{}}
+	for _, stack := range stacks {
+		stackStatus := stack.Status.StackStatus
+		if stackStatus == "" {
+			stackStatus = pendingStackStatus
+		}
+		status.ByStatus[stackStatus]++
+
+		ready := meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionReady)
+		item := v1alpha1.StackSummaryItem{Namespace: stack.Namespace, Name: stack.Name,
+			StackStatus: stack.Status.StackStatus}
+		if ready != nil {
+			item.Reason = ready.Message
+		}
+		switch {
+		case ready != nil && ready.Status == metav1.ConditionTrue:
+			status.Ready++
+		case stackFailing(&stack, ready, helper):
+			status.FailingCount++
+			if len(status.Failing) < maxSummaryItems {
+				status.Failing = append(status.Failing, item)
+			}
+		}
+
+		if stack.Status.StackID != "" && drifted[stack.Status.StackID] {
+			status.DriftedCount++
+			if len(status.Drifted) < maxSummaryItems {
+				status.Drifted = append(status.Drifted, item)
+			}
+		}
+	}
+	return status
+}
+
+// stackFailing identifies a Stack not Ready which settled, or failed to create (onFailure: DELETE).
+func stackFailing(stack *v1alpha1.Stack, ready *metav1.Condition, helper *CloudFormationHelper) bool {
+	if meta.IsStatusConditionTrue(stack.Status.Conditions, v1alpha1.ConditionCreateFailed) {
+		return true
+	}
+	stackStatus := cfTypes.StackStatus(stack.Status.StackStatus)
+	if stackStatus == "" || !helper.StackInTerminalState(stackStatus) {
+		return false
+	}
+	// Without a Ready condition yet, by the status alone
+	return ready != nil || !helper.StackInReadyState(stackStatus)
+}
@@ -0,0 +1,141 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func summarizedStack(namespace string, name string, stackId string, status string,
+	ready metav1.ConditionStatus) *v1alpha1.Stack {
+	stack := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Status:     v1alpha1.StackStatus{StackID: stackId, StackStatus: status},
+	}
+	if ready != "" {
+		stack.Status.Conditions = []metav1.Condition{{Type: v1alpha1.ConditionReady, Status: ready,
+			Reason: "Test", Message: "Stack is " + status}}
+	}
+	return stack
+}
+
+func TestStackSummary(t *testing.T) {
+	bucketID := "arn:aws:cloudformation:us-east-1:123456789012:stack/my-bucket/1"
+	queueID := "arn:aws:cloudformation:us-east-1:123456789012:stack/my-queue/1"
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(
+			summarizedStack("team-a", "my-bucket", bucketID, "CREATE_COMPLETE", metav1.ConditionTrue),
+			summarizedStack("team-a", "my-queue", queueID, "UPDATE_ROLLBACK_COMPLETE", metav1.ConditionFalse),
+			summarizedStack("team-b", "my-db", testStackID, "CREATE_IN_PROGRESS", metav1.ConditionFalse),
+			summarizedStack("team-b", "my-topic", "", "", ""),
+		).
+		WithStatusSubresource(&v1alpha1.Stack{}, &v1alpha1.StackSummary{}).
+		Build()
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", bucketID, cfTypes.StackStatusCreateComplete).DriftInformation =
+		&cfTypes.StackDriftInformation{StackDriftStatus: cfTypes.StackDriftStatusDrifted}
+	cfn.addStack("my-queue", queueID, cfTypes.StackStatusUpdateRollbackComplete).DriftInformation =
+		&cfTypes.StackDriftInformation{StackDriftStatus: cfTypes.StackDriftStatusInSync}
+	cfn.addStack("my-db", testStackID, cfTypes.StackStatusCreateInProgress)
+	summarizer := &StackSummarizer{
+		Client:               k8sClient,
+		Log:                  logr.Discard(),
+		CloudFormationHelper: &CloudFormationHelper{CloudFormation: cfn},
+	}
+
+	if err := summarizer.summarize(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	summary := &v1alpha1.StackSummary{}
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: stackSummaryName}, summary); err != nil {
+		t.Fatal(err)
+	}
+	status := summary.Status
+	if status.Total != 4 || status.Ready != 1 {
+		t.Errorf("expected 4 Stacks, 1 ready, got %d, %d", status.Total, status.Ready)
+	}
+	expected := map[string]int32{"CREATE_COMPLETE": 1, "UPDATE_ROLLBACK_COMPLETE": 1, "CREATE_IN_PROGRESS": 1,
+		pendingStackStatus: 1}
+	for stackStatus, count := range expected {
+		if status.ByStatus[stackStatus] != count {
+			t.Errorf("expected %d Stacks %s, got %v", count, stackStatus, status.ByStatus)
+		}
+	}
+	if status.FailingCount != 1 || len(status.Failing) != 1 || status.Failing[0].Name != "my-queue" ||
+		status.Failing[0].Reason != "Stack is UPDATE_ROLLBACK_COMPLETE" {
+		t.Errorf("expected my-queue failing, got %v", status.Failing)
+	}
+	if status.DriftedCount != 1 || len(status.Drifted) != 1 || status.Drifted[0].Name != "my-bucket" {
+		t.Errorf("expected my-bucket drifted, got %v", status.Drifted)
+	}
+	if status.LastUpdated == nil {
+		t.Error("expected the time of the summary")
+	}
+
+	// Updated in place on the next pass
+	cfn.addStack("my-queue", queueID, cfTypes.StackStatusUpdateComplete)
+	queue := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Namespace: "team-a", Name: "my-queue"},
+		queue); err != nil {
+		t.Fatal(err)
+	}
+	queue.Status.StackStatus = "UPDATE_COMPLETE"
+	queue.Status.Conditions[0].Status = metav1.ConditionTrue
+	if err := k8sClient.Status().Update(context.TODO(), queue); err != nil {
+		t.Fatal(err)
+	}
+	if err := summarizer.summarize(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: stackSummaryName}, summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Status.Ready != 2 || summary.Status.FailingCount != 0 || len(summary.Status.Failing) != 0 {
+		t.Errorf("expected the recovered stack no longer failing, got %v", summary.Status)
+	}
+}
+
+func TestStackSummaryCapsItems(t *testing.T) {
+	var stacks []v1alpha1.Stack
+	for i := 0; i < maxSummaryItems+5; i++ {
+		stacks = append(stacks, *summarizedStack("default", fmt.Sprintf("stack-%d", i), "", "ROLLBACK_COMPLETE",
+			metav1.ConditionFalse))
+	}
+	status := summarizeStacks(stacks, nil, &CloudFormationHelper{})
+	if status.FailingCount != maxSummaryItems+5 || len(status.Failing) != maxSummaryItems {
+		t.Errorf("expected all failing counted and %d listed, got %d, %d", maxSummaryItems, status.FailingCount,
+			len(status.Failing))
+	}
+}
@@ -0,0 +1,72 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// Largest template recorded in the status, only the digest of larger templates is recorded
+const maxRecordedTemplateSize = 64 * 1024
+
+// recordAppliedTemplate records the template just submitted in the status of Stacks asking for it. Templates given by
+// URL (or reused from the stack) are read back from the stack.
+func (r *StackReconciler) recordAppliedTemplate(loop *StackLoop) {
+	if !loop.instance.Spec.RecordTemplateInStatus {
+		loop.instance.Status.AppliedTemplate = ""
+		loop.instance.Status.AppliedTemplateDigest = ""
+		return
+	}
+
+	template := loop.instance.Spec.Template
+	if template == "" {
+		callCtx, cancel := r.CloudFormationHelper.callContextFor(loop.ctx, loop.instance)
+		output, err := r.CloudFormationHelper.CloudFormationFor(loop.instance).GetTemplate(callCtx,
+			&cloudformation.GetTemplateInput{
+				StackName:     aws.String(loop.instance.Status.StackID),
+				TemplateStage: cfTypes.TemplateStageOriginal,
+			})
+		err = callError(loop.ctx, callCtx, err)
+		cancel()
+		if err != nil {
+			loop.Log.Error(err, "Failed to read back the template to record")
+			return
+		}
+		template = aws.ToString(output.TemplateBody)
+	}
+
+	loop.instance.Status.AppliedTemplateDigest = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(template)))
+	if len(template) > maxRecordedTemplateSize {
+		loop.Log.Info("Template too large to record, only recording its digest", "size", len(template))
+		loop.instance.Status.AppliedTemplate = ""
+	} else {
+		loop.instance.Status.AppliedTemplate = template
+	}
+}
@@ -0,0 +1,67 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestTemplateRecordedInStatus(t *testing.T) {
+	large := testTemplate + "# " + strings.Repeat("x", maxRecordedTemplateSize) + "\n"
+	for _, template := range []string{testTemplate, large} {
+		instance := &v1alpha1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default",
+				Finalizers: []string{stacksFinalizer}},
+			Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: template, RecordTemplateInStatus: true},
+		}
+		k8sClient := newFakeClient(instance)
+		r := newTestReconciler(k8sClient, newFakeCloudFormation())
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatal(err)
+		}
+
+		created := &v1alpha1.Stack{}
+		if err := k8sClient.Get(context.TODO(), req.NamespacedName, created); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(created.Status.AppliedTemplateDigest, "sha256:") {
+			t.Errorf("expected the template digest recorded, got %q", created.Status.AppliedTemplateDigest)
+		}
+		if template == testTemplate && created.Status.AppliedTemplate != testTemplate {
+			t.Errorf("expected the template recorded, got %q", created.Status.AppliedTemplate)
+		}
+		if template == large && created.Status.AppliedTemplate != "" {
+			t.Errorf("expected only the digest of the large template recorded")
+		}
+	}
+}
@@ -0,0 +1,151 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// newTTLStack prepares a Stack created the given time ago, with the TTL given when not zero.
+func newTTLStack(age time.Duration, ttl time.Duration) (*StackReconciler, *fakeCloudFormation, ctrl.Request) {
+	created := metav1.NewTime(time.Now().Add(-age))
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_COMPLETE",
+			CreatedTime: &created},
+	}
+	if ttl > 0 {
+		instance.Spec.TTL = &metav1.Duration{Duration: ttl}
+	}
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete).Tags = []cfTypes.Tag{
+		{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+	}
+	r := newTestReconciler(newFakeClient(instance), cfn)
+	return r, cfn, ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+}
+
+func TestTTLNotExpiredRequeuesAtExpiration(t *testing.T) {
+	r, cfn, req := newTTLStack(time.Hour, 3*time.Hour)
+
+	result, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter <= 119*time.Minute || result.RequeueAfter > 2*time.Hour {
+		t.Errorf("expected a requeue once the TTL expires, got %v", result.RequeueAfter)
+	}
+	instance := &v1alpha1.Stack{}
+	if err := r.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.DeletionTimestamp != nil || len(cfn.deleteInputs) != 0 {
+		t.Error("expected the stack kept until the TTL expires")
+	}
+}
+
+func TestTTLExpiredDeletesStack(t *testing.T) {
+	r, cfn, req := newTTLStack(3*time.Hour, time.Hour)
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	instance := &v1alpha1.Stack{}
+	if err := r.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.DeletionTimestamp == nil {
+		t.Fatal("expected the expired Stack deleted")
+	}
+	if len(cfn.updateInputs) != 0 {
+		t.Errorf("expected the expired stack not updated, got %d updates", len(cfn.updateInputs))
+	}
+
+	// The finalizer then deletes the CloudFormation stack
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.deleteInputs) != 1 {
+		t.Errorf("expected the stack deleted, got %d deletes", len(cfn.deleteInputs))
+	}
+}
+
+func TestNoTTLLeavesStack(t *testing.T) {
+	r, cfn, req := newTTLStack(365*24*time.Hour, 0)
+
+	result, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != 0 {
+		t.Errorf("expected no requeue without a TTL, got %v", result.RequeueAfter)
+	}
+	instance := &v1alpha1.Stack{}
+	if err := r.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.DeletionTimestamp != nil || len(cfn.deleteInputs) != 0 {
+		t.Error("expected a stack without a TTL never deleted")
+	}
+}
+
+func TestFollowerRecordsScheduledDeletionTime(t *testing.T) {
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", TTL: &metav1.Duration{Duration: 72 * time.Hour}},
+		Status:     v1alpha1.StackStatus{StackID: testStackID, StackStatus: "CREATE_IN_PROGRESS"},
+	}
+	cfn := newFakeCloudFormation()
+	cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusCreateComplete).CreationTime = aws.Time(created)
+	follower := newTestFollower(newFakeClient(instance), cfn)
+
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	scheduled := instance.Status.ScheduledDeletionTime
+	if scheduled == nil || !scheduled.Time.Equal(created.Add(72*time.Hour)) {
+		t.Fatalf("expected the deletion scheduled 72h after creation, got %v", scheduled)
+	}
+
+	// Without a TTL, nothing is scheduled
+	instance.Spec.TTL = nil
+	if err := follower.updateStackStatus(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.ScheduledDeletionTime != nil {
+		t.Errorf("expected no deletion scheduled without a TTL, got %v", instance.Status.ScheduledDeletionTime)
+	}
+}
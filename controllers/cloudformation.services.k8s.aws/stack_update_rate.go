@@ -0,0 +1,67 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// updateRateLimited defers the update of a stack last created or updated less than its minUpdateInterval ago,
+// recording the UpdateRateLimited condition, and provides the time left until the update is due.
+func (r *StackReconciler) updateRateLimited(loop *StackLoop) (time.Duration, error) {
+	var after time.Duration
+	if interval := loop.instance.Spec.MinUpdateInterval; interval != nil && loop.stack != nil {
+		last := loop.stack.LastUpdatedTime
+		if last == nil {
+			last = loop.stack.CreationTime
+		}
+		if last != nil {
+			after = time.Until(last.Add(interval.Duration))
+		}
+	}
+
+	var changed bool
+	if after <= 0 {
+		after = 0
+		changed = removeCondition(loop.instance, v1alpha1.ConditionUpdateRateLimited)
+	} else {
+		due := time.Now().Add(after).UTC().Format(time.RFC3339)
+		loop.Log.Info("Stack updated too recently, deferring the update", "due", due)
+		changed = setCondition(loop.instance, v1alpha1.ConditionUpdateRateLimited, metav1.ConditionTrue,
+			"MinUpdateInterval", fmt.Sprintf("The stack was updated less than %s ago, the update is deferred until %s",
+				loop.instance.Spec.MinUpdateInterval.Duration, due))
+	}
+
+	if changed {
+		if err := r.updateStatus(loop); err != nil {
+			return after, err
+		}
+	}
+	return after, nil
+}
@@ -0,0 +1,93 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestMinUpdateIntervalDefersUpdates(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate,
+			MinUpdateInterval: &metav1.Duration{Duration: time.Hour}},
+		Status: v1alpha1.StackStatus{StackID: testStackID, StackStatus: "UPDATE_COMPLETE"},
+	}
+	k8sClient := newFakeClient(instance)
+	cfn := newFakeCloudFormation()
+	addStack := func(updated time.Time) {
+		stack := cfn.addStack("my-bucket", testStackID, cfTypes.StackStatusUpdateComplete)
+		stack.Tags = []cfTypes.Tag{{Key: aws.String(controllerKey), Value: aws.String(controllerValue)}}
+		stack.LastUpdatedTime = aws.Time(updated)
+	}
+	addStack(time.Now().Add(-10 * time.Minute))
+	cfn.templates[testStackID] = testTemplate
+	r := newTestReconciler(k8sClient, cfn)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-bucket", Namespace: "default"}}
+
+	result, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 0 {
+		t.Fatalf("expected the update deferred, got %d updates", len(cfn.updateInputs))
+	}
+	if result.RequeueAfter <= 49*time.Minute || result.RequeueAfter > 50*time.Minute {
+		t.Errorf("expected a requeue once the interval passed, got %v", result.RequeueAfter)
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionUpdateRateLimited)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != "MinUpdateInterval" {
+		t.Fatalf("expected the UpdateRateLimited condition, got %v", instance.Status.Conditions)
+	}
+
+	// The interval passed, the update goes ahead
+	addStack(time.Now().Add(-2 * time.Hour))
+	r.CloudFormationHelper.InvalidateStack(testStackID)
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.updateInputs) != 1 {
+		t.Fatalf("expected the stack updated, got %d updates", len(cfn.updateInputs))
+	}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	if meta.FindStatusCondition(instance.Status.Conditions, v1alpha1.ConditionUpdateRateLimited) != nil {
+		t.Errorf("expected the UpdateRateLimited condition cleared, got %v", instance.Status.Conditions)
+	}
+}
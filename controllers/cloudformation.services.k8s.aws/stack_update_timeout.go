@@ -0,0 +1,78 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cancelTimedOutUpdate cancels an update of the stack running for longer than its updateTimeout, rolling it back and
+// recording the UpdateTimedOut condition until an update completes. Returns true when the conditions changed.
+func (f *StackFollower) cancelTimedOutUpdate(ctx context.Context, log logr.Logger, instance *v1alpha1.Stack,
+	cfs *cfTypes.Stack) bool {
+	switch cfs.StackStatus {
+	case cfTypes.StackStatusUpdateComplete:
+		f.cancelledAt.Delete(instance.Status.StackID)
+		return removeCondition(instance, v1alpha1.ConditionUpdateTimedOut)
+	case cfTypes.StackStatusUpdateInProgress:
+	default:
+		return false
+	}
+
+	if instance.Spec.UpdateTimeout == nil || cfs.LastUpdatedTime == nil {
+		return false
+	}
+	started := *cfs.LastUpdatedTime
+	if time.Since(started) < instance.Spec.UpdateTimeout.Duration {
+		return false
+	}
+	// Cancelling each update once, the rollback takes a moment to show
+	if cancelled, ok := f.cancelledAt.Load(*cfs.StackId); ok && cancelled.(time.Time).Equal(started) {
+		return false
+	}
+
+	log.Info("Cancelling update running past its timeout", "started", started,
+		"timeout", instance.Spec.UpdateTimeout.Duration)
+	callCtx, cancel := f.CloudFormationHelper.callContextFor(ctx, instance)
+	defer cancel()
+	_, err := f.CloudFormationHelper.CloudFormationFor(instance).CancelUpdateStack(callCtx,
+		&cloudformation.CancelUpdateStackInput{StackName: cfs.StackId})
+	if err = callError(ctx, callCtx, err); err != nil {
+		log.Error(err, "Failed to cancel the update")
+		return false
+	}
+	f.cancelledAt.Store(*cfs.StackId, started)
+	return setCondition(instance, v1alpha1.ConditionUpdateTimedOut, metav1.ConditionTrue, "UpdateCancelled",
+		fmt.Sprintf("Update started at %s ran past the timeout of %s and was cancelled",
+			started.UTC().Format(time.RFC3339), instance.Spec.UpdateTimeout.Duration))
+}
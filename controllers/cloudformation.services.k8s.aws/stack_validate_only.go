@@ -0,0 +1,98 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validateOnly audits the Stack without reconciling it: its spec is validated as the webhook does, its inline
+// template read as JSON or YAML and, with ValidateTemplates, its template validated by CloudFormation, the results
+// recorded in the InvalidSpec and InvalidTemplate conditions.
+func (r *StackReconciler) validateOnly(loop *StackLoop) error {
+	if invalid, err := r.invalidSpec(loop); err != nil || invalid {
+		return err
+	}
+	return r.invalidTemplate(loop)
+}
+
+// invalidTemplate checks the inline template of the Stack reads as JSON or YAML and, with ValidateTemplates, has
+// CloudFormation validate the inline template or template URL, recording the InvalidTemplate condition when rejected.
+func (r *StackReconciler) invalidTemplate(loop *StackLoop) error {
+	if loop.instance.Spec.Template != "" {
+		var parsed map[string]interface{}
+		if _, err := v1alpha1.ParseTemplate(loop.instance.Spec.Template, &parsed); err != nil {
+			return r.recordInvalidTemplate(loop, "MalformedTemplate", err.Error())
+		}
+	}
+	if !r.ValidateTemplates {
+		if removeCondition(loop.instance, v1alpha1.ConditionInvalidTemplate) {
+			return r.updateStatus(loop)
+		}
+		return nil
+	}
+
+	input := &cloudformation.ValidateTemplateInput{}
+	switch {
+	case loop.instance.Spec.Template != "":
+		input.TemplateBody = aws.String(loop.instance.Spec.Template)
+	case loop.instance.Spec.TemplateUrl != "":
+		input.TemplateURL = aws.String(loop.instance.Spec.TemplateUrl)
+	default:
+		// Nothing to validate, e.g. the template of a Template resource
+		return nil
+	}
+
+	callCtx, cancel := r.CloudFormationHelper.callContextFor(loop.ctx, loop.instance)
+	_, err := r.CloudFormationHelper.CloudFormationFor(loop.instance).ValidateTemplate(callCtx, input)
+	err = callError(loop.ctx, callCtx, err)
+	cancel()
+	if err == nil {
+		if removeCondition(loop.instance, v1alpha1.ConditionInvalidTemplate) {
+			return r.updateStatus(loop)
+		}
+		return nil
+	}
+	if !IsPermanentError(err) {
+		loop.Log.Error(err, "Failed to validate the template")
+		return err
+	}
+
+	return r.recordInvalidTemplate(loop, errorCode(err), err.Error())
+}
+
+// recordInvalidTemplate records the InvalidTemplate condition.
+func (r *StackReconciler) recordInvalidTemplate(loop *StackLoop, reason string, message string) error {
+	loop.Log.Info("Invalid template", "error", message)
+	if setCondition(loop.instance, v1alpha1.ConditionInvalidTemplate, metav1.ConditionTrue, reason, message) {
+		r.Recorder.Event(loop.instance, v1.EventTypeWarning, v1alpha1.ConditionInvalidTemplate, message)
+		return r.updateStatus(loop)
+	}
+	return nil
+}
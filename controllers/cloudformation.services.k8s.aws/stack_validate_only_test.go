@@ -0,0 +1,148 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestValidateOnlyReportsBrokenStacks(t *testing.T) {
+	valid := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: testTemplate},
+	}
+	invalidSpec := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-queue", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-queue"},
+	}
+	k8sClient := newFakeClient(valid, invalidSpec)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	r.ValidateOnly = true
+	r.ValidateTemplates = true
+
+	reconcile := func(name string) *v1alpha1.Stack {
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "default"}}
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatal(err)
+		}
+		stack := &v1alpha1.Stack{}
+		if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+			t.Fatal(err)
+		}
+		return stack
+	}
+
+	stack := reconcile("my-bucket")
+	if len(stack.Status.Conditions) != 0 {
+		t.Errorf("expected nothing reported for a valid Stack, got %v", stack.Status.Conditions)
+	}
+	if controllerutil.ContainsFinalizer(stack, stacksFinalizer) {
+		t.Error("expected the Stack left untouched")
+	}
+	if len(cfn.validateInputs) != 1 || cfn.validateInputs[0].TemplateBody == nil {
+		t.Fatalf("expected the template validated, got %v", cfn.validateInputs)
+	}
+
+	stack = reconcile("my-queue")
+	if !meta.IsStatusConditionTrue(stack.Status.Conditions, v1alpha1.ConditionInvalidSpec) {
+		t.Errorf("expected the InvalidSpec condition, got %v", stack.Status.Conditions)
+	}
+
+	cfn.validateErr = &smithy.GenericAPIError{Code: "ValidationError", Message: "Template format error"}
+	stack = reconcile("my-bucket")
+	condition := meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionInvalidTemplate)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != "ValidationError" {
+		t.Errorf("expected the InvalidTemplate condition, got %v", stack.Status.Conditions)
+	}
+
+	cfn.validateErr = nil
+	stack = reconcile("my-bucket")
+	if meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionInvalidTemplate) != nil {
+		t.Errorf("expected the InvalidTemplate condition cleared once fixed, got %v", stack.Status.Conditions)
+	}
+
+	if len(cfn.createInputs)+len(cfn.updateInputs)+len(cfn.deleteInputs) != 0 {
+		t.Error("expected no stack created, updated or deleted")
+	}
+}
+
+func TestValidateOnlyReadsJSONAndYAMLTemplates(t *testing.T) {
+	yamlStack := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec: v1alpha1.StackSpec{StackName: "my-bucket",
+			Template: "Resources:\n  Bucket:\n    Type: AWS::S3::Bucket\n    Properties:\n" +
+				"      BucketName: !Sub '${AWS::StackName}-bucket'\n"},
+	}
+	jsonStack := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-queue", Namespace: "default"},
+		Spec: v1alpha1.StackSpec{StackName: "my-queue",
+			Template: "{\n\t\"Resources\": {\n\t\t\"Queue\": {\"Type\": \"AWS::SQS::Queue\"}\n\t}\n}\n"},
+	}
+	malformed := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-topic", Namespace: "default"},
+		Spec: v1alpha1.StackSpec{StackName: "my-topic",
+			Template: `{"Resources": {"Topic": {"Type": "AWS::SNS::Topic"}}`},
+	}
+	k8sClient := newFakeClient(yamlStack, jsonStack, malformed)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	r.ValidateOnly = true
+
+	for _, name := range []string{"my-bucket", "my-queue", "my-topic"} {
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "default"}}
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatal(err)
+		}
+		stack := &v1alpha1.Stack{}
+		if err := k8sClient.Get(context.TODO(), req.NamespacedName, stack); err != nil {
+			t.Fatal(err)
+		}
+		condition := meta.FindStatusCondition(stack.Status.Conditions, v1alpha1.ConditionInvalidTemplate)
+		if name != "my-topic" {
+			if condition != nil {
+				t.Errorf("expected the template of %s read, got %v", name, condition)
+			}
+			continue
+		}
+		if condition == nil || condition.Reason != "MalformedTemplate" ||
+			!strings.HasPrefix(condition.Message, "template is not valid JSON") {
+			t.Errorf("expected the malformed JSON template reported, got %v", stack.Status.Conditions)
+		}
+	}
+	if len(cfn.validateInputs) != 0 {
+		t.Errorf("expected no template validated by CloudFormation, got %d", len(cfn.validateInputs))
+	}
+}
@@ -0,0 +1,100 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-logr/logr"
+	"net/http"
+	"time"
+)
+
+const (
+	// Time allowed for the webhook to accept each notification
+	statusNotifyTimeout = 10 * time.Second
+)
+
+// StatusNotification is the payload posted to the status webhook on each stack status transition
+type StatusNotification struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	StackName string `json:"stackName"`
+	StackID   string `json:"stackID"`
+	OldStatus string `json:"oldStatus"`
+	NewStatus string `json:"newStatus"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// StatusNotifier posts stack status transitions to an external webhook (e.g. Slack or incident tooling).
+type StatusNotifier struct {
+	Log    logr.Logger
+	URL    string
+	Client *http.Client
+}
+
+// Notify posts the notification in the background so the follower is not held up by the webhook.
+func (n *StatusNotifier) Notify(notification *StatusNotification) {
+	go func() {
+		if err := n.post(notification); err != nil {
+			n.Log.Error(err, "Failed to notify status webhook", "Namespace", notification.Namespace, "Name",
+				notification.Name, "status", notification.NewStatus)
+		}
+	}()
+}
+
+func (n *StatusNotifier) post(notification *StatusNotification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), statusNotifyTimeout)
+	defer cancel()
+	return postJSON(ctx, n.Client, n.URL, notification)
+}
+
+// postJSON posts the payload as JSON to the URL, with the default HTTP client when none is given.
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded %s", url, response.Status)
+	}
+	return nil
+}
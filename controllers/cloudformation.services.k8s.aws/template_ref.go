@@ -0,0 +1,111 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"strings"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Index of Stacks by the Template they reference via templateRef
+const templateRefIndex = "spec.templateRef"
+
+// templateRefIndexer lists the name of the Template referenced by a Stack.
+func templateRefIndexer(obj client.Object) []string {
+	stack := obj.(*v1alpha1.Stack)
+	if stack.Spec.TemplateRef == nil {
+		return nil
+	}
+	return []string{stack.Spec.TemplateRef.Name}
+}
+
+// +kubebuilder:rbac:groups=cloudformation.services.k8s.aws.cuppett.dev,resources=templates,verbs=get;list;watch
+
+// resolveTemplateRef reads the body of the Template referenced by the Stack into its spec (only in memory, the
+// resource keeps the reference), recording the WaitingOnTemplate condition while the Template is missing or the Stack
+// lacks parameters the Template requires.
+func (r *StackReconciler) resolveTemplateRef(loop *StackLoop) (bool, error) {
+	ref := loop.instance.Spec.TemplateRef
+	if ref == nil {
+		return true, nil
+	}
+
+	var reason, message string
+	template := &v1alpha1.Template{}
+	err := r.Get(loop.ctx, types.NamespacedName{Namespace: loop.instance.Namespace, Name: ref.Name}, template)
+	if err != nil && !errors.IsNotFound(err) {
+		loop.Log.Error(err, "Failed to get the referenced Template", "template", ref.Name)
+		return false, err
+	}
+	if err != nil {
+		reason, message = "TemplateNotFound", "Waiting on Template "+ref.Name
+	} else if missing := missingTemplateParameters(loop.instance, template); len(missing) > 0 {
+		reason = "MissingParameters"
+		message = "Template " + ref.Name + " requires parameters: " + strings.Join(missing, ", ")
+	}
+
+	if reason != "" {
+		loop.Log.Info("Template reference not resolved", "reason", reason, "template", ref.Name)
+		if setCondition(loop.instance, v1alpha1.ConditionWaitingOnTemplate, metav1.ConditionTrue, reason, message) {
+			return false, r.updateStatus(loop)
+		}
+		return false, nil
+	}
+	if removeCondition(loop.instance, v1alpha1.ConditionWaitingOnTemplate) {
+		if err := r.updateStatus(loop); err != nil {
+			return false, err
+		}
+	}
+
+	loop.instance.Spec.Template = template.Spec.Template
+	return true, nil
+}
+
+// missingTemplateParameters lists the parameters required by the Template the Stack does not set.
+func missingTemplateParameters(instance *v1alpha1.Stack, template *v1alpha1.Template) []string {
+	given := map[string]bool{}
+	for name := range instance.Spec.Parameters {
+		given[name] = true
+	}
+	for name := range instance.Spec.ListParameters {
+		given[name] = true
+	}
+	for _, source := range instance.Spec.ParametersFrom {
+		given[source.Name] = true
+	}
+
+	var missing []string
+	for _, parameter := range template.Spec.Parameters {
+		if parameter.Required && !given[parameter.Name] {
+			missing = append(missing, parameter.Name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,146 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestTemplateRefResolved(t *testing.T) {
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{
+			StackName:   "my-bucket",
+			TemplateRef: &v1alpha1.TemplateReference{Name: "bucket"},
+		},
+	}
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(instance).
+		WithStatusSubresource(&v1alpha1.Stack{}).
+		WithIndex(&v1alpha1.Stack{}, templateRefIndex, templateRefIndexer).
+		Build()
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	name := types.NamespacedName{Name: "my-bucket", Namespace: "default"}
+
+	// Waiting while the Template does not exist
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 0 {
+		t.Fatal("expected no stack created before the Template exists")
+	}
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionWaitingOnTemplate)
+	if condition == nil || condition.Reason != "TemplateNotFound" {
+		t.Fatalf("expected to wait on the Template, got %v", updated.Status.Conditions)
+	}
+
+	// Creating the Template enqueues the Stack referencing it
+	template := &v1alpha1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket", Namespace: "default"},
+		Spec:       v1alpha1.TemplateSpec{Template: testTemplate},
+	}
+	if err := k8sClient.Create(context.TODO(), template); err != nil {
+		t.Fatal(err)
+	}
+	requests := r.stacksReferencing(templateRefIndex)(context.TODO(), template)
+	if len(requests) != 1 || requests[0].NamespacedName != name {
+		t.Fatalf("expected the referencing stack to be enqueued, got %v", requests)
+	}
+	if _, err := r.Reconcile(context.TODO(), requests[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 1 || aws.ToString(cfn.createInputs[0].TemplateBody) != testTemplate {
+		t.Fatalf("expected the stack created from the Template body, got %d creates", len(cfn.createInputs))
+	}
+
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Spec.Template != "" || updated.Spec.TemplateRef == nil {
+		t.Errorf("expected the Stack to keep only the reference, got %v", updated.Spec)
+	}
+	if meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionWaitingOnTemplate) != nil {
+		t.Errorf("expected the WaitingOnTemplate condition removed, got %v", updated.Status.Conditions)
+	}
+}
+
+func TestTemplateRefMissingParameters(t *testing.T) {
+	template := &v1alpha1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket", Namespace: "default"},
+		Spec: v1alpha1.TemplateSpec{
+			Template: testTemplate,
+			Parameters: []v1alpha1.TemplateParameter{
+				{Name: "BucketName", Required: true},
+				{Name: "Versioning", Required: true},
+				{Name: "Tier"},
+			},
+		},
+	}
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default", Finalizers: []string{stacksFinalizer}},
+		Spec: v1alpha1.StackSpec{
+			StackName:   "my-bucket",
+			TemplateRef: &v1alpha1.TemplateReference{Name: "bucket"},
+			Parameters:  map[string]string{"BucketName": "my-team-bucket"},
+		},
+	}
+	k8sClient := newFakeClient(template, instance)
+	cfn := newFakeCloudFormation()
+	r := newTestReconciler(k8sClient, cfn)
+	name := types.NamespacedName{Name: "my-bucket", Namespace: "default"}
+
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfn.createInputs) != 0 {
+		t.Fatal("expected no stack created while required parameters are missing")
+	}
+	updated := &v1alpha1.Stack{}
+	if err := k8sClient.Get(context.TODO(), name, updated); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionWaitingOnTemplate)
+	if condition == nil || condition.Reason != "MissingParameters" {
+		t.Fatalf("expected the MissingParameters reason, got %v", updated.Status.Conditions)
+	}
+	if condition.Message != "Template bucket requires parameters: Versioning" {
+		t.Errorf("unexpected message %q", condition.Message)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"crypto/sha256"
+	coreerrors "errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+)
+
+// CloudFormation accepts inline template bodies up to 51,200 bytes
+const maxTemplateBodySize = 51200
+
+var (
+	ErrInvalidTemplateSSE  = coreerrors.New("template upload server-side encryption must be AES256 or aws:kms")
+	ErrKMSKeyWithoutSSEKMS = coreerrors.New("a template upload KMS key requires aws:kms server-side encryption")
+)
+
+// TemplateUploader stages inline templates too large to submit directly in an S3 bucket, submitting them to
+// CloudFormation by URL instead.
+type TemplateUploader struct {
+	CloudFormationHelper *CloudFormationHelper
+	// Bucket the templates are uploaded to
+	Bucket string
+	// Server-side encryption of the uploaded templates, AES256 (SSE-S3) or aws:kms (SSE-KMS), the bucket default when
+	// empty
+	ServerSideEncryption s3Types.ServerSideEncryption
+	// KMS key (ID or ARN) used with aws:kms, the AWS managed key when empty
+	KMSKeyID string
+}
+
+// Validate ensures the server-side encryption settings are usable.
+func (u *TemplateUploader) Validate() error {
+	switch u.ServerSideEncryption {
+	case "", s3Types.ServerSideEncryptionAes256, s3Types.ServerSideEncryptionAwsKms:
+	default:
+		return ErrInvalidTemplateSSE
+	}
+	if u.KMSKeyID != "" && u.ServerSideEncryption != s3Types.ServerSideEncryptionAwsKms {
+		return ErrKMSKeyWithoutSSEKMS
+	}
+	return nil
+}
+
+// NeedsUpload identifies templates too large to be submitted inline.
+func (u *TemplateUploader) NeedsUpload(template string) bool {
+	return len(template) > maxTemplateBodySize
+}
+
+// Upload puts the template in the bucket, keyed by the Stack resource and the template's digest, returning its URL.
+func (u *TemplateUploader) Upload(ctx context.Context, instance *v1alpha1.Stack, template string) (string, error) {
+	key := fmt.Sprintf("%s/%s/%x.template", instance.Namespace, instance.Name, sha256.Sum256([]byte(template)))
+	input := &s3.PutObjectInput{
+		Bucket:               aws.String(u.Bucket),
+		Key:                  aws.String(key),
+		Body:                 strings.NewReader(template),
+		ServerSideEncryption: u.ServerSideEncryption,
+	}
+	if u.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(u.KMSKeyID)
+	}
+	if _, err := u.CloudFormationHelper.GetS3().PutObject(ctx, input); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", u.Bucket, key), nil
+}
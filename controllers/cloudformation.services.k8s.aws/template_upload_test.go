@@ -0,0 +1,93 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLargeTemplateUploadedEncrypted(t *testing.T) {
+	template := testTemplate + strings.Repeat("# padding\n", maxTemplateBodySize/10)
+	instance := &v1alpha1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bucket", Namespace: "default"},
+		Spec:       v1alpha1.StackSpec{StackName: "my-bucket", Template: template},
+	}
+	cfn := newFakeCloudFormation()
+	s3Client := &fakeS3{}
+	r := newTestReconciler(newFakeClient(instance), cfn)
+	r.CloudFormationHelper.S3 = s3Client
+	r.TemplateUploader = &TemplateUploader{
+		CloudFormationHelper: r.CloudFormationHelper,
+		Bucket:               "templates",
+		ServerSideEncryption: s3Types.ServerSideEncryptionAwsKms,
+		KMSKeyID:             "arn:aws:kms:us-east-1:123456789012:key/1234abcd",
+	}
+	loop := &StackLoop{ctx: context.TODO(), instance: instance, Log: logr.Discard()}
+	if err := r.createStack(loop); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s3Client.puts) != 1 {
+		t.Fatalf("expected the template to be uploaded once, got %d", len(s3Client.puts))
+	}
+	put := s3Client.puts[0]
+	if put.ServerSideEncryption != s3Types.ServerSideEncryptionAwsKms ||
+		aws.ToString(put.SSEKMSKeyId) != r.TemplateUploader.KMSKeyID {
+		t.Errorf("expected SSE-KMS with the configured key, got %s/%s", put.ServerSideEncryption,
+			aws.ToString(put.SSEKMSKeyId))
+	}
+	input := cfn.createInputs[0]
+	if input.TemplateBody != nil || !strings.HasPrefix(aws.ToString(input.TemplateURL), "https://templates.s3.amazonaws.com/default/my-bucket/") {
+		t.Errorf("expected the uploaded template URL to be submitted, got %s", aws.ToString(input.TemplateURL))
+	}
+}
+
+func TestTemplateUploaderValidate(t *testing.T) {
+	tests := []struct {
+		sse   s3Types.ServerSideEncryption
+		key   string
+		valid bool
+	}{
+		{"", "", true},
+		{s3Types.ServerSideEncryptionAes256, "", true},
+		{s3Types.ServerSideEncryptionAwsKms, "alias/templates", true},
+		{"aws:kms:dsse", "", false},
+		{s3Types.ServerSideEncryptionAes256, "alias/templates", false},
+	}
+	for _, test := range tests {
+		uploader := &TemplateUploader{Bucket: "templates", ServerSideEncryption: test.sse, KMSKeyID: test.key}
+		if err := uploader.Validate(); (err == nil) != test.valid {
+			t.Errorf("%s/%s: expected valid %v, got %v", test.sse, test.key, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	coreerrors "errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Delay between checks of the object behind a tracked template URL
+const templateUrlRecheckInterval = 5 * time.Minute
+
+var (
+	ErrNotS3TemplateUrl = coreerrors.New("tracked template URLs must point to an S3 object")
+	// Virtual-hosted (bucket.s3.region.amazonaws.com) and path-style (s3.region.amazonaws.com/bucket) S3 hosts
+	s3VirtualHostExpression = regexp.MustCompile(`^(.+?)\.s3([.-][a-z0-9.-]+)?\.amazonaws\.com(\.cn)?$`)
+	s3PathHostExpression    = regexp.MustCompile(`^s3([.-][a-z0-9.-]+)?\.amazonaws\.com(\.cn)?$`)
+)
+
+// parseS3URL splits an S3 object URL into its bucket and key.
+func parseS3URL(templateURL string) (string, string, error) {
+	parsed, err := url.Parse(templateURL)
+	if err != nil {
+		return "", "", err
+	}
+	path := strings.TrimPrefix(parsed.Path, "/")
+	if match := s3VirtualHostExpression.FindStringSubmatch(parsed.Host); match != nil && path != "" {
+		return match[1], path, nil
+	}
+	if s3PathHostExpression.MatchString(parsed.Host) {
+		if bucket, key, found := strings.Cut(path, "/"); found && bucket != "" && key != "" {
+			return bucket, key, nil
+		}
+	}
+	return "", "", ErrNotS3TemplateUrl
+}
+
+// templateUrlETag reads the entity tag of the S3 object behind the template URL, which changes with its content.
+func (r *StackReconciler) templateUrlETag(loop *StackLoop) (string, error) {
+	bucket, key, err := parseS3URL(loop.instance.Spec.TemplateUrl)
+	if err != nil {
+		loop.Log.Error(err, "Unable to track the template URL", "templateUrl", loop.instance.Spec.TemplateUrl)
+		return "", err
+	}
+
+	input := &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	if loop.instance.Spec.TemplateVersionId != "" {
+		input.VersionId = aws.String(loop.instance.Spec.TemplateVersionId)
+	}
+	output, err := r.CloudFormationHelper.GetS3().HeadObject(loop.ctx, input)
+	if err != nil {
+		loop.Log.Error(err, "Failed to check the template behind the URL", "bucket", bucket, "key", key)
+		return "", err
+	}
+	return aws.ToString(output.ETag), nil
+}
@@ -0,0 +1,75 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformation_services_k8s_aws
+
+import (
+	"testing"
+
+	"github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
+)
+
+func TestParseS3URL(t *testing.T) {
+	for templateURL, expected := range map[string][2]string{
+		"https://my-bucket.s3.amazonaws.com/stacks/app.yaml":            {"my-bucket", "stacks/app.yaml"},
+		"https://my.dotted.bucket.s3.eu-west-1.amazonaws.com/app.yaml":  {"my.dotted.bucket", "app.yaml"},
+		"https://my-bucket.s3-us-west-2.amazonaws.com/app.yaml":         {"my-bucket", "app.yaml"},
+		"https://s3.us-east-1.amazonaws.com/my-bucket/stacks/app.yaml":  {"my-bucket", "stacks/app.yaml"},
+		"https://my-bucket.s3.cn-north-1.amazonaws.com.cn/app.yaml?x=y": {"my-bucket", "app.yaml"},
+	} {
+		bucket, key, err := parseS3URL(templateURL)
+		if err != nil || bucket != expected[0] || key != expected[1] {
+			t.Errorf("expected %s split into %v, got %s %s (%v)", templateURL, expected, bucket, key, err)
+		}
+	}
+
+	for _, templateURL := range []string{"https://example.com/app.yaml", "https://s3.amazonaws.com/my-bucket"} {
+		if _, _, err := parseS3URL(templateURL); err != ErrNotS3TemplateUrl {
+			t.Errorf("expected %s refused, got %v", templateURL, err)
+		}
+	}
+}
+
+func TestTrackedTemplateUrlChangesHash(t *testing.T) {
+	r, _, loop := newUpdateLoop(t, v1alpha1.StackSpec{
+		StackName:        "my-bucket",
+		TemplateUrl:      "https://my-templates.s3.amazonaws.com/stacks/app.yaml",
+		TrackTemplateUrl: true,
+	})
+	objects := &fakeS3{etags: map[string]string{"my-templates/stacks/app.yaml": `"9b2cf535f27731c974343645a3985328"`}}
+	r.CloudFormationHelper.S3 = objects
+
+	hash, err := r.appliedTemplateHash(loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchanged, _ := r.appliedTemplateHash(loop); unchanged != hash {
+		t.Error("expected the hash stable while the object is unchanged")
+	}
+
+	objects.etags["my-templates/stacks/app.yaml"] = `"6f5902ac237024bdd0c176cb93063dc4"`
+	if changed, _ := r.appliedTemplateHash(loop); changed == hash {
+		t.Error("expected new content behind the URL to change the hash")
+	}
+}
@@ -27,12 +27,18 @@ package servicesk8saws
 import (
 	"context"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	servicesv1alpha1 "github.com/cuppett/aws-cloudformation-operator/apis/services.k8s.aws/v1alpha1"
 	"github.com/go-logr/logr"
 	configv1 "github.com/openshift/api/config/v1"
+	"github.com/prometheus/client_golang/prometheus"
 	v12 "k8s.io/api/core/v1"
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -42,6 +48,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sync"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -52,26 +59,60 @@ import (
 const (
 	configName     string = "default"
 	credSecretName string = "aws-cloud-credentials"
+	imdsTimeout           = 5 * time.Second
+	// Bound on the CloudFormation clients tuned for particular stacks kept at once
+	maxTunedClients = 16
 )
 
 var (
 	podNamespace string = os.Getenv("POD_NAMESPACE")
 )
 
+// AWSClientOptions tunes the AWS clients created by the ConfigReconciler
+type AWSClientOptions struct {
+	// Retry mode (standard or adaptive), the SDK default when empty
+	RetryMode aws.RetryMode
+	// Maximum attempts per request, the SDK default when zero
+	RetryMaxAttempts int
+	// Resolve the FIPS endpoints of every service
+	UseFIPSEndpoint bool
+	// Optional counter of the credentials refreshed after requests failed on expired credentials
+	CredentialsRefreshes prometheus.Counter
+}
+
 // ConfigReconciler reconciles a Config object
 type ConfigReconciler struct {
 	client         client.Client
 	log            logr.Logger
 	scheme         *runtime.Scheme
+	clientOptions  AWSClientOptions
 	cloudFormation *cloudformation.Client
-	cfLock         sync.Mutex
+	// Prebuilt clients for stacks requesting a specific retry mode
+	cloudFormationByMode map[aws.RetryMode]*cloudformation.Client
+	cloudWatch           *cloudwatch.Client
+	sqs                  *sqs.Client
+	s3                   *s3.Client
+	region               string // Region the clients were created for
+	cfLock               sync.Mutex
+
+	// Clients built on demand for stacks tuning their maximum attempts, from the config loaded
+	cloudFormationByOptions map[cloudFormationOptions]*cloudformation.Client
+	awsConfig               *aws.Config
+}
+
+// cloudFormationOptions identifies the tuning of a CloudFormation client
+type cloudFormationOptions struct {
+	mode        aws.RetryMode
+	maxAttempts int
 }
 
-func InitializeConfigReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme) *ConfigReconciler {
+func InitializeConfigReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme,
+	clientOptions AWSClientOptions) *ConfigReconciler {
 	reconciler := &ConfigReconciler{
 		client:         client,
 		log:            log,
 		scheme:         scheme,
+		clientOptions:  clientOptions,
 		cloudFormation: nil,
 	}
 	return reconciler
@@ -109,7 +150,7 @@ func (r *ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	}
 
 	r.cfLock.Lock()
-	r.createCloudFormation(loop)
+	r.createClients(loop)
 	r.cfLock.Unlock()
 
 	return ctrl.Result{}, nil
@@ -158,27 +199,149 @@ func (r *ConfigReconciler) GetTags(ctx context.Context) map[string]string {
 }
 
 func (r *ConfigReconciler) GetCloudFormation() *cloudformation.Client {
+	r.ensureClients()
+	return r.cloudFormation
+}
+
+// GetCloudFormationFor provides the CloudFormation client using the retry mode requested, the default client
+// otherwise.
+func (r *ConfigReconciler) GetCloudFormationFor(mode aws.RetryMode) *cloudformation.Client {
+	r.ensureClients()
+	if client, ok := r.cloudFormationByMode[mode]; ok {
+		return client
+	}
+	return r.cloudFormation
+}
+
+// GetCloudFormationWith provides the CloudFormation client using the retry mode and maximum attempts requested,
+// built on first use and kept for the stacks sharing the same tuning. Without a maximum, the clients of
+// GetCloudFormationFor are used.
+func (r *ConfigReconciler) GetCloudFormationWith(mode aws.RetryMode, maxAttempts int) *cloudformation.Client {
+	if maxAttempts <= 0 {
+		return r.GetCloudFormationFor(mode)
+	}
+	r.ensureClients()
+	if mode == "" {
+		mode = r.clientOptions.RetryMode
+	}
+	key := cloudFormationOptions{mode: mode, maxAttempts: maxAttempts}
+
+	r.cfLock.Lock()
+	defer r.cfLock.Unlock()
+	if client, ok := r.cloudFormationByOptions[key]; ok {
+		return client
+	}
+	if len(r.cloudFormationByOptions) >= maxTunedClients || r.cloudFormationByOptions == nil {
+		// Starting over rather than growing without bound on stacks all tuned differently
+		r.cloudFormationByOptions = map[cloudFormationOptions]*cloudformation.Client{}
+	}
+	client := cloudformation.NewFromConfig(*r.awsConfig, r.withRetryer(mode, maxAttempts),
+		r.refreshCloudFormationCredentials)
+	r.cloudFormationByOptions[key] = client
+	return client
+}
+
+// GetRegion provides the region the clients were created for.
+func (r *ConfigReconciler) GetRegion() string {
+	r.ensureClients()
+	return r.region
+}
+
+func (r *ConfigReconciler) GetCloudWatch() *cloudwatch.Client {
+	r.ensureClients()
+	return r.cloudWatch
+}
+
+func (r *ConfigReconciler) GetSQS() *sqs.Client {
+	r.ensureClients()
+	return r.sqs
+}
+
+func (r *ConfigReconciler) GetS3() *s3.Client {
+	r.ensureClients()
+	return r.s3
+}
+
+func (r *ConfigReconciler) ensureClients() {
 	if r.cloudFormation == nil {
 		r.cfLock.Lock()
 		if r.cloudFormation == nil {
 			ctx := context.TODO()
 			loop := &ConfigLoop{ctx, r.getDefaultConfig(ctx),
 				log.FromContext(ctx)}
-			r.createCloudFormation(loop)
+			r.createClients(loop)
 		}
 		r.cfLock.Unlock()
 	}
-	return r.cloudFormation
 }
 
-func (r *ConfigReconciler) createCloudFormation(loop *ConfigLoop) {
+func (r *ConfigReconciler) createClients(loop *ConfigLoop) {
 	cfg := r.loadConfig(loop)
-	r.cloudFormation = cloudformation.NewFromConfig(*cfg)
+	r.region = cfg.Region
+	r.cloudWatch = cloudwatch.NewFromConfig(*cfg, func(o *cloudwatch.Options) {
+		o.Retryer = r.withCredentialsRefresh(o.Retryer, o.Credentials)
+	})
+	r.sqs = sqs.NewFromConfig(*cfg, func(o *sqs.Options) {
+		o.Retryer = r.withCredentialsRefresh(o.Retryer, o.Credentials)
+	})
+	r.s3 = s3.NewFromConfig(*cfg, func(o *s3.Options) {
+		o.Retryer = r.withCredentialsRefresh(o.Retryer, o.Credentials)
+	})
+	r.awsConfig = cfg
+	r.cloudFormation = cloudformation.NewFromConfig(*cfg, r.refreshCloudFormationCredentials)
+	maxAttempts := r.clientOptions.RetryMaxAttempts
+	r.cloudFormationByMode = map[aws.RetryMode]*cloudformation.Client{
+		aws.RetryModeStandard: cloudformation.NewFromConfig(*cfg, r.withRetryer(aws.RetryModeStandard, maxAttempts),
+			r.refreshCloudFormationCredentials),
+		aws.RetryModeAdaptive: cloudformation.NewFromConfig(*cfg, r.withRetryer(aws.RetryModeAdaptive, maxAttempts),
+			r.refreshCloudFormationCredentials),
+	}
+	// Tuned clients are built again from the new config as stacks ask for them
+	r.cloudFormationByOptions = nil
+}
+
+// refreshCloudFormationCredentials refreshes the credentials of a CloudFormation client once expired.
+func (r *ConfigReconciler) refreshCloudFormationCredentials(o *cloudformation.Options) {
+	o.Retryer = r.withCredentialsRefresh(o.Retryer, o.Credentials)
+}
+
+// withRetryer replaces the retryer of a CloudFormation client with one of the given mode and maximum attempts
+// (the SDK default when zero).
+func (r *ConfigReconciler) withRetryer(mode aws.RetryMode, maxAttempts int) func(*cloudformation.Options) {
+	standardOptions := func(o *retry.StandardOptions) {
+		if maxAttempts > 0 {
+			o.MaxAttempts = maxAttempts
+		}
+	}
+	return func(o *cloudformation.Options) {
+		if mode == aws.RetryModeAdaptive {
+			o.Retryer = retry.NewAdaptiveMode(func(ao *retry.AdaptiveModeOptions) {
+				ao.StandardOptions = append(ao.StandardOptions, standardOptions)
+			})
+		} else {
+			o.Retryer = retry.NewStandard(standardOptions)
+		}
+	}
+}
+
+// loadOptions translates the AWSClientOptions into options loading the AWS config.
+func (r *ConfigReconciler) loadOptions() []func(*config.LoadOptions) error {
+	var optFns []func(*config.LoadOptions) error
+	if r.clientOptions.RetryMode != "" {
+		optFns = append(optFns, config.WithRetryMode(r.clientOptions.RetryMode))
+	}
+	if r.clientOptions.RetryMaxAttempts > 0 {
+		optFns = append(optFns, config.WithRetryMaxAttempts(r.clientOptions.RetryMaxAttempts))
+	}
+	if r.clientOptions.UseFIPSEndpoint {
+		optFns = append(optFns, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	return optFns
 }
 
 func (r *ConfigReconciler) loadConfig(loop *ConfigLoop) *aws.Config {
 
-	cfg, err := config.LoadDefaultConfig(loop.ctx)
+	cfg, err := config.LoadDefaultConfig(loop.ctx, r.loadOptions()...)
 	if err != nil {
 		r.log.Error(err, "error getting AWS config")
 		return nil
@@ -215,7 +378,8 @@ func (r *ConfigReconciler) loadConfig(loop *ConfigLoop) *aws.Config {
 	if err != nil || output == nil {
 		r.log.Info("No AWS identity available in config.", "error", err)
 	} else {
-		r.log.Info("AWS identity found", "arn", *output.Arn)
+		r.log.Info("AWS identity found", "arn", *output.Arn, "account", aws.ToString(output.Account),
+			"region", cfg.Region)
 	}
 
 	return &cfg
@@ -253,8 +417,25 @@ func (r *ConfigReconciler) getClusterRegion(loop *ConfigLoop) string {
 		return loop.config.Spec.Region
 	}
 
-	// Lastly, if we're on OpenShift, check what the region is for the infra.
-	return r.getInfraRegion(loop.ctx)
+	// If we're on OpenShift, check what the region is for the infra.
+	if region := r.getInfraRegion(loop.ctx); region != "" {
+		return region
+	}
+
+	// Lastly, ask the instance metadata service when running on EC2/EKS.
+	return r.getInstanceRegion(loop.ctx)
+}
+
+func (r *ConfigReconciler) getInstanceRegion(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, imdsTimeout)
+	defer cancel()
+
+	output, err := imds.New(imds.Options{}).GetRegion(ctx, &imds.GetRegionInput{})
+	if err != nil {
+		r.log.Info("No region available from instance metadata", "error", err)
+		return ""
+	}
+	return output.Region
 }
 
 //+kubebuilder:rbac:groups=config.openshift.io,resources=infrastructures,verbs=get;list;watch
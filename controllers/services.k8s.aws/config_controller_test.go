@@ -0,0 +1,113 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package servicesk8saws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+func TestLoadOptionsUseFIPSEndpoint(t *testing.T) {
+	loaded := func(options AWSClientOptions) config.LoadOptions {
+		r := &ConfigReconciler{clientOptions: options}
+		loadOptions := config.LoadOptions{}
+		for _, optFn := range r.loadOptions() {
+			if err := optFn(&loadOptions); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return loadOptions
+	}
+
+	if state := loaded(AWSClientOptions{}).UseFIPSEndpoint; state != aws.FIPSEndpointStateUnset {
+		t.Errorf("expected the FIPS endpoint left to the SDK default, got %v", state)
+	}
+	if state := loaded(AWSClientOptions{UseFIPSEndpoint: true}).UseFIPSEndpoint; state != aws.FIPSEndpointStateEnabled {
+		t.Errorf("expected the FIPS endpoint enabled, got %v", state)
+	}
+}
+
+func TestCloudFormationClientsCachedByTuning(t *testing.T) {
+	cfg := aws.Config{Region: "us-east-1"}
+	r := &ConfigReconciler{awsConfig: &cfg, cloudFormation: cloudformation.NewFromConfig(cfg)}
+
+	tuned := r.GetCloudFormationWith(aws.RetryModeStandard, 10)
+	if r.GetCloudFormationWith(aws.RetryModeStandard, 10) != tuned {
+		t.Error("expected the client kept for the same tuning")
+	}
+	if r.GetCloudFormationWith(aws.RetryModeAdaptive, 10) == tuned ||
+		r.GetCloudFormationWith(aws.RetryModeStandard, 3) == tuned {
+		t.Error("expected distinct clients for other tunings")
+	}
+	if r.GetCloudFormationWith("", 0) != r.cloudFormation {
+		t.Error("expected the default client without a maximum of attempts")
+	}
+
+	// Bounded, starting over once full
+	for attempts := 1; attempts <= maxTunedClients+1; attempts++ {
+		r.GetCloudFormationWith(aws.RetryModeStandard, attempts)
+	}
+	if len(r.cloudFormationByOptions) > maxTunedClients {
+		t.Errorf("expected at most %d clients kept, got %d", maxTunedClients, len(r.cloudFormationByOptions))
+	}
+}
+
+func TestCloudFormationClientsByRetryMode(t *testing.T) {
+	cfg := aws.Config{Region: "us-east-1"}
+	r := &ConfigReconciler{awsConfig: &cfg, cloudFormation: cloudformation.NewFromConfig(cfg)}
+	r.cloudFormationByMode = map[aws.RetryMode]*cloudformation.Client{
+		aws.RetryModeStandard: cloudformation.NewFromConfig(cfg, r.withRetryer(aws.RetryModeStandard, 0)),
+		aws.RetryModeAdaptive: cloudformation.NewFromConfig(cfg, r.withRetryer(aws.RetryModeAdaptive, 0)),
+	}
+
+	if r.GetCloudFormationFor(aws.RetryModeAdaptive) != r.cloudFormationByMode[aws.RetryModeAdaptive] ||
+		r.GetCloudFormationFor(aws.RetryModeStandard) != r.cloudFormationByMode[aws.RetryModeStandard] {
+		t.Error("expected the client pre-built for the retry mode")
+	}
+	if r.GetCloudFormationFor("") != r.cloudFormation || r.GetCloudFormationFor("unknown") != r.cloudFormation {
+		t.Error("expected the default client without a known retry mode")
+	}
+}
+
+func TestWithRetryer(t *testing.T) {
+	r := &ConfigReconciler{}
+	retryer := func(mode aws.RetryMode, maxAttempts int) aws.Retryer {
+		options := cloudformation.Options{}
+		r.withRetryer(mode, maxAttempts)(&options)
+		return options.Retryer
+	}
+
+	if adaptive, ok := retryer(aws.RetryModeAdaptive, 5).(*retry.AdaptiveMode); !ok || adaptive.MaxAttempts() != 5 {
+		t.Errorf("expected adaptive rate limiting with 5 attempts, got %T", retryer(aws.RetryModeAdaptive, 5))
+	}
+	if standard, ok := retryer(aws.RetryModeStandard, 0).(*retry.Standard); !ok ||
+		standard.MaxAttempts() != retry.DefaultMaxAttempts {
+		t.Errorf("expected the standard retryer with the default attempts, got %T", retryer(aws.RetryModeStandard, 0))
+	}
+}
@@ -0,0 +1,92 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package servicesk8saws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+)
+
+// Error codes of requests signed with credentials which expired, e.g. an assumed role session outliving its duration
+var expiredCredentialsCodes = map[string]struct{}{
+	"ExpiredToken":          {},
+	"ExpiredTokenException": {},
+}
+
+// IsExpiredCredentials identifies the errors of requests signed with expired credentials.
+func IsExpiredCredentials(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	_, expired := expiredCredentialsCodes[apiErr.ErrorCode()]
+	return expired
+}
+
+// credentialsRefreshRetryer retries the requests failing on expired credentials, dropping the cached credentials so
+// the retry is signed with fresh ones.
+type credentialsRefreshRetryer struct {
+	aws.RetryerV2
+	credentials *aws.CredentialsCache
+	reconciler  *ConfigReconciler
+}
+
+func (r *credentialsRefreshRetryer) IsErrorRetryable(err error) bool {
+	if !IsExpiredCredentials(err) {
+		return r.RetryerV2.IsErrorRetryable(err)
+	}
+	r.reconciler.log.Info("AWS credentials expired, refreshing", "error", err)
+	r.credentials.Invalidate()
+	if r.reconciler.clientOptions.CredentialsRefreshes != nil {
+		r.reconciler.clientOptions.CredentialsRefreshes.Inc()
+	}
+	return true
+}
+
+// retryerV2 adapts a Retryer lacking GetAttemptToken.
+type retryerV2 struct {
+	aws.Retryer
+}
+
+func (r retryerV2) GetAttemptToken(context.Context) (func(error) error, error) {
+	return r.GetInitialToken(), nil
+}
+
+// withCredentialsRefresh wraps the retryer of a client to refresh expired credentials, when they are cached (those
+// of the credentials Secret are not, reloaded as the Secret changes instead).
+func (r *ConfigReconciler) withCredentialsRefresh(retryer aws.Retryer, credentials aws.CredentialsProvider) aws.Retryer {
+	cache, cached := credentials.(*aws.CredentialsCache)
+	if !cached || retryer == nil {
+		return retryer
+	}
+	v2, ok := retryer.(aws.RetryerV2)
+	if !ok {
+		v2 = retryerV2{retryer}
+	}
+	return &credentialsRefreshRetryer{RetryerV2: v2, credentials: cache, reconciler: r}
+}
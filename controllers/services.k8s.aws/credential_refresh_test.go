@@ -0,0 +1,79 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package servicesk8saws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCredentialsRefreshedOnExpiry(t *testing.T) {
+	retrieved := 0
+	credentials := aws.NewCredentialsCache(aws.CredentialsProviderFunc(
+		func(ctx context.Context) (aws.Credentials, error) {
+			retrieved++
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET", SessionToken: "TOKEN"}, nil
+		}))
+	r := &ConfigReconciler{log: logr.Discard(), clientOptions: AWSClientOptions{
+		CredentialsRefreshes: prometheus.NewCounter(prometheus.CounterOpts{Name: "test_refreshes"}),
+	}}
+	retryer := r.withCredentialsRefresh(retry.NewStandard(), credentials)
+	if _, err := credentials.Retrieve(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !retryer.IsErrorRetryable(&smithy.GenericAPIError{Code: "ExpiredToken", Message: "The security token included in the request is expired"}) {
+		t.Fatal("expected expired credentials retried")
+	}
+	if _, err := credentials.Retrieve(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if retrieved != 2 {
+		t.Errorf("expected the credentials retrieved again, got %d retrievals", retrieved)
+	}
+	if refreshes := testutil.ToFloat64(r.clientOptions.CredentialsRefreshes); refreshes != 1 {
+		t.Errorf("expected one refresh counted, got %v", refreshes)
+	}
+
+	// Other errors are left to the SDK retryer
+	if retryer.IsErrorRetryable(&smithy.GenericAPIError{Code: "ValidationError", Message: "Template format error"}) {
+		t.Error("expected a validation error not retried")
+	}
+
+	// Uncached credentials are left alone
+	static := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, nil
+	})
+	if _, wrapped := r.withCredentialsRefresh(retry.NewStandard(), static).(*credentialsRefreshRetryer); wrapped {
+		t.Error("expected the retryer of uncached credentials unchanged")
+	}
+}
@@ -0,0 +1,92 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package servicesk8saws
+
+import (
+	"context"
+	"time"
+
+	servicesv1alpha1 "github.com/cuppett/aws-cloudformation-operator/apis/services.k8s.aws/v1alpha1"
+)
+
+// ChangesDeferred identifies if the default Config defers stack changes at the time given and until when. The time
+// is zero while changes are deferred until further notice.
+func (r *ConfigReconciler) ChangesDeferred(ctx context.Context, now time.Time) (bool, time.Time) {
+	defaultConfig := r.getDefaultConfig(ctx)
+	if defaultConfig == nil {
+		return false, time.Time{}
+	}
+	return changesDeferred(defaultConfig.Spec, now)
+}
+
+func changesDeferred(spec servicesv1alpha1.ConfigSpec, now time.Time) (bool, time.Time) {
+	if spec.DeferStackChanges {
+		return true, time.Time{}
+	}
+
+	deferred := false
+	var until time.Time
+	for _, window := range spec.MaintenanceWindows {
+		if closes, open := windowCloses(window, now); open {
+			deferred = true
+			if closes.After(until) {
+				until = closes
+			}
+		}
+	}
+	return deferred, until
+}
+
+// windowCloses identifies if the maintenance window is open at the time given and when it closes.
+func windowCloses(window servicesv1alpha1.MaintenanceWindow, now time.Time) (time.Time, bool) {
+	start, err := time.Parse("15:04", window.Start)
+	if err != nil || window.Duration.Duration <= 0 {
+		return time.Time{}, false
+	}
+
+	now = now.UTC()
+	// Windows opened on earlier days may still be open, looking back as far as they last
+	for daysBack := 0; daysBack <= int(window.Duration.Hours()/24)+1; daysBack++ {
+		day := now.AddDate(0, 0, -daysBack)
+		opens := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, time.UTC)
+		closes := opens.Add(window.Duration.Duration)
+		if opensOn(window, opens.Weekday()) && !now.Before(opens) && now.Before(closes) {
+			return closes, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func opensOn(window servicesv1alpha1.MaintenanceWindow, weekday time.Weekday) bool {
+	if len(window.Days) == 0 {
+		return true
+	}
+	for _, day := range window.Days {
+		if string(day) == weekday.String() {
+			return true
+		}
+	}
+	return false
+}
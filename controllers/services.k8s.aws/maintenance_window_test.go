@@ -0,0 +1,71 @@
+/*
+MIT License
+
+Copyright (c) 2022 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package servicesk8saws
+
+import (
+	"testing"
+	"time"
+
+	servicesv1alpha1 "github.com/cuppett/aws-cloudformation-operator/apis/services.k8s.aws/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	// Saturday night maintenance, running past midnight
+	weekend := servicesv1alpha1.MaintenanceWindow{
+		Days:     []servicesv1alpha1.Weekday{"Saturday"},
+		Start:    "22:00",
+		Duration: metav1.Duration{Duration: 4 * time.Hour},
+	}
+	spec := servicesv1alpha1.ConfigSpec{MaintenanceWindows: []servicesv1alpha1.MaintenanceWindow{weekend}}
+
+	tests := []struct {
+		now      string
+		deferred bool
+		until    string
+	}{
+		{"2026-10-17T21:59:00Z", false, ""},
+		{"2026-10-17T22:00:00Z", true, "2026-10-18T02:00:00Z"},
+		{"2026-10-18T01:30:00Z", true, "2026-10-18T02:00:00Z"},
+		{"2026-10-18T02:00:00Z", false, ""},
+		{"2026-10-18T22:30:00Z", false, ""},
+	}
+	for _, test := range tests {
+		now, _ := time.Parse(time.RFC3339, test.now)
+		deferred, until := changesDeferred(spec, now)
+		if deferred != test.deferred {
+			t.Errorf("%s: expected deferred %v, got %v", test.now, test.deferred, deferred)
+		}
+		if test.deferred && until.Format(time.RFC3339) != test.until {
+			t.Errorf("%s: expected deferred until %s, got %s", test.now, test.until, until.Format(time.RFC3339))
+		}
+	}
+
+	// The toggle defers until further notice
+	spec.DeferStackChanges = true
+	if deferred, until := changesDeferred(spec, time.Now()); !deferred || !until.IsZero() {
+		t.Errorf("expected changes deferred until further notice, got %v until %v", deferred, until)
+	}
+}
@@ -26,10 +26,15 @@ SOFTWARE.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	cfv1alpha1 "github.com/cuppett/aws-cloudformation-operator/apis/cloudformation.services.k8s.aws/v1alpha1"
 	configv1alpha1 "github.com/cuppett/aws-cloudformation-operator/apis/services.k8s.aws/v1alpha1"
 	"github.com/cuppett/aws-cloudformation-operator/controllers/cloudformation.services.k8s.aws"
@@ -51,6 +56,7 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -78,7 +84,74 @@ func init() {
 
 	StackFlagSet = pflag.NewFlagSet("stack", pflag.ExitOnError)
 	StackFlagSet.Bool("dry-run", false, "If true, don't actually do anything.")
+	StackFlagSet.Bool("validate-only", false,
+		"If true, only validate the Stacks, reporting the broken ones in their status (implies --dry-run).")
+	StackFlagSet.Bool("validate-templates", false,
+		"If true, with --validate-only, also have CloudFormation validate the templates of the Stacks.")
 	StackFlagSet.Bool("no-webhook", false, "If true, don't run the webhook server.")
+	StackFlagSet.Duration("requeue-after-submit", 30*time.Second,
+		"Delay before rechecking a stack after submitting a create or update (0 to disable).")
+	StackFlagSet.String("stack-events-queue-url", "",
+		"SQS queue receiving stack notifications (via SNS) to follow stacks by events rather than polling alone.")
+	StackFlagSet.String("status-webhook-url", "",
+		"URL POSTed a JSON payload on each stack status transition (defaults to STATUS_WEBHOOK_URL).")
+	StackFlagSet.String("deployment-record-webhook-url", "",
+		"URL POSTed a JSON deployment record on each stack operation which succeeded.")
+	StackFlagSet.String("deployment-record-bucket", "",
+		"S3 bucket each stack operation which succeeded writes a JSON deployment record to.")
+	StackFlagSet.String("deployment-record-prefix", "deployments/",
+		"Prefix of the keys of the deployment records written to the deployment record bucket.")
+	StackFlagSet.StringSlice("ready-statuses", nil,
+		"Stack statuses considered healthy for the Ready condition (defaults to CREATE_COMPLETE, UPDATE_COMPLETE, "+
+			"IMPORT_COMPLETE, UPDATE_ROLLBACK_COMPLETE and IMPORT_ROLLBACK_COMPLETE).")
+	StackFlagSet.String("stack-name-prefix", "", "Prefix of generated stack names (e.g. identifying the cluster).")
+	StackFlagSet.String("stack-name-suffix", "", "Suffix of generated stack names.")
+	StackFlagSet.String("stack-name-template", "",
+		"Template of generated stack names from {namespace}, {name}, {uid-short} and {hash} (defaults to {name}-{hash}).")
+	StackFlagSet.Duration("stack-cache-ttl", 5*time.Second,
+		"How long a described stack is reused before describing it again (0 to always describe).")
+	StackFlagSet.Duration("cloudformation-call-timeout", time.Minute,
+		"Bound on each CloudFormation API call, timed out calls are retried (0 for no bound).")
+	StackFlagSet.String("git-revision-annotation", cloudformation_services_k8s_aws.DefaultGitRevisionAnnotation,
+		"Annotation on Stacks whose value (the deploying Git revision) is tagged on the CloudFormation stack.")
+	StackFlagSet.Duration("orphaned-stacks-interval", 0,
+		"Interval between passes reporting controller stacks without a Stack resource (0 to disable).")
+	StackFlagSet.Bool("delete-orphaned-stacks", false,
+		"Delete the stacks found without a Stack resource on two consecutive passes rather than only reporting them.")
+	StackFlagSet.String("controller-instance-id", "",
+		"Identity of this installation tagged on its stacks, scoping orphaned stacks (defaults to the kube-system namespace UID).")
+	StackFlagSet.Duration("stack-summary-interval", 0,
+		"Interval between updates of the StackSummary aggregating the health of all Stacks (0 to disable).")
+	StackFlagSet.Int("force-delete-attempts", cloudformation_services_k8s_aws.DefaultForceDeleteAttempts,
+		"Failed deletions before a stack annotated for force deletion is forced (0 to never force).")
+	StackFlagSet.Int("max-concurrent-operations", 0,
+		"Maximum stack operations (create, update or delete) running in CloudFormation at once (0 for no limit).")
+	StackFlagSet.String("aws-retry-mode", "", "AWS SDK retry mode (standard or adaptive).")
+	StackFlagSet.Int("aws-retry-max-attempts", 0, "Maximum attempts for each AWS request (0 for the SDK default).")
+	StackFlagSet.Bool("use-fips-endpoint", false, "Use the FIPS endpoints of the AWS services.")
+	StackFlagSet.Duration("follower-poll-interval", time.Second,
+		"Interval between polls of stacks being followed (defaults to 30s when following by events).")
+	StackFlagSet.Duration("follower-max-poll-interval", 30*time.Second,
+		"Interval the polls of stacks holding their status back off to (no backoff when not above the poll interval).")
+	StackFlagSet.Bool("metrics-namespace-label", false,
+		"If true, label the stack operation metrics with the namespace of the Stack.")
+	StackFlagSet.String("namespace-selector", "",
+		"Label selector of the namespaces allowed to create and update stacks (e.g. cloudformation=allowed, all when empty).")
+	StackFlagSet.StringToString("namespace-label-tags", nil,
+		"Namespace labels tagged on the stacks of the namespace, as label=tag pairs (e.g. team=Team,cost-center=CostCenter).")
+	StackFlagSet.StringSlice("default-capabilities", nil,
+		"Capabilities submitted for stacks not listing their own (e.g. CAPABILITY_IAM), less those a stack disables.")
+	StackFlagSet.StringSlice("failover-regions", nil,
+		"Ordered regions to fail over to, named on stacks failing on their region being unreachable (none when empty).")
+	StackFlagSet.Bool("record-resolved-parameters", false,
+		"If true, record the values SSM-typed parameters resolved to in the status of the stacks.")
+	StackFlagSet.Bool("metrics-name-label", false,
+		"If true, label the stack operation metrics with the name of the Stack (high cardinality).")
+	StackFlagSet.String("template-upload-bucket", "",
+		"S3 bucket inline templates too large to submit directly are uploaded to.")
+	StackFlagSet.String("template-upload-sse", "",
+		"Server-side encryption of uploaded templates (AES256 or aws:kms), the bucket default when empty.")
+	StackFlagSet.String("template-upload-kms-key-id", "", "KMS key ID or ARN encrypting uploaded templates with aws:kms.")
 }
 
 func main() {
@@ -174,11 +247,87 @@ func main() {
 		setupLog.Error(err, "error parsing flag")
 		os.Exit(1)
 	}
+	validateOnly, err := StackFlagSet.GetBool("validate-only")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	validateTemplates, err := StackFlagSet.GetBool("validate-templates")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	// Auditing, no stack may be changed, by the orphan reaper included
+	dryRun = dryRun || validateOnly
+
+	requeueAfterSubmit, err := StackFlagSet.GetDuration("requeue-after-submit")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+
+	eventsQueueURL, err := StackFlagSet.GetString("stack-events-queue-url")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	pollInterval, err := StackFlagSet.GetDuration("follower-poll-interval")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if eventsQueueURL != "" && !StackFlagSet.Changed("follower-poll-interval") {
+		// With events driving the follower, polling is only a fallback
+		pollInterval = 30 * time.Second
+	}
+	maxPollInterval, err := StackFlagSet.GetDuration("follower-max-poll-interval")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+
+	statusWebhookURL, err := StackFlagSet.GetString("status-webhook-url")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if statusWebhookURL == "" {
+		statusWebhookURL = os.Getenv("STATUS_WEBHOOK_URL")
+	}
+
+	clientOptions := servicesk8saws.AWSClientOptions{}
+	retryMode, err := StackFlagSet.GetString("aws-retry-mode")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if retryMode != "" {
+		if clientOptions.RetryMode, err = aws.ParseRetryMode(retryMode); err != nil {
+			setupLog.Error(err, "error parsing flag")
+			os.Exit(1)
+		}
+	}
+	if clientOptions.RetryMaxAttempts, err = StackFlagSet.GetInt("aws-retry-max-attempts"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if clientOptions.UseFIPSEndpoint, err = StackFlagSet.GetBool("use-fips-endpoint"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	clientOptions.CredentialsRefreshes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "aws_credentials_refreshes_total",
+			Help: "Total number of AWS credentials refreshed after requests failed on expired credentials",
+		},
+	)
+	metrics.Registry.MustRegister(clientOptions.CredentialsRefreshes)
 
 	configReconciler := servicesk8saws.InitializeConfigReconciler(
 		mgr.GetClient(),
 		ctrl.Log.WithName("workers").WithName("Config"),
 		mgr.GetScheme(),
+		clientOptions,
 	)
 	if err = configReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Config")
@@ -195,13 +344,88 @@ func main() {
 		os.Exit(1)
 	}
 
+	readyStatuses, err := StackFlagSet.GetStringSlice("ready-statuses")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+
 	cfHelper := &cloudformation_services_k8s_aws.CloudFormationHelper{
 		ConfigReconciler: configReconciler,
 	}
+	for _, status := range readyStatuses {
+		cfHelper.ReadyStatuses = append(cfHelper.ReadyStatuses, cfTypes.StackStatus(status))
+	}
+	if cfHelper.StackNamePrefix, err = StackFlagSet.GetString("stack-name-prefix"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if cfHelper.StackNameSuffix, err = StackFlagSet.GetString("stack-name-suffix"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if cfHelper.StackNameTemplate, err = StackFlagSet.GetString("stack-name-template"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if cfHelper.StackCacheTTL, err = StackFlagSet.GetDuration("stack-cache-ttl"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if cfHelper.CallTimeout, err = StackFlagSet.GetDuration("cloudformation-call-timeout"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	gitRevisionAnnotation, err := StackFlagSet.GetString("git-revision-annotation")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	forceDeleteAttempts, err := StackFlagSet.GetInt("force-delete-attempts")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if err = cfHelper.ValidateStackNameAffixes(); err != nil {
+		setupLog.Error(err, "invalid stack name prefix/suffix")
+		os.Exit(1)
+	}
+	if err = cfHelper.ValidateStackNameTemplate(); err != nil {
+		setupLog.Error(err, "invalid stack name template")
+		os.Exit(1)
+	}
+
+	var templateUploader *cloudformation_services_k8s_aws.TemplateUploader
+	templateUploadBucket, err := StackFlagSet.GetString("template-upload-bucket")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if templateUploadBucket != "" {
+		templateUploader = &cloudformation_services_k8s_aws.TemplateUploader{
+			CloudFormationHelper: cfHelper,
+			Bucket:               templateUploadBucket,
+		}
+		templateUploadSSE, err := StackFlagSet.GetString("template-upload-sse")
+		if err != nil {
+			setupLog.Error(err, "error parsing flag")
+			os.Exit(1)
+		}
+		templateUploader.ServerSideEncryption = s3Types.ServerSideEncryption(templateUploadSSE)
+		if templateUploader.KMSKeyID, err = StackFlagSet.GetString("template-upload-kms-key-id"); err != nil {
+			setupLog.Error(err, "error parsing flag")
+			os.Exit(1)
+		}
+		if err = templateUploader.Validate(); err != nil {
+			setupLog.Error(err, "invalid template upload encryption")
+			os.Exit(1)
+		}
+	}
 
 	channelHub := &cloudformation_services_k8s_aws.ChannelHub{
 		MappingChannel: make(chan *cfv1alpha1.Stack),
-		FollowChannel:  make(chan *cfv1alpha1.Stack),
+		FollowQueue:    cloudformation_services_k8s_aws.NewFollowQueue(),
+		EventChannel:   make(chan string, 100),
 	}
 
 	mapWriter := &cloudformation_services_k8s_aws.MapWriter{
@@ -212,11 +436,29 @@ func main() {
 	}
 	go mapWriter.Worker()
 
+	maxConcurrentOperations, err := StackFlagSet.GetInt("max-concurrent-operations")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	operationLimiter := &cloudformation_services_k8s_aws.OperationLimiter{Limit: maxConcurrentOperations}
+
+	recordResolvedParameters, err := StackFlagSet.GetBool("record-resolved-parameters")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+
 	stackFollower := &cloudformation_services_k8s_aws.StackFollower{
-		Client:               mgr.GetClient(),
-		Log:                  ctrl.Log.WithName("workers").WithName("Stack"),
-		ChannelHub:           *channelHub,
-		CloudFormationHelper: cfHelper,
+		Client:                   mgr.GetClient(),
+		Log:                      ctrl.Log.WithName("workers").WithName("Stack"),
+		ChannelHub:               *channelHub,
+		CloudFormationHelper:     cfHelper,
+		PollInterval:             pollInterval,
+		MaxPollInterval:          maxPollInterval,
+		Recorder:                 mgr.GetEventRecorderFor("stack-follower"),
+		OperationLimiter:         operationLimiter,
+		RecordResolvedParameters: recordResolvedParameters,
 		StacksFollowing: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "cloudformation_stacks_following",
@@ -229,20 +471,224 @@ func main() {
 				Help: "Total number of CloudFormation stacks followed (lifetime)",
 			},
 		),
+		StacksByStatus: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cloudformation_stacks_following_by_status",
+				Help: "Number of CloudFormation stacks being followed currently by their latest status",
+			},
+			[]string{"status"},
+		),
+		PollDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "cloudformation_follower_poll_duration_seconds",
+				Help:    "Time taken by each poll of the CloudFormation stacks being followed",
+				Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+			},
+		),
+		PollErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cloudformation_follower_poll_errors_total",
+				Help: "Total number of failed polls of the CloudFormation stacks being followed",
+			},
+			[]string{"namespace", "name"},
+		),
+	}
+	if statusWebhookURL != "" {
+		stackFollower.StatusNotifier = &cloudformation_services_k8s_aws.StatusNotifier{
+			Log: ctrl.Log.WithName("workers").WithName("StatusWebhook"),
+			URL: statusWebhookURL,
+		}
+	}
+	deploymentRecordWebhookURL, err := StackFlagSet.GetString("deployment-record-webhook-url")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	deploymentRecordBucket, err := StackFlagSet.GetString("deployment-record-bucket")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	deploymentRecordPrefix, err := StackFlagSet.GetString("deployment-record-prefix")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	switch {
+	case deploymentRecordWebhookURL != "" && deploymentRecordBucket != "":
+		setupLog.Error(nil, "only one of deployment-record-webhook-url and deployment-record-bucket can be set")
+		os.Exit(1)
+	case deploymentRecordWebhookURL != "":
+		stackFollower.DeploymentSink = &cloudformation_services_k8s_aws.WebhookDeploymentSink{
+			URL: deploymentRecordWebhookURL,
+		}
+	case deploymentRecordBucket != "":
+		stackFollower.DeploymentSink = &cloudformation_services_k8s_aws.S3DeploymentSink{
+			CloudFormationHelper: cfHelper,
+			Bucket:               deploymentRecordBucket,
+			Prefix:               deploymentRecordPrefix,
+		}
 	}
 	go stackFollower.Receiver()
 	go stackFollower.Worker()
+
+	if eventsQueueURL != "" {
+		eventListener := &cloudformation_services_k8s_aws.StackEventListener{
+			ChannelHub:           *channelHub,
+			Log:                  ctrl.Log.WithName("workers").WithName("StackEvents"),
+			CloudFormationHelper: cfHelper,
+			QueueURL:             eventsQueueURL,
+		}
+		if err = mgr.Add(eventListener); err != nil {
+			setupLog.Error(err, "unable to add the stack event listener")
+			os.Exit(1)
+		}
+	}
+
+	orphanedStacksInterval, err := StackFlagSet.GetDuration("orphaned-stacks-interval")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	deleteOrphanedStacks, err := StackFlagSet.GetBool("delete-orphaned-stacks")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	controllerInstanceID, err := StackFlagSet.GetString("controller-instance-id")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if controllerInstanceID == "" {
+		controllerInstanceID, err = cloudformation_services_k8s_aws.ClusterInstanceID(context.Background(),
+			mgr.GetAPIReader())
+		if err != nil {
+			setupLog.Error(err, "unable to identify the cluster, stacks are not tagged with an instance ID")
+		}
+	}
+	if orphanedStacksInterval > 0 {
+		if controllerInstanceID == "" {
+			setupLog.Error(cloudformation_services_k8s_aws.ErrNoInstanceID, "set --controller-instance-id")
+			os.Exit(1)
+		}
+		orphanReaper := &cloudformation_services_k8s_aws.OrphanReaper{
+			Client:               mgr.GetClient(),
+			Log:                  ctrl.Log.WithName("workers").WithName("OrphanedStacks"),
+			CloudFormationHelper: cfHelper,
+			InstanceID:           controllerInstanceID,
+			Interval:             orphanedStacksInterval,
+			Delete:               deleteOrphanedStacks,
+			DryRun:               dryRun,
+			Orphans: prometheus.NewGauge(
+				prometheus.GaugeOpts{
+					Name: "cloudformation_stacks_orphaned",
+					Help: "Number of controller stacks without a Stack resource found on the latest pass",
+				},
+			),
+		}
+		metrics.Registry.MustRegister(orphanReaper.Orphans)
+		if err = mgr.Add(orphanReaper); err != nil {
+			setupLog.Error(err, "unable to add the orphan reaper")
+			os.Exit(1)
+		}
+	}
+
+	stackSummaryInterval, err := StackFlagSet.GetDuration("stack-summary-interval")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if stackSummaryInterval > 0 {
+		if err = mgr.Add(&cloudformation_services_k8s_aws.StackSummarizer{
+			Client:               mgr.GetClient(),
+			Log:                  ctrl.Log.WithName("workers").WithName("StackSummary"),
+			CloudFormationHelper: cfHelper,
+			Interval:             stackSummaryInterval,
+		}); err != nil {
+			setupLog.Error(err, "unable to add the stack summary")
+			os.Exit(1)
+		}
+	}
+
 	metrics.Registry.MustRegister(stackFollower.StacksFollowing)
 	metrics.Registry.MustRegister(stackFollower.StacksFollowed)
+	metrics.Registry.MustRegister(stackFollower.StacksByStatus)
+	metrics.Registry.MustRegister(stackFollower.PollDuration, stackFollower.PollErrors)
+
+	metricsNamespaceLabel, err := StackFlagSet.GetBool("metrics-namespace-label")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	metricsNameLabel, err := StackFlagSet.GetBool("metrics-name-label")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	namespaceSelector, err := StackFlagSet.GetString("namespace-selector")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	var allowedNamespaces labels.Selector
+	if namespaceSelector != "" {
+		if allowedNamespaces, err = labels.Parse(namespaceSelector); err != nil {
+			setupLog.Error(err, "invalid namespace selector")
+			os.Exit(1)
+		}
+	}
+
+	namespaceLabelTags, err := StackFlagSet.GetStringToString("namespace-label-tags")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+
+	defaultCapabilities, err := StackFlagSet.GetStringSlice("default-capabilities")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+
+	failoverRegions, err := StackFlagSet.GetStringSlice("failover-regions")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+
+	stackMetrics := cloudformation_services_k8s_aws.NewStackMetrics(metricsNamespaceLabel, metricsNameLabel)
+	metrics.Registry.MustRegister(stackMetrics.Operations, stackMetrics.TemplateSizes, stackMetrics.TemplateUploads)
 
 	if err = (&cloudformation_services_k8s_aws.StackReconciler{
-		Client:               mgr.GetClient(),
-		ChannelHub:           *channelHub,
-		Log:                  ctrl.Log.WithName("controllers").WithName("Stack"),
-		Scheme:               mgr.GetScheme(),
-		WatchNamespaces:      watchNamespaces,
-		CloudFormationHelper: cfHelper,
-		DryRun:               dryRun,
+		Client:                mgr.GetClient(),
+		ChannelHub:            *channelHub,
+		Log:                   ctrl.Log.WithName("controllers").WithName("Stack"),
+		Scheme:                mgr.GetScheme(),
+		WatchNamespaces:       watchNamespaces,
+		CloudFormationHelper:  cfHelper,
+		DryRun:                dryRun,
+		InstanceID:            controllerInstanceID,
+		SubmitRequeueAfter:    requeueAfterSubmit,
+		Recorder:              mgr.GetEventRecorderFor("stack-controller"),
+		GitRevisionAnnotation: gitRevisionAnnotation,
+		ForceDeleteAttempts:   forceDeleteAttempts,
+		TemplateUploader:      templateUploader,
+		Metrics:               stackMetrics,
+		OperationLimiter:      operationLimiter,
+		APIReader:             mgr.GetAPIReader(),
+		NamespaceSelector:     allowedNamespaces,
+		NamespaceLabelTags:    namespaceLabelTags,
+		ValidateOnly:          validateOnly,
+		ValidateTemplates:     validateTemplates,
+		DefaultCapabilities:   defaultCapabilities,
+		FailoverRegions:       failoverRegions,
+		PreDeleteHooks: []cloudformation_services_k8s_aws.PreDeleteHook{
+			&cloudformation_services_k8s_aws.S3EmptyBucketsHook{
+				Log:                  ctrl.Log.WithName("hooks").WithName("S3EmptyBuckets"),
+				CloudFormationHelper: cfHelper,
+			},
+		},
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Stack")
 		os.Exit(1)